@@ -2,15 +2,27 @@ package main
 
 import (
 	"flag"
+	"fmt"
+	"os"
 	system "sharedkitchenordersystem/internal/app/sharedkitchenordersystem"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/clock"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/config"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/repository/order"
+	"time"
 
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 func main() {
 
+	// Sweep simulations over a grid of parameters instead of running the kitchen
+	if len(os.Args) > 1 && os.Args[1] == "simulate" {
+		os.Exit(simulate(os.Args[2:]))
+	}
+
 	// Create a zap logger with appropriate configuration.
-	logger := createZapLogger()
+	logger := createZapLogger(zap.InfoLevel)
 
 	// Replace the global logger with created logger. After this
 	// any package in the process can use zap.L() or zap.S()
@@ -18,17 +30,148 @@ func main() {
 	zap.S().Info("Starting main method")
 
 	var noOfOrdersToRead int
-	flag.IntVar(&noOfOrdersToRead, "noOfOrdersToRead", 2, "Orders receive rate")
+	var address string
+	var configPath string
+	var sourceKind string
+	var ordersPath string
+	var exitOnCompletion bool
+	var seed int64
+	var virtualTime bool
+	var statePath string
+	var reportPath string
+	flag.IntVar(&noOfOrdersToRead, "noOfOrdersToRead", system.DefaultNoOfOrdersToRead, "Orders receive rate")
+	flag.StringVar(&address, "address", ":1323", "HTTP address to accept orders on")
+	flag.StringVar(&configPath, "config", "", "Path to the kitchen layout YAML config file")
+	flag.StringVar(&sourceKind, "source", order.FILE_SOURCE, "Order source: 'file' (JSON array file), 'stdin' (newline delimited JSON) or 'dir' (directory watched for JSON files)")
+	flag.StringVar(&ordersPath, "orders", "", "Orders file for the 'file' source or directory for the 'dir' source")
+	flag.BoolVar(&exitOnCompletion, "exitOnCompletion", false, "Exit once the order source has no more orders and every order is picked up, expired or evicted")
+	flag.Int64Var(&seed, "seed", 0, "Seed of the random courier delays and evictions; a random seed is used if 0")
+	flag.BoolVar(&virtualTime, "virtualTime", false, "Simulate the run on a virtual clock: orders are read from the 'file' source only, no orders are accepted over HTTP and the application exits once every order is completed")
+	flag.StringVar(&statePath, "state", "", "File the state of the kitchen is saved to and restored from; overrides the persistence path of the config file")
+	flag.StringVar(&reportPath, "report", "", "File the order status report is written to when the application exits: CSV of the orders if its extension is '.csv', else JSON")
 	flag.Parse()
 
 	zap.S().Infof("Configuration: Read noOfOrdersToRead '%d'", noOfOrdersToRead)
+	zap.S().Infof("Configuration: Read address '%s'", address)
+
+	cfg := config.Default()
+	if configPath != "" {
+		var err error
+		if cfg, err = config.Load(configPath); err != nil {
+			zap.S().Fatal(err)
+		}
+		zap.S().Infof("Configuration: Read config file '%s'", configPath)
+	}
+
+	if statePath != "" {
+		cfg.Persistence.Path = statePath
+	}
+	if cfg.Persistence.Path != "" {
+		zap.S().Infof("Configuration: Read state file '%s'", cfg.Persistence.Path)
+	}
+
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	zap.S().Infof("Configuration: Read seed '%d'", seed)
+
+	var kitchenClock clock.Clock = clock.NewWallClock()
+	if virtualTime {
+		if sourceKind != order.FILE_SOURCE {
+			zap.S().Fatalf("Configuration: Virtual time requires the '%s' order source", order.FILE_SOURCE)
+		}
+		kitchenClock = clock.NewVirtualClock(time.Now())
+		address = ""
+		exitOnCompletion = true
+		zap.S().Info("Configuration: Running on virtual time")
+	}
+
+	source, err := order.NewOrderSource(sourceKind, ordersPath, kitchenClock)
+	if err != nil {
+		zap.S().Fatal(err)
+	}
+	zap.S().Infof("Configuration: Read order source '%s'", sourceKind)
 
 	// Start application
-	system.Start(noOfOrdersToRead)
+	exitCode := system.Start(system.Options{
+		NoOfOrdersToRead: noOfOrdersToRead,
+		Address:          address,
+		Config:           cfg,
+		Source:           source,
+		ExitOnCompletion: exitOnCompletion,
+		Clock:            kitchenClock,
+		Seed:             seed,
+		ReportPath:       reportPath,
+	})
+	logger.Sync()
+	os.Exit(exitCode)
+}
+
+// simulate runs the simulations of the grid of parameters given by the arguments and prints their results as a
+// table. It gives the exit status code of the command
+func simulate(args []string) int {
+
+	// Only warnings and errors are logged, so the table is not buried in the logs of every run
+	logger := createZapLogger(zap.WarnLevel).WithOptions(zap.AddStacktrace(zap.FatalLevel))
+	zap.ReplaceGlobals(logger)
+	defer logger.Sync()
+
+	var configPath string
+	var ordersPath string
+	var seed int64
+	var noOfOrdersToRead string
+	var shelfCapacities string
+	var overflowCapacities string
+	var courierDelays string
+	var evictionPolicies string
+	flags := flag.NewFlagSet("simulate", flag.ExitOnError)
+	flags.StringVar(&configPath, "config", "", "Path to the kitchen layout YAML config file the simulations are based on")
+	flags.StringVar(&ordersPath, "orders", "", "Orders file replayed by every simulation")
+	flags.Int64Var(&seed, "seed", 0, "Seed of the random courier delays and evictions of every simulation; a random seed is used if 0")
+	flags.StringVar(&noOfOrdersToRead, "noOfOrdersToRead", "", "Comma-separated orders receive rates")
+	flags.StringVar(&shelfCapacities, "shelfCapacities", "", "Comma-separated capacities of every temperature controlled shelf")
+	flags.StringVar(&overflowCapacities, "overflowCapacities", "", "Comma-separated capacities of the overflow shelf")
+	flags.StringVar(&courierDelays, "courierDelays", "", "Comma-separated ranges of courier delays (seconds), as 'min-max'")
+	flags.StringVar(&evictionPolicies, "evictionPolicies", "", "Comma-separated eviction policies")
+	flags.Parse(args)
+
+	cfg := config.Default()
+	if configPath != "" {
+		var err error
+		if cfg, err = config.Load(configPath); err != nil {
+			zap.S().Error(err)
+			return 1
+		}
+	}
+
+	grid, err := system.ParseSimulationGrid(noOfOrdersToRead, shelfCapacities, overflowCapacities, courierDelays, evictionPolicies)
+	if err != nil {
+		zap.S().Error(err)
+		return 1
+	}
+
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	results, err := system.Simulate(cfg, grid, ordersPath, seed)
+	if err != nil {
+		zap.S().Error(err)
+		return 1
+	}
+
+	fmt.Printf("Simulations of seed %d:\n", seed)
+	if err := system.WriteSimulations(os.Stdout, results); err != nil {
+		zap.S().Error(err)
+		return 1
+	}
+	return 0
 }
 
-func createZapLogger() *zap.Logger {
-	logger, err := zap.NewDevelopment()
+func createZapLogger(level zapcore.Level) *zap.Logger {
+	loggerConfig := zap.NewDevelopmentConfig()
+	loggerConfig.Level = zap.NewAtomicLevelAt(level)
+	logger, err := loggerConfig.Build()
 	if err != nil {
 		zap.S().Fatal(err)
 	}
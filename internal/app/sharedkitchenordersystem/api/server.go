@@ -0,0 +1,288 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/metrics"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/model"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/service/intake"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/service/supervisor"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type acceptedResponse struct {
+	IDs []string `json:"ids"`
+}
+
+type shelvesResponse struct {
+	Time    time.Time             `json:"time"`
+	Shelves []model.ShelfSnapshot `json:"shelves"`
+}
+
+// Interval between the comments keeping an event stream open while no event is sent
+const keepAliveInterval = 15 * time.Second
+
+// Largest request body of orders read; a larger body is refused
+const maxOrdersBodyBytes = 1 << 20
+
+var errOrdersBodyTooLarge = errors.New(fmt.Sprintf("Request body is larger than %d bytes", maxOrdersBodyBytes))
+
+type errorResponse struct {
+	Error string `json:"error"`
+
+	Rejected []model.ValidationError `json:"rejected,omitempty"`
+}
+
+// handler serves the routes of a single kitchen
+type handler struct {
+	intake *intake.Service
+	report *supervisor.ReportBook
+}
+
+// Start starts the HTTP server serving the given handler on the given address. The server is stopped with its Shutdown method
+func Start(address string, handler http.Handler) *http.Server {
+	server := &http.Server{Addr: address, Handler: handler}
+
+	go func() {
+		zap.S().Infof("API: Listening for orders on '%s'", address)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			zap.S().Errorf("API: Server stopped: %s", err)
+		}
+	}()
+
+	return server
+}
+
+// NewHandler creates the HTTP handler serving the order intake, lookup, shelves and events routes, and the metrics of the
+// collector
+func NewHandler(intake *intake.Service, report *supervisor.ReportBook, collector metrics.Collector) http.Handler {
+	h := &handler{intake: intake, report: report}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/orders", h.handleOrders)
+	mux.HandleFunc("/orders/", h.handleOrder)
+	mux.HandleFunc("/shelves", h.handleShelves)
+	mux.HandleFunc("/events", h.handleEvents)
+	mux.Handle("/metrics", metrics.Handler(collector))
+	return mux
+}
+
+// handleOrders accepts a single order or a batch of orders and sends them to the kitchen
+func (h *handler) handleOrders(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: fmt.Sprintf("Method '%s' not allowed", r.Method)})
+		return
+	}
+
+	orders, err := decodeOrders(w, r)
+	if err == errOrdersBodyTooLarge {
+		writeJSON(w, http.StatusRequestEntityTooLarge, errorResponse{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	// Accept the request only if every order in it is valid
+	zap.S().Infof("API: Received number of orders '%d' and are being sent to kitchen", len(orders))
+	rejected, err := h.intake.TrySubmit(orders)
+	if err != nil {
+		w.Header().Set("Retry-After", "1")
+		writeJSON(w, http.StatusServiceUnavailable, errorResponse{Error: err.Error()})
+		return
+	}
+	if len(rejected) > 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "Invalid orders", Rejected: rejected})
+		return
+	}
+
+	ids := make([]string, 0, len(orders))
+	for _, order := range orders {
+		ids = append(ids, order.ID)
+	}
+
+	writeJSON(w, http.StatusAccepted, acceptedResponse{IDs: ids})
+}
+
+// handleOrder gives the status, status history and shelf details of a single order, or cancels it
+func (h *handler) handleOrder(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodDelete {
+		w.Header().Set("Allow", strings.Join([]string{http.MethodGet, http.MethodDelete}, ", "))
+		writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: fmt.Sprintf("Method '%s' not allowed", r.Method)})
+		return
+	}
+
+	orderId := strings.TrimPrefix(r.URL.Path, "/orders/")
+	if orderId == "" || strings.Contains(orderId, "/") {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: fmt.Sprintf("Route '%s' not found", r.URL.Path)})
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		h.cancelOrder(w, orderId)
+		return
+	}
+
+	details, err := h.report.Lookup(orderId)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, details)
+}
+
+// cancelOrder cancels an accepted order which is not completed yet; the order is cancelled asynchronously
+func (h *handler) cancelOrder(w http.ResponseWriter, orderId string) {
+	if !h.report.IsAccepted(orderId) {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: fmt.Sprintf("Order '%s' not found", orderId)})
+		return
+	}
+
+	if err := h.intake.Cancel(orderId); err != nil {
+		writeJSON(w, http.StatusConflict, errorResponse{Error: err.Error()})
+		return
+	}
+
+	zap.S().Infof("API: Order '%s' is being cancelled", orderId)
+	writeJSON(w, http.StatusAccepted, acceptedResponse{IDs: []string{orderId}})
+}
+
+// decodeOrders reads either a single order object or an array of orders from the request body, up to
+// maxOrdersBodyBytes of it
+func decodeOrders(w http.ResponseWriter, r *http.Request) ([]model.Order, error) {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxOrdersBodyBytes))
+	if err != nil && len(body) >= maxOrdersBodyBytes {
+		return nil, errOrdersBodyTooLarge
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return nil, errors.New("Request body is empty")
+	}
+
+	var orders []model.Order
+	if body[0] == '[' {
+		err = json.Unmarshal(body, &orders)
+	} else {
+		var order model.Order
+		err = json.Unmarshal(body, &order)
+		orders = []model.Order{order}
+	}
+
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Invalid order payload: %s", err))
+	}
+
+	if len(orders) == 0 {
+		return nil, errors.New("No orders in request")
+	}
+
+	return orders, nil
+}
+
+// handleShelves gives the orders on every shelf with their age, remaining shelf life and value
+func (h *handler) handleShelves(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: fmt.Sprintf("Method '%s' not allowed", r.Method)})
+		return
+	}
+
+	now, shelves := h.report.Shelves()
+	writeJSON(w, http.StatusOK, shelvesResponse{Time: now, Shelves: shelves})
+}
+
+// handleEvents streams the order events selected by the orderId, temp and status query parameters as server-sent events,
+// until the client goes away or the kitchen stops. Each parameter may be repeated or hold comma-separated values
+func (h *handler) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: fmt.Sprintf("Method '%s' not allowed", r.Method)})
+		return
+	}
+
+	flusher, isFlusher := w.(http.Flusher)
+	if !isFlusher {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: "Streaming not supported"})
+		return
+	}
+
+	query := r.URL.Query()
+	filter := supervisor.EventFilter{
+		OrderIds:     queryValues(query["orderId"]),
+		Temperatures: queryValues(query["temp"]),
+		Statuses:     queryValues(query["status"]),
+	}
+
+	subscription := h.report.Subscribe(filter)
+	defer h.report.Unsubscribe(subscription)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	zap.S().Infof("API: Streaming order events to '%s'", r.RemoteAddr)
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			zap.S().Infof("API: Order events stream to '%s' closed by the client", r.RemoteAddr)
+			return
+		case event, isOpen := <-subscription.Events():
+			if !isOpen {
+				zap.S().Infof("API: Order events stream to '%s' ended", r.RemoteAddr)
+				return
+			}
+
+			data, err := json.Marshal(event)
+			if err != nil {
+				zap.S().Errorf("API: Could not encode order event: %s", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Status, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// queryValues splits the comma-separated values of a repeated query parameter, leaving out the empty ones
+func queryValues(params []string) []string {
+	values := []string{}
+	for _, param := range params {
+		for _, value := range strings.Split(param, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,518 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/bus"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/clock"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/config"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/metrics"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/model"
+	repo "sharedkitchenordersystem/internal/app/sharedkitchenordersystem/repository/shelf"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/service/intake"
+	kitchenService "sharedkitchenordersystem/internal/app/sharedkitchenordersystem/service/kitchen"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/service/supervisor"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestHandler creates a handler for a kitchen with the default shelves and a running supervisor
+func newTestHandler() (http.Handler, *bus.Bus, *supervisor.ReportBook) {
+	return newTestHandlerWithKitchen(config.Default().Kitchen)
+}
+
+// newTestHandlerWithKitchen creates a handler for a kitchen with the default shelves, a running supervisor and the
+// given stations; the orders sent to the kitchen are not cooked
+func newTestHandlerWithKitchen(kitchen config.KitchenConfig) (http.Handler, *bus.Bus, *supervisor.ReportBook) {
+	eventBus := bus.New(10, clock.NewWallClock())
+	shelves := repo.New(config.Default().Shelves)
+	s := supervisor.New(eventBus, shelves, config.Default().IdleTimeoutS)
+	s.Start(context.Background())
+	cooking := kitchenService.New(eventBus, shelves, s.Report, kitchen)
+	return NewHandler(intake.New(eventBus, shelves, s.Report, cooking), s.Report, testCollector{}), eventBus, s.Report
+}
+
+// testCollector collects a single gauge
+type testCollector struct{}
+
+func (collector testCollector) Collect(writer *metrics.Writer) {
+	writer.Family("test_gauge", metrics.GAUGE, "Test gauge.")
+	writer.Sample("test_gauge", 1)
+}
+
+func Test_handleOrders(t *testing.T) {
+	handler, eventBus, _ := newTestHandler()
+
+	tests := []struct {
+		name           string
+		method         string
+		body           string
+		wantStatusCode int
+		wantOrders     int
+		wantRejected   int
+	}{
+		{
+			name:           "Test_handleOrders_SingleOrder_Accepted",
+			method:         http.MethodPost,
+			body:           `{"id":"1","name":"Banana Split","temp":"frozen","shelfLife":20,"decayRate":0.63}`,
+			wantStatusCode: http.StatusAccepted,
+			wantOrders:     1,
+		},
+		{
+			name:           "Test_handleOrders_BatchOfOrders_Accepted",
+			method:         http.MethodPost,
+			body:           `[{"id":"2","name":"Yogurt","temp":"cold","shelfLife":263,"decayRate":0.37},{"id":"3","name":"Pizza","temp":"hot","shelfLife":300,"decayRate":0.45}]`,
+			wantStatusCode: http.StatusAccepted,
+			wantOrders:     2,
+		},
+		{
+			name:           "Test_handleOrders_MissingID_BadRequest",
+			method:         http.MethodPost,
+			body:           `{"name":"Yogurt","temp":"cold","shelfLife":263,"decayRate":0.37}`,
+			wantStatusCode: http.StatusBadRequest,
+			wantRejected:   1,
+		},
+		{
+			name:           "Test_handleOrders_BatchWithInvalidOrders_BadRequest",
+			method:         http.MethodPost,
+			body:           `[{"id":"4","name":"Yogurt","temp":"cold","shelfLife":263,"decayRate":0.37},{"id":"5","name":"Pizza","temp":"warm","shelfLife":300,"decayRate":0.45},{"id":"6","name":"Soup","temp":"hot","shelfLife":0,"decayRate":-1}]`,
+			wantStatusCode: http.StatusBadRequest,
+			wantRejected:   2,
+		},
+		{
+			name:           "Test_handleOrders_TakenID_BadRequest",
+			method:         http.MethodPost,
+			body:           `[{"id":"7","name":"Yogurt","temp":"cold","shelfLife":263,"decayRate":0.37},{"id":"1","name":"Banana Split","temp":"frozen","shelfLife":20,"decayRate":0.63}]`,
+			wantStatusCode: http.StatusBadRequest,
+			wantRejected:   1,
+		},
+		{
+			name:           "Test_handleOrders_MalformedJSON_BadRequest",
+			method:         http.MethodPost,
+			body:           `{"id":`,
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "Test_handleOrders_BodyTooLarge_RequestEntityTooLarge",
+			method:         http.MethodPost,
+			body:           `[` + strings.Repeat(`{"id":"8","name":"Yogurt","temp":"cold","shelfLife":263,"decayRate":0.37},`, maxOrdersBodyBytes/64) + `]`,
+			wantStatusCode: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:           "Test_handleOrders_Get_MethodNotAllowed",
+			method:         http.MethodGet,
+			wantStatusCode: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(tt.method, "/orders", strings.NewReader(tt.body)))
+
+			if recorder.Code != tt.wantStatusCode {
+				t.Errorf("handleOrders(), got status %d, want %d", recorder.Code, tt.wantStatusCode)
+			}
+
+			if tt.wantRejected > 0 {
+				var response errorResponse
+				if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil || len(response.Rejected) != tt.wantRejected {
+					t.Errorf("handleOrders(), got rejected %v (error %v), want %d rejected orders", response.Rejected, err, tt.wantRejected)
+				}
+			}
+
+			if tt.wantOrders == 0 {
+				select {
+				case orders := <-eventBus.KitchenChannel:
+					t.Errorf("handleOrders(), got orders %v sent to kitchen, want none", orders)
+				default:
+				}
+				return
+			}
+
+			select {
+			case orders := <-eventBus.KitchenChannel:
+				if len(orders) != tt.wantOrders {
+					t.Errorf("handleOrders(), got %d orders sent to kitchen, want %d", len(orders), tt.wantOrders)
+				}
+			default:
+				t.Errorf("handleOrders(), no orders sent to kitchen")
+			}
+		})
+	}
+}
+
+func Test_handleOrders_KitchenQueueFull(t *testing.T) {
+	handler, eventBus, report := newTestHandlerWithKitchen(config.KitchenConfig{Stations: 1, QueueSize: 2})
+
+	tests := []struct {
+		name           string
+		body           string
+		wantStatusCode int
+		wantAccepted   []string
+	}{
+		{
+			name:           "Test_handleOrders_KitchenQueueFull_RoomLeft_Accepted",
+			body:           `[{"id":"1","name":"Yogurt","temp":"cold","shelfLife":263,"decayRate":0.37},{"id":"2","name":"Pizza","temp":"hot","shelfLife":300,"decayRate":0.45}]`,
+			wantStatusCode: http.StatusAccepted,
+			wantAccepted:   []string{"1", "2"},
+		},
+		{
+			name:           "Test_handleOrders_KitchenQueueFull_NoRoom_ServiceUnavailable",
+			body:           `{"id":"3","name":"Banana Split","temp":"frozen","shelfLife":20,"decayRate":0.63}`,
+			wantStatusCode: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(tt.body)))
+
+			if recorder.Code != tt.wantStatusCode {
+				t.Errorf("handleOrders(), got status %d, want %d", recorder.Code, tt.wantStatusCode)
+			}
+
+			for _, orderId := range tt.wantAccepted {
+				if !report.IsAccepted(orderId) {
+					t.Errorf("handleOrders(), got order %s not accepted, want accepted", orderId)
+				}
+			}
+
+			// The kitchen does not cook the orders accepted, so they stay in its queue
+			if tt.wantAccepted != nil {
+				<-eventBus.KitchenChannel
+			} else {
+				if retryAfter := recorder.Header().Get("Retry-After"); retryAfter == "" {
+					t.Errorf("handleOrders(), got no Retry-After header, want one")
+				}
+				if report.IsAccepted("3") {
+					t.Errorf("handleOrders(), got order 3 refused but accepted, want its id free")
+				}
+
+				select {
+				case orders := <-eventBus.KitchenChannel:
+					t.Errorf("handleOrders(), got orders %v sent to kitchen, want none", orders)
+				default:
+				}
+			}
+		})
+	}
+}
+
+func Test_handleOrder(t *testing.T) {
+	handler, eventBus, report := newTestHandler()
+	eventBus.SupervisorChannel <- model.OrderStatus{OrderId: "1", Status: model.ORDER_RECEIVED}
+	eventBus.SupervisorChannel <- model.OrderStatus{OrderId: "1", Status: model.ORDER_PROCESSED}
+
+	// Wait for the supervisor to record the statuses
+	for i := 0; i < 100; i++ {
+		if details, err := report.Lookup("1"); err == nil && len(details.History) == 2 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	tests := []struct {
+		name           string
+		path           string
+		wantStatusCode int
+		wantStatus     string
+		wantHistory    int
+	}{
+		{
+			name:           "Test_handleOrder_KnownOrder_Found",
+			path:           "/orders/1",
+			wantStatusCode: http.StatusOK,
+			wantStatus:     model.ORDER_PROCESSED,
+			wantHistory:    2,
+		},
+		{
+			name:           "Test_handleOrder_UnknownOrder_NotFound",
+			path:           "/orders/2",
+			wantStatusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if recorder.Code != tt.wantStatusCode {
+				t.Fatalf("handleOrder(), got status %d, want %d", recorder.Code, tt.wantStatusCode)
+			}
+
+			if tt.wantStatusCode != http.StatusOK {
+				return
+			}
+
+			var details model.OrderDetails
+			if err := json.NewDecoder(recorder.Body).Decode(&details); err != nil {
+				t.Fatalf("handleOrder(), got invalid body: %s", err)
+			}
+
+			if details.Status != tt.wantStatus || len(details.History) != tt.wantHistory {
+				t.Errorf("handleOrder(), got status %s with %d history entries, want %s with %d", details.Status, len(details.History), tt.wantStatus, tt.wantHistory)
+			}
+		})
+	}
+}
+
+func Test_handleOrder_Delete(t *testing.T) {
+	handler, eventBus, report := newTestHandler()
+	report.Accept([]model.Order{{ID: "1"}, {ID: "2"}})
+	eventBus.SupervisorChannel <- model.OrderStatus{OrderId: "2", Status: model.ORDER_PICKED}
+
+	// Wait for the supervisor to record the status
+	for i := 0; i < 100; i++ {
+		if details, err := report.Lookup("2"); err == nil && details.Status == model.ORDER_PICKED {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		wantStatusCode int
+		wantCancelled  bool
+	}{
+		{
+			name:           "Test_handleOrder_Delete_OrderInFlight_Accepted",
+			method:         http.MethodDelete,
+			path:           "/orders/1",
+			wantStatusCode: http.StatusAccepted,
+			wantCancelled:  true,
+		},
+		{
+			name:           "Test_handleOrder_Delete_OrderAlreadyCancelled_Conflict",
+			method:         http.MethodDelete,
+			path:           "/orders/1",
+			wantStatusCode: http.StatusConflict,
+		},
+		{
+			name:           "Test_handleOrder_Delete_OrderPicked_Conflict",
+			method:         http.MethodDelete,
+			path:           "/orders/2",
+			wantStatusCode: http.StatusConflict,
+		},
+		{
+			name:           "Test_handleOrder_Delete_UnknownOrder_NotFound",
+			method:         http.MethodDelete,
+			path:           "/orders/3",
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name:           "Test_handleOrder_Put_MethodNotAllowed",
+			method:         http.MethodPut,
+			path:           "/orders/1",
+			wantStatusCode: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if recorder.Code != tt.wantStatusCode {
+				t.Errorf("handleOrder(), got status %d, want %d", recorder.Code, tt.wantStatusCode)
+			}
+
+			select {
+			case orderId := <-eventBus.CancelPickupChannel:
+				if !tt.wantCancelled {
+					t.Errorf("handleOrder(), got pickup of order %s cancelled, want none", orderId)
+				}
+			default:
+				if tt.wantCancelled {
+					t.Errorf("handleOrder(), no pickup cancelled, want the pickup of %s cancelled", tt.path)
+				}
+			}
+		})
+	}
+}
+
+func Test_handleMetrics(t *testing.T) {
+	handler, _, _ := newTestHandler()
+
+	tests := []struct {
+		name           string
+		method         string
+		wantStatusCode int
+		wantBody       string
+	}{
+		{
+			name:           "Test_handleMetrics_Get_MetricsWritten",
+			method:         http.MethodGet,
+			wantStatusCode: http.StatusOK,
+			wantBody:       "# HELP test_gauge Test gauge.\n# TYPE test_gauge gauge\ntest_gauge 1\n",
+		},
+		{
+			name:           "Test_handleMetrics_Post_MethodNotAllowed",
+			method:         http.MethodPost,
+			wantStatusCode: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(tt.method, "/metrics", nil))
+
+			if recorder.Code != tt.wantStatusCode {
+				t.Fatalf("handleMetrics(), got status %d, want %d", recorder.Code, tt.wantStatusCode)
+			}
+			if tt.wantBody != "" && recorder.Body.String() != tt.wantBody {
+				t.Errorf("handleMetrics(), got body %q, want %q", recorder.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func Test_handleShelves(t *testing.T) {
+	handler, _, _ := newTestHandler()
+
+	tests := []struct {
+		name           string
+		method         string
+		wantStatusCode int
+		wantShelves    []string
+	}{
+		{
+			name:           "Test_handleShelves_Get_EveryShelfDescribed",
+			method:         http.MethodGet,
+			wantStatusCode: http.StatusOK,
+			wantShelves:    []string{model.HOT, model.COLD, model.FROZEN, model.OVERFLOW},
+		},
+		{
+			name:           "Test_handleShelves_Post_MethodNotAllowed",
+			method:         http.MethodPost,
+			wantStatusCode: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(tt.method, "/shelves", nil))
+
+			if recorder.Code != tt.wantStatusCode {
+				t.Fatalf("handleShelves(), got status %d, want %d", recorder.Code, tt.wantStatusCode)
+			}
+			if tt.wantShelves == nil {
+				return
+			}
+
+			response := shelvesResponse{}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+				t.Fatalf("handleShelves(), got body %s, want shelves: %s", recorder.Body.String(), err)
+			}
+
+			shelves := []string{}
+			for _, shelf := range response.Shelves {
+				shelves = append(shelves, shelf.Shelf)
+			}
+			if !reflect.DeepEqual(shelves, tt.wantShelves) {
+				t.Errorf("handleShelves(), got shelves %v, want %v", shelves, tt.wantShelves)
+			}
+		})
+	}
+}
+
+func Test_handleEvents(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		query          string
+		wantStatusCode int
+		wantEvents     []string
+	}{
+		{
+			name:           "Test_handleEvents_HotOrders_StreamsTheirEvents",
+			method:         http.MethodGet,
+			query:          "?temp=hot",
+			wantStatusCode: http.StatusOK,
+			wantEvents:     []string{model.ORDER_RECEIVED + ":1", model.ORDER_COOKING + ":1"},
+		},
+		{
+			name:           "Test_handleEvents_CommaSeparatedAndRepeatedParams_StreamsEventsSelected",
+			method:         http.MethodGet,
+			query:          "?orderId=1,2&orderId=3&status=" + model.ORDER_COOKING,
+			wantStatusCode: http.StatusOK,
+			wantEvents:     []string{model.ORDER_COOKING + ":1", model.ORDER_COOKING + ":2"},
+		},
+		{
+			name:           "Test_handleEvents_Post_MethodNotAllowed",
+			method:         http.MethodPost,
+			wantStatusCode: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, eventBus, report := newTestHandler()
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			request, _ := http.NewRequest(tt.method, server.URL+"/events"+tt.query, nil)
+			response, err := http.DefaultClient.Do(request)
+			if err != nil {
+				t.Fatalf("handleEvents(), got error %s", err)
+			}
+			defer response.Body.Close()
+
+			if response.StatusCode != tt.wantStatusCode {
+				t.Fatalf("handleEvents(), got status %d, want %d", response.StatusCode, tt.wantStatusCode)
+			}
+			if tt.wantEvents == nil {
+				return
+			}
+			if contentType := response.Header.Get("Content-Type"); contentType != "text/event-stream" {
+				t.Errorf("handleEvents(), got content type '%s', want 'text/event-stream'", contentType)
+			}
+
+			// The stream is subscribed once its headers are received
+			report.Accept([]model.Order{{ID: "1", Temp: model.HOT}, {ID: "2", Temp: model.COLD}})
+			for _, status := range []string{model.ORDER_RECEIVED, model.ORDER_COOKING} {
+				eventBus.ReportStatus(model.OrderStatus{OrderId: "1", Status: status, Time: time.Now()})
+				eventBus.ReportStatus(model.OrderStatus{OrderId: "2", Status: status, Time: time.Now()})
+			}
+
+			events := []string{}
+			scanner := bufio.NewScanner(response.Body)
+			name := ""
+			for len(events) < len(tt.wantEvents) && scanner.Scan() {
+				line := scanner.Text()
+				switch {
+				case strings.HasPrefix(line, "event: "):
+					name = strings.TrimPrefix(line, "event: ")
+				case strings.HasPrefix(line, "data: "):
+					event := model.OrderEvent{}
+					if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+						t.Fatalf("handleEvents(), got data %s, want an order event: %s", line, err)
+					}
+					if event.Status != name {
+						t.Errorf("handleEvents(), got event '%s' with status '%s', want the same", name, event.Status)
+					}
+					events = append(events, event.Status+":"+event.OrderId)
+				}
+			}
+			if !reflect.DeepEqual(events, tt.wantEvents) {
+				t.Errorf("handleEvents(), got events %v, want %v", events, tt.wantEvents)
+			}
+
+			// The stream ends once the subscriptions are closed
+			report.CloseSubscriptions()
+			for scanner.Scan() {
+			}
+		})
+	}
+}
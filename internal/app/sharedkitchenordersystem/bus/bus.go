@@ -0,0 +1,118 @@
+package bus
+
+import (
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/clock"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/model"
+)
+
+// Bus carries the events exchanged by the services of a single kitchen
+type Bus struct {
+	// Clock of the kitchen; every message sent on the bus is a unit of work until its receiver handled it
+	Clock clock.Clock
+
+	// Order status events reported to the supervisor
+	SupervisorChannel chan model.OrderStatus
+
+	// Batches of orders to cook
+	KitchenChannel chan []model.Order
+
+	// Orders ready for a courier
+	DispatchChannel chan model.Order
+
+	// Cooked orders to store on a shelf
+	StorageChannel chan model.ShelfItem
+
+	// Temperatures of the shelves with new space available
+	NewSpaceAvailableChannel chan string
+
+	// Orders to store on the overflow shelf
+	OverflownChannel chan model.ShelfItem
+
+	// Ids of the orders no courier is to pick up anymore: cancelled, expired or evicted
+	CancelPickupChannel chan string
+}
+
+// New instantiates channels for Kicthen ,Dispatch, Storage, Supervisor, NewSpaceAvailable, Overflown and CancelPickup
+// events
+func New(noOfOrdersToRead int, kitchenClock clock.Clock) *Bus {
+	return &Bus{
+		Clock: kitchenClock,
+
+		SupervisorChannel: make(chan model.OrderStatus, noOfOrdersToRead),
+
+		KitchenChannel:  make(chan []model.Order, noOfOrdersToRead),
+		DispatchChannel: make(chan model.Order, noOfOrdersToRead),
+		StorageChannel:  make(chan model.ShelfItem, noOfOrdersToRead),
+
+		NewSpaceAvailableChannel: make(chan string, noOfOrdersToRead),
+		OverflownChannel:         make(chan model.ShelfItem, noOfOrdersToRead),
+		CancelPickupChannel:      make(chan string, noOfOrdersToRead),
+	}
+}
+
+// QueueDepth is the number of messages waiting on a channel of the bus and the number of messages it holds
+type QueueDepth struct {
+	Channel  string
+	Depth    int
+	Capacity int
+}
+
+// QueueDepths gives the number of messages waiting on each channel of the bus
+func (bus *Bus) QueueDepths() []QueueDepth {
+	return []QueueDepth{
+		{Channel: "supervisor", Depth: len(bus.SupervisorChannel), Capacity: cap(bus.SupervisorChannel)},
+		{Channel: "kitchen", Depth: len(bus.KitchenChannel), Capacity: cap(bus.KitchenChannel)},
+		{Channel: "dispatch", Depth: len(bus.DispatchChannel), Capacity: cap(bus.DispatchChannel)},
+		{Channel: "storage", Depth: len(bus.StorageChannel), Capacity: cap(bus.StorageChannel)},
+		{Channel: "newSpaceAvailable", Depth: len(bus.NewSpaceAvailableChannel), Capacity: cap(bus.NewSpaceAvailableChannel)},
+		{Channel: "overflown", Depth: len(bus.OverflownChannel), Capacity: cap(bus.OverflownChannel)},
+		{Channel: "cancelPickup", Depth: len(bus.CancelPickupChannel), Capacity: cap(bus.CancelPickupChannel)},
+	}
+}
+
+// ReportStatus sends an order status event to the supervisor
+func (bus *Bus) ReportStatus(status model.OrderStatus) {
+	bus.Clock.Begin()
+	bus.SupervisorChannel <- status
+}
+
+// Cook sends a batch of orders to the kitchen
+func (bus *Bus) Cook(orders []model.Order) {
+	bus.Clock.Begin()
+	bus.KitchenChannel <- orders
+}
+
+// Dispatch sends a cooked order to dispatch
+func (bus *Bus) Dispatch(order model.Order) {
+	bus.Clock.Begin()
+	bus.DispatchChannel <- order
+}
+
+// Store sends a cooked order to storage
+func (bus *Bus) Store(shelfItem model.ShelfItem) {
+	bus.Clock.Begin()
+	bus.StorageChannel <- shelfItem
+}
+
+// NewSpaceAvailable tells storage a shelf has new space available
+func (bus *Bus) NewSpaceAvailable(temp string) {
+	bus.Clock.Begin()
+	bus.NewSpaceAvailableChannel <- temp
+}
+
+// Overflow sends an order to the overflow shelf
+func (bus *Bus) Overflow(shelfItem model.ShelfItem) {
+	bus.Clock.Begin()
+	bus.OverflownChannel <- shelfItem
+}
+
+// CancelPickup tells dispatch no courier is to pick up an order anymore
+func (bus *Bus) CancelPickup(orderId string) {
+	bus.Clock.Begin()
+	bus.CancelPickupChannel <- orderId
+}
+
+// Handled tells a message received on the bus is handled
+func (bus *Bus) Handled() {
+	bus.Clock.Done()
+}
@@ -0,0 +1,217 @@
+package clock
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// Clock tells the time to the services of a kitchen and fires their timers. It also keeps count of the work in
+// progress (messages on the bus, fired timers being handled), so a virtual clock only moves forward once the
+// kitchen is idle
+type Clock interface {
+	// Now gives the current time
+	Now() time.Time
+
+	// NewTimer creates a timer firing once the duration elapsed. A fired timer is a unit of work, released with
+	// Done once handled
+	NewTimer(d time.Duration) Timer
+
+	// Begin records a unit of work in progress
+	Begin()
+
+	// Done records a unit of work is finished
+	Done()
+}
+
+// Timer fires once on its channel
+type Timer interface {
+	// C gives the channel the timer fires on
+	C() <-chan time.Time
+
+	// Stop stops the timer; if it already fired and was not received, its unit of work is released
+	Stop()
+}
+
+// Sleep waits for the duration to elapse on the clock
+func Sleep(clock Clock, d time.Duration) {
+	<-clock.NewTimer(d).C()
+	clock.Done()
+}
+
+// WallClock is the real time clock; it does not need to keep count of the work in progress
+type WallClock struct{}
+
+// NewWallClock creates a real time clock
+func NewWallClock() *WallClock {
+	return &WallClock{}
+}
+
+func (clock *WallClock) Now() time.Time {
+	return time.Now()
+}
+
+func (clock *WallClock) NewTimer(d time.Duration) Timer {
+	return &wallTimer{timer: time.NewTimer(d)}
+}
+
+func (clock *WallClock) Begin() {}
+
+func (clock *WallClock) Done() {}
+
+type wallTimer struct {
+	timer *time.Timer
+}
+
+func (timer *wallTimer) C() <-chan time.Time {
+	return timer.timer.C
+}
+
+func (timer *wallTimer) Stop() {
+	timer.timer.Stop()
+}
+
+// VirtualClock is a simulated clock. Time stands still while there is work in progress; once the kitchen is idle
+// it jumps to the earliest timer and fires it, one timer at a time, so runs are fast and reproducible
+type VirtualClock struct {
+	locker sync.Mutex
+	now    time.Time
+
+	// Number of units of work in progress
+	working int
+
+	timers timerQueue
+
+	// Number of timers created, to fire timers with the same deadline in creation order
+	created int64
+}
+
+// NewVirtualClock creates a virtual clock starting at the given time
+func NewVirtualClock(start time.Time) *VirtualClock {
+	return &VirtualClock{now: start, timers: timerQueue{}}
+}
+
+func (clock *VirtualClock) Now() time.Time {
+	clock.locker.Lock()
+	defer clock.locker.Unlock()
+
+	return clock.now
+}
+
+func (clock *VirtualClock) NewTimer(d time.Duration) Timer {
+	clock.locker.Lock()
+	defer clock.locker.Unlock()
+
+	clock.created++
+	timer := &virtualTimer{
+		clock:    clock,
+		deadline: clock.now.Add(d),
+		seq:      clock.created,
+		fired:    make(chan time.Time, 1),
+	}
+	heap.Push(&clock.timers, timer)
+
+	clock.advance()
+	return timer
+}
+
+func (clock *VirtualClock) Begin() {
+	clock.locker.Lock()
+	defer clock.locker.Unlock()
+
+	clock.working++
+}
+
+func (clock *VirtualClock) Done() {
+	clock.locker.Lock()
+	defer clock.locker.Unlock()
+
+	if clock.working > 0 {
+		clock.working--
+	}
+	clock.advance()
+}
+
+// advance fires the earliest timer if there is no work in progress. Callers hold the lock
+func (clock *VirtualClock) advance() {
+	if clock.working > 0 || clock.timers.Len() == 0 {
+		return
+	}
+
+	timer := heap.Pop(&clock.timers).(*virtualTimer)
+	if timer.deadline.After(clock.now) {
+		clock.now = timer.deadline
+	}
+
+	// The fired timer is handled as work in progress until its receiver is done
+	clock.working++
+	timer.fired <- clock.now
+}
+
+type virtualTimer struct {
+	clock    *VirtualClock
+	deadline time.Time
+	seq      int64
+	fired    chan time.Time
+
+	// The index of the timer in the heap; -1 once fired or stopped
+	index int
+}
+
+func (timer *virtualTimer) C() <-chan time.Time {
+	return timer.fired
+}
+
+func (timer *virtualTimer) Stop() {
+	clock := timer.clock
+	clock.locker.Lock()
+	defer clock.locker.Unlock()
+
+	if timer.index >= 0 {
+		heap.Remove(&clock.timers, timer.index)
+		return
+	}
+
+	select {
+	case <-timer.fired:
+		if clock.working > 0 {
+			clock.working--
+		}
+		clock.advance()
+	default:
+	}
+}
+
+// timerQueue implements heap.Interface and holds the pending timers, earliest deadline first
+type timerQueue []*virtualTimer
+
+func (queue timerQueue) Len() int { return len(queue) }
+
+func (queue timerQueue) Less(i, j int) bool {
+	if queue[i].deadline.Equal(queue[j].deadline) {
+		return queue[i].seq < queue[j].seq
+	}
+	return queue[i].deadline.Before(queue[j].deadline)
+}
+
+func (queue timerQueue) Swap(i, j int) {
+	queue[i], queue[j] = queue[j], queue[i]
+	queue[i].index = i
+	queue[j].index = j
+}
+
+func (queue *timerQueue) Push(x interface{}) {
+	timer := x.(*virtualTimer)
+	timer.index = len(*queue)
+	*queue = append(*queue, timer)
+}
+
+func (queue *timerQueue) Pop() interface{} {
+	old := *queue
+	n := len(old)
+	timer := old[n-1]
+	old[n-1] = nil
+	timer.index = -1
+	*queue = old[0 : n-1]
+	return timer
+}
@@ -0,0 +1,126 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+var start = time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+func TestVirtualClock_NewTimer(t *testing.T) {
+	tests := []struct {
+		name      string
+		durations []time.Duration
+		wantOrder []int
+	}{
+		{
+			name:      "TestVirtualClock_NewTimer_EarliestDeadline_FiresFirst",
+			durations: []time.Duration{3 * time.Second, time.Second, 2 * time.Second},
+			wantOrder: []int{1, 2, 0},
+		},
+		{
+			name:      "TestVirtualClock_NewTimer_SameDeadline_FiresInCreationOrder",
+			durations: []time.Duration{time.Second, time.Second, 0},
+			wantOrder: []int{2, 0, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := NewVirtualClock(start)
+
+			// Hold the clock until every timer is created
+			clock.Begin()
+			timers := []Timer{}
+			for _, d := range tt.durations {
+				timers = append(timers, clock.NewTimer(d))
+			}
+			clock.Done()
+
+			for _, i := range tt.wantOrder {
+				select {
+				case now := <-timers[i].C():
+					if want := start.Add(tt.durations[i]); !now.Equal(want) {
+						t.Errorf("NewTimer(), got timer %d fired at %s, want %s", i, now, want)
+					}
+				case <-time.After(time.Second):
+					t.Fatalf("NewTimer(), timer %d did not fire", i)
+				}
+
+				for j, timer := range timers {
+					select {
+					case <-timer.C():
+						t.Fatalf("NewTimer(), got timer %d fired while timer %d is handled", j, i)
+					default:
+					}
+				}
+				clock.Done()
+			}
+		})
+	}
+}
+
+func TestVirtualClock_Begin(t *testing.T) {
+	clock := NewVirtualClock(start)
+
+	clock.Begin()
+	timer := clock.NewTimer(time.Second)
+
+	select {
+	case <-timer.C():
+		t.Fatalf("NewTimer(), got timer fired while work is in progress, want time to stand still")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	clock.Done()
+	select {
+	case <-timer.C():
+		if now := clock.Now(); !now.Equal(start.Add(time.Second)) {
+			t.Errorf("Now(), got %s, want %s", now, start.Add(time.Second))
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("NewTimer(), timer did not fire once the work is done")
+	}
+}
+
+func TestVirtualClock_Stop(t *testing.T) {
+	clock := NewVirtualClock(start)
+
+	clock.Begin()
+	stopped := clock.NewTimer(time.Second)
+	fired := clock.NewTimer(2 * time.Second)
+	stopped.Stop()
+	clock.Done()
+
+	select {
+	case now := <-fired.C():
+		if !now.Equal(start.Add(2 * time.Second)) {
+			t.Errorf("Stop(), got next timer fired at %s, want %s", now, start.Add(2*time.Second))
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Stop(), next timer did not fire")
+	}
+	clock.Done()
+
+	// A fired timer stopped before it is received releases its unit of work
+	clock.Begin()
+	unreceived := clock.NewTimer(0)
+	next := clock.NewTimer(time.Second)
+	clock.Done()
+
+	for len(unreceived.(*virtualTimer).fired) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case <-next.C():
+		t.Fatalf("Stop(), got next timer fired while the fired timer is not handled")
+	default:
+	}
+	unreceived.Stop()
+
+	select {
+	case <-next.C():
+	case <-time.After(time.Second):
+		t.Fatalf("Stop(), got clock held by the stopped timer, want next timer fired")
+	}
+}
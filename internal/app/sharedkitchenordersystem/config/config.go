@@ -0,0 +1,273 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/model"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ShelfConfig describes a shelf. A shelf accepting a single temperature is the temperature controlled
+// shelf for it; the shelf accepting more than one temperature is the overflow shelf
+type ShelfConfig struct {
+	Name string `yaml:"name"`
+
+	Temperatures []string `yaml:"temperatures"`
+
+	// Max number of items the shelf can hold
+	Capacity int `yaml:"capacity"`
+
+	// Factor applied to the decay rate of the items on the shelf
+	DecayModifier float32 `yaml:"decayModifier"`
+}
+
+// CourierConfig describes the courier fleet: the number of couriers, the range of time (seconds) a courier takes to
+// arrive once assigned an order and the time (seconds) it takes to deliver the order before it is available again
+type CourierConfig struct {
+	MinDelayS int `yaml:"minDelay"`
+	MaxDelayS int `yaml:"maxDelay"`
+	FleetSize int `yaml:"fleetSize"`
+	DeliveryS int `yaml:"deliveryTime"`
+}
+
+// KitchenConfig describes the cooking stations: the number of stations cooking an order each, the number of orders
+// waiting for a station and the time (seconds) an order takes to cook, by dish name, else by temperature, else the
+// default cook time
+type KitchenConfig struct {
+	Stations  int `yaml:"stations"`
+	QueueSize int `yaml:"queueSize"`
+
+	CookTimeS              int            `yaml:"cookTime"`
+	CookTimeByTemperatureS map[string]int `yaml:"cookTimeByTemperature"`
+	CookTimeByDishS        map[string]int `yaml:"cookTimeByDish"`
+}
+
+// Eviction policies choosing the order taken off a full overflow shelf to make room for an incoming order
+const EVICT_RANDOM string = "random"
+const EVICT_LOWEST_VALUE string = "lowestValue"
+const EVICT_SOONEST_EXPIRY string = "soonestExpiry"
+const EVICT_OLDEST string = "oldest"
+const EVICT_RELOCATE string = "relocate"
+
+// EvictionPolicies lists the eviction policies an overflow shelf can be configured with
+var EvictionPolicies = []string{EVICT_RANDOM, EVICT_LOWEST_VALUE, EVICT_SOONEST_EXPIRY, EVICT_OLDEST, EVICT_RELOCATE}
+
+// Dispatch strategies matching the couriers arrived at the kitchen with the orders ready
+const DISPATCH_MATCHED string = "matched"
+const DISPATCH_FIFO string = "fifo"
+
+// DispatchStrategies lists the dispatch strategies couriers can be configured with
+var DispatchStrategies = []string{DISPATCH_MATCHED, DISPATCH_FIFO}
+
+// PersistenceConfig describes where and how often the state of the kitchen is saved, to be restored when it restarts
+type PersistenceConfig struct {
+	// File the state is saved to and restored from; the state is not saved if empty
+	Path string `yaml:"path"`
+
+	// Time (seconds) between two saves while running; the state is also saved when stopping
+	IntervalS int `yaml:"interval"`
+}
+
+// Config is the kitchen layout and timings
+type Config struct {
+	Shelves []ShelfConfig `yaml:"shelves"`
+
+	Kitchen KitchenConfig `yaml:"kitchen"`
+
+	Courier CourierConfig `yaml:"courier"`
+
+	// Strategy matching the couriers with the orders ready, one of DispatchStrategies
+	DispatchStrategy string `yaml:"dispatchStrategy"`
+
+	// Policy choosing the order taken off a full overflow shelf, one of EvictionPolicies
+	EvictionPolicy string `yaml:"evictionPolicy"`
+
+	// Time (seconds) between two scans moving orders from the overflow shelf back to temperature controlled shelves
+	// with free capacity; 0 disables the scans
+	RebalanceIntervalS int `yaml:"rebalanceInterval"`
+
+	Persistence PersistenceConfig `yaml:"persistence"`
+
+	// Time (seconds) between two checks logging the contents of every shelf if they changed since the last log; 0
+	// disables the log
+	ShelvesLogIntervalS int `yaml:"shelvesLogInterval"`
+
+	// Time (seconds) without any activity after which the supervisor reports the kitchen idle
+	IdleTimeoutS int `yaml:"idleTimeout"`
+
+	// Time (seconds) given to in-flight orders to be picked up, expire or get evicted when shutting down
+	ShutdownTimeoutS int `yaml:"shutdownTimeout"`
+}
+
+// Default gives the configuration used when no config file is supplied
+func Default() *Config {
+	return &Config{
+		Shelves: []ShelfConfig{
+			{Name: "Hot shelf", Temperatures: []string{model.HOT}, Capacity: 10, DecayModifier: 1},
+			{Name: "Cold shelf", Temperatures: []string{model.COLD}, Capacity: 10, DecayModifier: 1},
+			{Name: "Frozen shelf", Temperatures: []string{model.FROZEN}, Capacity: 10, DecayModifier: 1},
+			{Name: "Overflow shelf", Temperatures: []string{model.HOT, model.COLD, model.FROZEN}, Capacity: 15, DecayModifier: 2},
+		},
+		Kitchen:             KitchenConfig{Stations: 4, QueueSize: 50},
+		Courier:             CourierConfig{MinDelayS: 2, MaxDelayS: 6, FleetSize: 10},
+		DispatchStrategy:    DISPATCH_MATCHED,
+		EvictionPolicy:      EVICT_RANDOM,
+		RebalanceIntervalS:  1,
+		Persistence:         PersistenceConfig{IntervalS: 5},
+		ShelvesLogIntervalS: 1,
+		IdleTimeoutS:        10,
+		ShutdownTimeoutS:    30,
+	}
+}
+
+// Load reads the configuration from a YAML file. Settings missing from the file keep their default values
+func Load(path string) (*Config, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := Default()
+	if err := yaml.UnmarshalStrict(content, cfg); err != nil {
+		return nil, errors.New(fmt.Sprintf("Config: Invalid config file '%s': %s", path, err))
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// Validate checks the shelves layout and timings are consistent
+func (cfg *Config) Validate() error {
+	temperatures := make(map[string]bool)
+	var overflow *ShelfConfig
+
+	for i, shelf := range cfg.Shelves {
+		if len(shelf.Temperatures) == 0 {
+			return errors.New(fmt.Sprintf("Config: Shelf '%s' accepts no temperatures", shelf.Name))
+		}
+
+		if shelf.Capacity <= 0 {
+			return errors.New(fmt.Sprintf("Config: Shelf '%s' capacity must be positive, got %d", shelf.Name, shelf.Capacity))
+		}
+
+		if shelf.DecayModifier <= 0 {
+			return errors.New(fmt.Sprintf("Config: Shelf '%s' decay modifier must be positive, got %.2f", shelf.Name, shelf.DecayModifier))
+		}
+
+		if len(shelf.Temperatures) > 1 {
+			if overflow != nil {
+				return errors.New(fmt.Sprintf("Config: Only one overflow shelf allowed, got '%s' and '%s'", overflow.Name, shelf.Name))
+			}
+			overflow = &cfg.Shelves[i]
+			continue
+		}
+
+		if temperatures[shelf.Temperatures[0]] {
+			return errors.New(fmt.Sprintf("Config: More than one shelf accepts temperature '%s'", shelf.Temperatures[0]))
+		}
+		temperatures[shelf.Temperatures[0]] = true
+	}
+
+	if len(temperatures) == 0 {
+		return errors.New("Config: No temperature controlled shelves configured")
+	}
+
+	if overflow == nil {
+		return errors.New("Config: No overflow shelf configured; it must accept more than one temperature")
+	}
+
+	accepted := make(map[string]bool)
+	for _, temp := range overflow.Temperatures {
+		accepted[temp] = true
+	}
+	for temp := range temperatures {
+		if !accepted[temp] {
+			return errors.New(fmt.Sprintf("Config: Overflow shelf '%s' does not accept temperature '%s'", overflow.Name, temp))
+		}
+	}
+
+	if cfg.Kitchen.Stations <= 0 {
+		return errors.New(fmt.Sprintf("Config: Kitchen stations must be positive, got %d", cfg.Kitchen.Stations))
+	}
+
+	if cfg.Kitchen.QueueSize <= 0 {
+		return errors.New(fmt.Sprintf("Config: Kitchen queue size must be positive, got %d", cfg.Kitchen.QueueSize))
+	}
+
+	if cfg.Kitchen.CookTimeS < 0 {
+		return errors.New(fmt.Sprintf("Config: Cook time must not be negative, got %d", cfg.Kitchen.CookTimeS))
+	}
+
+	for temp, cookTimeS := range cfg.Kitchen.CookTimeByTemperatureS {
+		if !temperatures[temp] {
+			return errors.New(fmt.Sprintf("Config: Cook time given for temperature '%s' no shelf accepts", temp))
+		}
+
+		if cookTimeS < 0 {
+			return errors.New(fmt.Sprintf("Config: Cook time of temperature '%s' must not be negative, got %d", temp, cookTimeS))
+		}
+	}
+
+	for dish, cookTimeS := range cfg.Kitchen.CookTimeByDishS {
+		if cookTimeS < 0 {
+			return errors.New(fmt.Sprintf("Config: Cook time of dish '%s' must not be negative, got %d", dish, cookTimeS))
+		}
+	}
+
+	if cfg.Courier.MinDelayS < 0 || cfg.Courier.MaxDelayS < cfg.Courier.MinDelayS {
+		return errors.New(fmt.Sprintf("Config: Invalid courier delay range %d-%d(s)", cfg.Courier.MinDelayS, cfg.Courier.MaxDelayS))
+	}
+
+	if cfg.Courier.FleetSize <= 0 {
+		return errors.New(fmt.Sprintf("Config: Courier fleet size must be positive, got %d", cfg.Courier.FleetSize))
+	}
+
+	if cfg.Courier.DeliveryS < 0 {
+		return errors.New(fmt.Sprintf("Config: Courier delivery time must not be negative, got %d", cfg.Courier.DeliveryS))
+	}
+
+	if !isOneOf(cfg.DispatchStrategy, DispatchStrategies) {
+		return errors.New(fmt.Sprintf("Config: Dispatch strategy '%s' must be one of %s", cfg.DispatchStrategy, strings.Join(DispatchStrategies, ", ")))
+	}
+
+	if !isOneOf(cfg.EvictionPolicy, EvictionPolicies) {
+		return errors.New(fmt.Sprintf("Config: Eviction policy '%s' must be one of %s", cfg.EvictionPolicy, strings.Join(EvictionPolicies, ", ")))
+	}
+
+	if cfg.RebalanceIntervalS < 0 {
+		return errors.New(fmt.Sprintf("Config: Rebalance interval must not be negative, got %d", cfg.RebalanceIntervalS))
+	}
+
+	if cfg.Persistence.IntervalS <= 0 {
+		return errors.New(fmt.Sprintf("Config: Persistence interval must be positive, got %d", cfg.Persistence.IntervalS))
+	}
+
+	if cfg.ShelvesLogIntervalS < 0 {
+		return errors.New(fmt.Sprintf("Config: Shelves log interval must not be negative, got %d", cfg.ShelvesLogIntervalS))
+	}
+
+	if cfg.IdleTimeoutS <= 0 {
+		return errors.New(fmt.Sprintf("Config: Idle timeout must be positive, got %d", cfg.IdleTimeoutS))
+	}
+
+	if cfg.ShutdownTimeoutS < 0 {
+		return errors.New(fmt.Sprintf("Config: Shutdown timeout must not be negative, got %d", cfg.ShutdownTimeoutS))
+	}
+
+	return nil
+}
+
+func isOneOf(name string, names []string) bool {
+	for _, candidate := range names {
+		if candidate == name {
+			return true
+		}
+	}
+	return false
+}
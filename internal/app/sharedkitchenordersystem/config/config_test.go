@@ -0,0 +1,151 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name         string
+		content      string
+		wantErr      bool
+		wantShelves  int
+		wantMaxDelay int
+	}{
+		{
+			name: "TestLoad_CustomLayout_Loaded",
+			content: `
+shelves:
+  - {name: Hot shelf, temperatures: [hot], capacity: 4, decayModifier: 1}
+  - {name: Cold shelf, temperatures: [cold], capacity: 6, decayModifier: 1}
+  - {name: Overflow shelf, temperatures: [hot, cold], capacity: 8, decayModifier: 3}
+courier: {minDelay: 1, maxDelay: 3}
+`,
+			wantShelves:  3,
+			wantMaxDelay: 3,
+		},
+		{
+			name:         "TestLoad_OnlyCourier_KeepsDefaultShelves",
+			content:      `courier: {minDelay: 2, maxDelay: 9}`,
+			wantShelves:  4,
+			wantMaxDelay: 9,
+		},
+		{
+			name: "TestLoad_NoOverflowShelf_Error",
+			content: `
+shelves:
+  - {name: Hot shelf, temperatures: [hot], capacity: 4, decayModifier: 1}
+`,
+			wantErr: true,
+		},
+		{
+			name: "TestLoad_OverflowMissingTemperature_Error",
+			content: `
+shelves:
+  - {name: Hot shelf, temperatures: [hot], capacity: 4, decayModifier: 1}
+  - {name: Cold shelf, temperatures: [cold], capacity: 4, decayModifier: 1}
+  - {name: Overflow shelf, temperatures: [hot, frozen], capacity: 8, decayModifier: 2}
+`,
+			wantErr: true,
+		},
+		{
+			name:         "TestLoad_CookTimes_Loaded",
+			content:      `kitchen: {stations: 2, queueSize: 10, cookTime: 3, cookTimeByTemperature: {frozen: 0}, cookTimeByDish: {Pizza: 8}}`,
+			wantShelves:  4,
+			wantMaxDelay: 6,
+		},
+		{
+			name:    "TestLoad_NoKitchenStations_Error",
+			content: `kitchen: {stations: 0}`,
+			wantErr: true,
+		},
+		{
+			name:    "TestLoad_CookTimeUnknownTemperature_Error",
+			content: `kitchen: {cookTimeByTemperature: {warm: 2}}`,
+			wantErr: true,
+		},
+		{
+			name:    "TestLoad_NegativeDishCookTime_Error",
+			content: `kitchen: {cookTimeByDish: {Pizza: -1}}`,
+			wantErr: true,
+		},
+		{
+			name:    "TestLoad_InvalidCourierRange_Error",
+			content: `courier: {minDelay: 5, maxDelay: 2}`,
+			wantErr: true,
+		},
+		{
+			name:    "TestLoad_UnknownDispatchStrategy_Error",
+			content: `dispatchStrategy: lifo`,
+			wantErr: true,
+		},
+		{
+			name:    "TestLoad_UnknownEvictionPolicy_Error",
+			content: `evictionPolicy: newest`,
+			wantErr: true,
+		},
+		{
+			name:    "TestLoad_NegativeRebalanceInterval_Error",
+			content: `rebalanceInterval: -1`,
+			wantErr: true,
+		},
+		{
+			name:    "TestLoad_NegativeShelvesLogInterval_Error",
+			content: `shelvesLogInterval: -1`,
+			wantErr: true,
+		},
+		{
+			name:    "TestLoad_NonPositivePersistenceInterval_Error",
+			content: `persistence: {path: state.json, interval: 0}`,
+			wantErr: true,
+		},
+		{
+			name:    "TestLoad_UnknownSetting_Error",
+			content: `shelfs: []`,
+			wantErr: true,
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, fmt.Sprintf("%d.yaml", i))
+			if err := ioutil.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := Load(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load(), got error %v, want error %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if len(cfg.Shelves) != tt.wantShelves || cfg.Courier.MaxDelayS != tt.wantMaxDelay {
+				t.Errorf("Load(), got %d shelves and max delay %d, want %d and %d", len(cfg.Shelves), cfg.Courier.MaxDelayS, tt.wantShelves, tt.wantMaxDelay)
+			}
+		})
+	}
+}
+
+func TestLoad_SampleConfig(t *testing.T) {
+	cfg, err := Load("../../../../configs/kitchen.yaml")
+	if err != nil {
+		t.Fatalf("Load(), got error %v, want none", err)
+	}
+
+	if len(cfg.Shelves) != len(Default().Shelves) {
+		t.Errorf("Load(), got %d shelves, want %d", len(cfg.Shelves), len(Default().Shelves))
+	}
+}
@@ -0,0 +1,396 @@
+package sharedkitchenordersystem
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/api"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/bus"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/clock"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/config"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/metrics"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/model"
+	repo "sharedkitchenordersystem/internal/app/sharedkitchenordersystem/repository/shelf"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/repository/state"
+	dispatchService "sharedkitchenordersystem/internal/app/sharedkitchenordersystem/service/dispatch"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/service/intake"
+	kitchenService "sharedkitchenordersystem/internal/app/sharedkitchenordersystem/service/kitchen"
+	storageService "sharedkitchenordersystem/internal/app/sharedkitchenordersystem/service/storage"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/service/supervisor"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Kitchen wires the services of a single kitchen to its own event bus and shelves, so several kitchens can run in
+// one process
+type Kitchen struct {
+	Bus        *bus.Bus
+	Shelves    *repo.Repository
+	Supervisor *supervisor.Supervisor
+	Intake     *intake.Service
+
+	// Saves the state of the kitchen every saveInterval and when stopping; nothing is saved if nil
+	Store        state.Store
+	saveInterval time.Duration
+
+	// Logs the contents of the shelves every shelvesLogInterval if they changed; nothing is logged if 0
+	shelvesLogInterval time.Duration
+
+	// Orders restored on the shelves, to be sent to dispatch once the kitchen starts
+	restored []model.Order
+
+	kitchen  *kitchenService.Service
+	storage  *storageService.Service
+	dispatch *dispatchService.Service
+
+	clock clock.Clock
+
+	stopServices   context.CancelFunc
+	stopSupervisor context.CancelFunc
+}
+
+// NewKitchen creates a kitchen with the given configuration running on the given clock. The random courier delays
+// and evictions are drawn from generators seeded with the given seed, so a run on a virtual clock is reproducible
+func NewKitchen(noOfOrdersToRead int, cfg *config.Config, kitchenClock clock.Clock, seed int64) *Kitchen {
+	eventBus := bus.New(noOfOrdersToRead, kitchenClock)
+	shelves := repo.New(cfg.Shelves)
+	kitchenSupervisor := supervisor.New(eventBus, shelves, cfg.IdleTimeoutS)
+
+	cooking := kitchenService.New(eventBus, shelves, kitchenSupervisor.Report, cfg.Kitchen)
+
+	var store state.Store
+	if cfg.Persistence.Path != "" {
+		store = state.NewFileStore(cfg.Persistence.Path)
+	}
+
+	return &Kitchen{
+		Bus:          eventBus,
+		Shelves:      shelves,
+		Supervisor:   kitchenSupervisor,
+		Intake:       intake.New(eventBus, shelves, kitchenSupervisor.Report, cooking),
+		Store:        store,
+		saveInterval: time.Duration(cfg.Persistence.IntervalS) * time.Second,
+
+		shelvesLogInterval: time.Duration(cfg.ShelvesLogIntervalS) * time.Second,
+		kitchen:            cooking,
+		storage:            storageService.New(eventBus, shelves, kitchenSupervisor.Report, cfg.EvictionPolicy, time.Duration(cfg.RebalanceIntervalS)*time.Second, rand.New(rand.NewSource(seed+1))),
+		dispatch:           dispatchService.New(eventBus, shelves, kitchenSupervisor.Report, cfg.Courier, cfg.DispatchStrategy, rand.New(rand.NewSource(seed))),
+		clock:              kitchenClock,
+	}
+}
+
+// Start starts the supervisor and the services of the kitchen
+func (k *Kitchen) Start() {
+	k.clock.Begin()
+	defer k.clock.Done()
+
+	var supervisorCtx, servicesCtx context.Context
+	supervisorCtx, k.stopSupervisor = context.WithCancel(context.Background())
+	servicesCtx, k.stopServices = context.WithCancel(context.Background())
+
+	k.Supervisor.Start(supervisorCtx)
+	k.dispatch.Start(servicesCtx)
+	k.storage.Start(servicesCtx)
+	k.kitchen.Start(servicesCtx)
+
+	// Send couriers for the orders restored on the shelves
+	for _, orderReq := range k.restored {
+		k.Bus.Dispatch(orderReq)
+	}
+	k.restored = nil
+
+	if k.Store != nil {
+		go k.saveRegularly(servicesCtx, k.clock.NewTimer(k.saveInterval))
+	}
+	if k.shelvesLogInterval > 0 {
+		go k.logShelvesRegularly(servicesCtx, k.clock.NewTimer(k.shelvesLogInterval))
+	}
+}
+
+// Stop stops the services, then the supervisor once it recorded the statuses already reported. The state of the
+// kitchen is then saved
+func (k *Kitchen) Stop() {
+	k.stopServices()
+	k.stopSupervisor()
+	k.Supervisor.Wait()
+
+	if k.Store != nil {
+		if err := k.Save(); err != nil {
+			zap.S().Errorf("Admin: Could not save kitchen state: %s", err)
+		}
+	}
+}
+
+// Save saves the orders on the shelves and the statuses reported so far. They are taken together while no order moves
+// between shelves, so an order being moved is saved on one of them
+func (k *Kitchen) Save() error {
+	snapshot := state.Snapshot{
+		Time:    k.clock.Now(),
+		Shelves: make(map[string][]model.ShelfItem),
+	}
+
+	k.Shelves.Transfer(func() {
+		snapshot.Report = k.Supervisor.Report.Snapshot()
+		for _, shelfType := range k.Shelves.ShelfTemperatures {
+			shelf, _ := k.Shelves.ShelfFactory(shelfType)
+			snapshot.Shelves[shelfType] = shelf.Items()
+		}
+		snapshot.Shelves[model.OVERFLOW] = k.Shelves.OverflowShelf.Items()
+	})
+
+	return k.Store.Save(snapshot)
+}
+
+// Restore puts the orders saved on the shelves back on them and restores the statuses reported so far; it must be
+// called before the kitchen starts. The restored orders keep decaying from the time they were cooked, and couriers
+// are sent for them once the kitchen starts. Orders cancelled, or saved on a shelf which no longer takes them, are not
+// put back but cancelled or evicted. Orders which were neither on the shelves nor completed are not tracked anymore
+func (k *Kitchen) Restore() error {
+	if k.Store == nil {
+		return nil
+	}
+
+	snapshot, isPresent, err := k.Store.Load()
+	if err != nil || !isPresent {
+		return err
+	}
+
+	report := snapshot.Report
+	if report.History == nil {
+		report.History = make(map[string][]model.OrderStatus)
+	}
+	cancelled := make(map[string]bool, len(report.Cancelled))
+	for _, orderId := range report.Cancelled {
+		cancelled[orderId] = true
+	}
+
+	// Orders saved on shelves the kitchen does not have anymore come last
+	shelfTypes := append(append([]string{}, k.Shelves.ShelfTemperatures...), model.OVERFLOW)
+	removed := []string{}
+	for shelfType := range snapshot.Shelves {
+		if _, err := k.Shelves.ShelfFactory(shelfType); err != nil && shelfType != model.OVERFLOW {
+			removed = append(removed, shelfType)
+		}
+	}
+	sort.Strings(removed)
+
+	now := k.clock.Now()
+	onShelves := make(map[string]bool)
+	for _, shelfType := range append(shelfTypes, removed...) {
+		var shelf repo.IShelf = k.Shelves.OverflowShelf
+		if shelfType != model.OVERFLOW {
+			shelf, _ = k.Shelves.ShelfFactory(shelfType)
+		}
+
+		for _, item := range snapshot.Shelves[shelfType] {
+			onShelves[item.Order.ID] = true
+			lost := model.OrderStatus{OrderId: item.Order.ID, Time: now, Shelf: shelfType}
+			if cancelled[item.Order.ID] {
+				zap.S().Infof("Admin: Order '%s' was cancelled on the '%s' shelf; it is not put back", item.Order.ID, shelfType)
+				lost.Status = model.ORDER_CANCELLED
+			} else if !restoreItem(shelf, item) {
+				zap.S().Warnf("Admin: Order '%s' saved on the '%s' shelf cannot be put back on it; evicted", item.Order.ID, shelfType)
+				lost.Status = model.ORDER_EVICTED
+			} else {
+				k.restored = append(k.restored, item.Order)
+				continue
+			}
+			report.History[item.Order.ID] = append(report.History[item.Order.ID], lost)
+		}
+	}
+
+	accepted := make([]string, 0, len(report.Accepted))
+	for _, orderId := range report.Accepted {
+		history := report.History[orderId]
+		if !onShelves[orderId] && (len(history) == 0 || !model.IsTerminal(history[len(history)-1].Status)) {
+			zap.S().Infof("Admin: Order '%s' was not on a shelf nor completed when the kitchen stopped; it is not tracked anymore", orderId)
+			continue
+		}
+		accepted = append(accepted, orderId)
+	}
+	report.Accepted = accepted
+	k.Supervisor.Report.Restore(report)
+
+	zap.S().Infof("Admin: Restored '%d' orders on the shelves from the state saved at %s", len(k.restored), snapshot.Time)
+	return nil
+}
+
+// restoreItem puts an order back on a shelf regardless of its capacity; it tells whether the shelf took it. There is
+// no shelf if the kitchen does not have it anymore
+func restoreItem(shelf repo.IShelf, item model.ShelfItem) bool {
+	if shelf == nil {
+		return false
+	}
+
+	shelf.Push(item)
+	return shelf.IsPresent(item.Order.ID)
+}
+
+// saveRegularly - worker to save the state of the kitchen every time the timer fires
+func (k *Kitchen) saveRegularly(ctx context.Context, timer clock.Timer) {
+	for {
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C():
+		}
+
+		if err := k.Save(); err != nil {
+			zap.S().Errorf("Admin: Could not save kitchen state: %s", err)
+		}
+
+		timer = k.clock.NewTimer(k.saveInterval)
+		k.clock.Done()
+	}
+}
+
+// logShelvesRegularly - worker to log the contents of every shelf every time the timer fires, if they changed since
+// they were last logged
+func (k *Kitchen) logShelvesRegularly(ctx context.Context, timer clock.Timer) {
+	logged := ""
+	for {
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case now := <-timer.C():
+			shelves := k.Shelves.Snapshot(now)
+			if contents := shelvesContents(shelves); contents != logged {
+				logShelves(now, shelves)
+				logged = contents
+			}
+		}
+
+		timer = k.clock.NewTimer(k.shelvesLogInterval)
+		k.clock.Done()
+	}
+}
+
+// shelvesContents lists the ids of the orders on every shelf
+func shelvesContents(shelves []model.ShelfSnapshot) string {
+	var contents strings.Builder
+	for _, shelf := range shelves {
+		contents.WriteString(shelf.Shelf + ":")
+		for _, item := range shelf.Items {
+			contents.WriteString(item.Order.ID + ",")
+		}
+		contents.WriteString(";")
+	}
+	return contents.String()
+}
+
+// logShelves logs the orders on every shelf with their age, remaining shelf life and value
+func logShelves(now time.Time, shelves []model.ShelfSnapshot) {
+	zap.S().Infof("Shelves: Contents at %s", now)
+	for _, shelf := range shelves {
+		items := make([]string, 0, len(shelf.Items))
+		for _, item := range shelf.Items {
+			items = append(items, fmt.Sprintf("'%s'(%s) age %ds, %ds left, value %.2f", item.Order.Name, item.Order.ID, item.AgeS, item.RemainingShelfLifeS, item.Value))
+		}
+
+		if len(items) == 0 {
+			zap.S().Infof("Shelves:   %s shelf, %d/%d orders", shelf.Shelf, len(shelf.Items), shelf.Capacity)
+			continue
+		}
+		zap.S().Infof("Shelves:   %s shelf, %d/%d orders: %s", shelf.Shelf, len(shelf.Items), shelf.Capacity, strings.Join(items, "; "))
+	}
+}
+
+// Handler creates the HTTP handler taking orders into the kitchen and serving its metrics
+func (k *Kitchen) Handler() http.Handler {
+	return api.NewHandler(k.Intake, k.Supervisor.Report, k)
+}
+
+// Collect writes the metrics of the kitchen: the orders by status, the orders on each shelf, the messages waiting on
+// the bus, the cooking stations and couriers in use, and the order wait and courier delay distributions
+func (k *Kitchen) Collect(writer *metrics.Writer) {
+	report := k.Supervisor.Report
+
+	writer.Family("kitchen_orders_total", metrics.COUNTER, "Orders which reached a status.")
+	counts := report.StatusCounts()
+	for _, status := range model.Statuses {
+		writer.Sample("kitchen_orders_total", float64(counts[status]), metrics.Label{Name: "status", Value: status})
+	}
+
+	writer.Family("kitchen_shelf_orders", metrics.GAUGE, "Orders on a shelf.")
+	for _, shelfType := range k.Shelves.ShelfTemperatures {
+		shelf, _ := k.Shelves.ShelfFactory(shelfType)
+		writer.Sample("kitchen_shelf_orders", float64(shelf.Size()), metrics.Label{Name: "shelf", Value: shelfType})
+	}
+	writer.Sample("kitchen_shelf_orders", float64(k.Shelves.OverflowShelf.Size()), metrics.Label{Name: "shelf", Value: model.OVERFLOW})
+
+	writer.Family("kitchen_shelf_capacity", metrics.GAUGE, "Orders a shelf holds.")
+	for _, shelfType := range k.Shelves.ShelfTemperatures {
+		shelf, _ := k.Shelves.ShelfFactory(shelfType)
+		writer.Sample("kitchen_shelf_capacity", float64(shelf.MaxCapacity()), metrics.Label{Name: "shelf", Value: shelfType})
+	}
+	writer.Sample("kitchen_shelf_capacity", float64(k.Shelves.OverflowShelf.MaxCapacity()), metrics.Label{Name: "shelf", Value: model.OVERFLOW})
+
+	writer.Family("kitchen_overflow_compartment_orders", metrics.GAUGE, "Orders of a temperature on the overflow shelf.")
+	for _, temp := range k.Shelves.OverflowShelf.Temperatures() {
+		writer.Sample("kitchen_overflow_compartment_orders", float64(k.Shelves.OverflowShelf.SizeOf(temp)), metrics.Label{Name: "temperature", Value: temp})
+	}
+
+	depths := k.Bus.QueueDepths()
+	writer.Family("kitchen_channel_depth", metrics.GAUGE, "Messages waiting on a channel of the event bus.")
+	for _, depth := range depths {
+		writer.Sample("kitchen_channel_depth", float64(depth.Depth), metrics.Label{Name: "channel", Value: depth.Channel})
+	}
+	writer.Family("kitchen_channel_capacity", metrics.GAUGE, "Messages a channel of the event bus holds.")
+	for _, depth := range depths {
+		writer.Sample("kitchen_channel_capacity", float64(depth.Capacity), metrics.Label{Name: "channel", Value: depth.Channel})
+	}
+
+	writer.Family("kitchen_queued_orders", metrics.GAUGE, "Orders admitted to the kitchen waiting for a cooking station.")
+	writer.Sample("kitchen_queued_orders", float64(k.kitchen.Queued()))
+
+	busy := 0
+	for _, station := range k.kitchen.Stations() {
+		if station.Order() != nil {
+			busy++
+		}
+	}
+	writer.Family("kitchen_stations", metrics.GAUGE, "Cooking stations, busy cooking an order or free.")
+	writer.Sample("kitchen_stations", float64(busy), metrics.Label{Name: "state", Value: "busy"})
+	writer.Sample("kitchen_stations", float64(len(k.kitchen.Stations())-busy), metrics.Label{Name: "state", Value: "free"})
+
+	couriers := make(map[string]int)
+	for _, courier := range k.dispatch.Fleet() {
+		status, _ := courier.Status()
+		couriers[status]++
+	}
+	writer.Family("kitchen_couriers", metrics.GAUGE, "Couriers of the fleet by status.")
+	for _, status := range []string{dispatchService.COURIER_AVAILABLE, dispatchService.COURIER_EN_ROUTE, dispatchService.COURIER_WAITING, dispatchService.COURIER_DELIVERING} {
+		writer.Sample("kitchen_couriers", float64(couriers[status]), metrics.Label{Name: "status", Value: status})
+	}
+
+	writer.Family("kitchen_order_wait_seconds", metrics.HISTOGRAM, "Time the orders picked up waited on the shelves.")
+	writer.Histogram("kitchen_order_wait_seconds", report.OrderWait)
+	writer.Family("kitchen_courier_delay_seconds", metrics.HISTOGRAM, "Time the couriers took to arrive at the kitchen once sent.")
+	writer.Histogram("kitchen_courier_delay_seconds", report.CourierDelay)
+}
+
+// Drain waits until every accepted order reached a terminal status or the timeout elapsed on the kitchen clock.
+// It tells whether every order reached a terminal status
+func (k *Kitchen) Drain(timeout time.Duration) bool {
+	deadline := k.clock.Now().Add(timeout)
+	for {
+		inFlight := k.Supervisor.Report.InFlight()
+		if inFlight <= 0 {
+			zap.S().Info("Admin: All orders reached a terminal status")
+			return true
+		}
+
+		if !k.clock.Now().Before(deadline) {
+			zap.S().Infof("Admin: Shutdown timeout of %s elapsed with '%d' orders still in flight", timeout, inFlight)
+			return false
+		}
+
+		clock.Sleep(k.clock, 100*time.Millisecond)
+	}
+}
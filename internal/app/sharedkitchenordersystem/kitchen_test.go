@@ -0,0 +1,368 @@
+package sharedkitchenordersystem
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/clock"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/config"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/metrics"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/model"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/repository/order"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/repository/state"
+	util "sharedkitchenordersystem/pkg"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKitchen_IndependentKitchens(t *testing.T) {
+	cfg := config.Default()
+	cfg.Courier = config.CourierConfig{MinDelayS: 1, MaxDelayS: 1, FleetSize: 10}
+
+	firstClock := clock.NewVirtualClock(time.Now())
+	secondClock := clock.NewVirtualClock(time.Now())
+	first := NewKitchen(10, cfg, firstClock, 1)
+	second := NewKitchen(10, cfg, secondClock, 1)
+
+	// Hold the clocks until the orders are submitted
+	firstClock.Begin()
+	secondClock.Begin()
+	first.Start()
+	second.Start()
+
+	first.Intake.Submit([]model.Order{{ID: "1", Name: "Banana Split", Temp: model.FROZEN, ShelfLife: 20, DecayRate: 0.63}})
+	second.Intake.Submit([]model.Order{
+		{ID: "1", Name: "Pizza", Temp: model.HOT, ShelfLife: 300, DecayRate: 0.45},
+		{ID: "2", Name: "Yogurt", Temp: model.COLD, ShelfLife: 263, DecayRate: 0.37},
+	})
+	firstClock.Done()
+	secondClock.Done()
+
+	if !first.Drain(5*time.Second) || !second.Drain(5*time.Second) {
+		t.Fatalf("Drain(), got orders in flight, want every order completed")
+	}
+	first.Stop()
+	second.Stop()
+
+	tests := []struct {
+		name        string
+		kitchen     *Kitchen
+		orderId     string
+		wantHistory []string
+	}{
+		{
+			name:        "TestKitchen_IndependentKitchens_FirstKitchenOrder_Picked",
+			kitchen:     first,
+			orderId:     "1",
+			wantHistory: []string{model.ORDER_RECEIVED, model.ORDER_COOKING, model.ORDER_PROCESSED, model.ORDER_STORED, model.ORDER_PICKED},
+		},
+		{
+			name:        "TestKitchen_IndependentKitchens_SecondKitchenSameOrderId_Picked",
+			kitchen:     second,
+			orderId:     "1",
+			wantHistory: []string{model.ORDER_RECEIVED, model.ORDER_COOKING, model.ORDER_PROCESSED, model.ORDER_STORED, model.ORDER_PICKED},
+		},
+		{
+			name:    "TestKitchen_IndependentKitchens_OtherKitchenOrder_NotFound",
+			kitchen: first,
+			orderId: "2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			details, err := tt.kitchen.Supervisor.Report.Lookup(tt.orderId)
+			if tt.wantHistory == nil {
+				if err == nil {
+					t.Errorf("Lookup(), got order %s with status %s, want not found", tt.orderId, details.Status)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Lookup(), got error %s, want order %s", err, tt.orderId)
+			}
+
+			if len(details.History) != len(tt.wantHistory) {
+				t.Fatalf("Lookup(), got history %v, want statuses %v", details.History, tt.wantHistory)
+			}
+			for i, status := range details.History {
+				if status.Status != tt.wantHistory[i] {
+					t.Errorf("Lookup(), got status %s at %d, want %s", status.Status, i, tt.wantHistory[i])
+				}
+			}
+		})
+	}
+}
+
+func TestKitchen_Restore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := state.NewFileStore(filepath.Join(dir, "state.json"))
+	orders := []model.Order{
+		{ID: "1", Name: "Pizza", Temp: model.HOT, ShelfLife: 300, DecayRate: 0.45},
+		{ID: "2", Name: "Yogurt", Temp: model.COLD, ShelfLife: 263, DecayRate: 0.37},
+	}
+
+	// The couriers of the first kitchen never arrive before it stops
+	cfg := config.Default()
+	cfg.Courier = config.CourierConfig{MinDelayS: 100, MaxDelayS: 100, FleetSize: 10}
+	stopped := NewKitchen(10, cfg, clock.NewWallClock(), 1)
+	stopped.Store = store
+	stopped.Start()
+	stopped.Intake.Submit(orders)
+	for _, orderReq := range orders {
+		waitForStatus(t, stopped, orderReq.ID, model.ORDER_STORED)
+	}
+	stopped.Stop()
+
+	cfg.Courier = config.CourierConfig{MinDelayS: 1, MaxDelayS: 1, FleetSize: 10}
+	virtualClock := clock.NewVirtualClock(time.Now())
+	restarted := NewKitchen(10, cfg, virtualClock, 1)
+	restarted.Store = store
+	if err := restarted.Restore(); err != nil {
+		t.Fatalf("Restore(), got error %s, want none", err)
+	}
+
+	for _, orderReq := range orders {
+		if shelfType, _, isPresent := restarted.Shelves.Locate(orderReq.ID); !isPresent || shelfType != orderReq.Temp {
+			t.Errorf("Restore(), got order %s on shelf '%s', want on shelf '%s'", orderReq.ID, shelfType, orderReq.Temp)
+		}
+	}
+
+	restarted.Start()
+	if !restarted.Drain(5 * time.Second) {
+		t.Fatalf("Drain(), got orders in flight, want every restored order completed")
+	}
+	restarted.Stop()
+
+	wantHistory := []string{model.ORDER_RECEIVED, model.ORDER_COOKING, model.ORDER_PROCESSED, model.ORDER_STORED, model.ORDER_PICKED}
+	for _, orderReq := range orders {
+		details, err := restarted.Supervisor.Report.Lookup(orderReq.ID)
+		if err != nil {
+			t.Fatalf("Lookup(), got error %s, want order %s", err, orderReq.ID)
+		}
+
+		got := []string{}
+		for _, status := range details.History {
+			got = append(got, status.Status)
+		}
+		if !reflect.DeepEqual(got, wantHistory) {
+			t.Errorf("Lookup(), got history %v for order %s, want %v", got, orderReq.ID, wantHistory)
+		}
+	}
+}
+
+func TestKitchen_Restore_OrdersNotPutBack(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := state.NewFileStore(filepath.Join(dir, "state.json"))
+	saved := time.Now()
+	item := func(id string, temp string) model.ShelfItem {
+		return model.NewShelfItem(model.Order{ID: id, Name: "Soup", Temp: temp, ShelfLife: 300, DecayRate: 0.45}, saved, 1)
+	}
+	stored := func(id string, shelf string) []model.OrderStatus {
+		return []model.OrderStatus{{OrderId: id, Status: model.ORDER_RECEIVED, Time: saved}, {OrderId: id, Status: model.ORDER_STORED, Time: saved, Shelf: shelf}}
+	}
+
+	// The overflow shelf takes no 'warm' orders and the kitchen has no 'warm' shelf anymore
+	err = store.Save(state.Snapshot{
+		Time: saved,
+		Shelves: map[string][]model.ShelfItem{
+			model.HOT:      {item("1", model.HOT)},
+			model.COLD:     {item("2", model.COLD)},
+			model.OVERFLOW: {item("3", "warm")},
+			"warm":         {item("4", "warm")},
+		},
+		Report: state.Report{
+			History:   map[string][]model.OrderStatus{"1": stored("1", model.HOT), "2": stored("2", model.COLD), "3": stored("3", model.OVERFLOW), "4": stored("4", "warm")},
+			Accepted:  []string{"1", "2", "3", "4"},
+			Cancelled: []string{"1"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kitchen := NewKitchen(10, config.Default(), clock.NewVirtualClock(saved), 1)
+	kitchen.Store = store
+	if err := kitchen.Restore(); err != nil {
+		t.Fatalf("Restore(), got error %s, want none", err)
+	}
+
+	tests := []struct {
+		name       string
+		orderId    string
+		wantStatus string
+		wantShelf  string
+	}{
+		{name: "TestKitchen_Restore_OrdersNotPutBack_Cancelled_TakenOff", orderId: "1", wantStatus: model.ORDER_CANCELLED},
+		{name: "TestKitchen_Restore_OrdersNotPutBack_Stored_PutBack", orderId: "2", wantStatus: model.ORDER_STORED, wantShelf: model.COLD},
+		{name: "TestKitchen_Restore_OrdersNotPutBack_TemperatureNotOnOverflow_Evicted", orderId: "3", wantStatus: model.ORDER_EVICTED},
+		{name: "TestKitchen_Restore_OrdersNotPutBack_ShelfRemoved_Evicted", orderId: "4", wantStatus: model.ORDER_EVICTED},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			details, err := kitchen.Supervisor.Report.Lookup(tt.orderId)
+			if err != nil || details.Status != tt.wantStatus {
+				t.Errorf("Lookup(), got status %s (error %v), want %s", details.Status, err, tt.wantStatus)
+			}
+			if shelfType, _, _ := kitchen.Shelves.Locate(tt.orderId); shelfType != tt.wantShelf {
+				t.Errorf("Restore(), got order on shelf '%s', want on shelf '%s'", shelfType, tt.wantShelf)
+			}
+		})
+	}
+
+	if len(kitchen.restored) != 1 || kitchen.restored[0].ID != "2" {
+		t.Errorf("Restore(), got orders %v to dispatch, want order 2 only", kitchen.restored)
+	}
+	if cancelled := kitchen.Supervisor.Report.Snapshot().Cancelled; !reflect.DeepEqual(cancelled, []string{"1"}) {
+		t.Errorf("Snapshot(), got orders %v cancelled, want the cancellation of order 1 restored and saved again", cancelled)
+	}
+}
+
+func TestKitchen_Collect(t *testing.T) {
+	cfg := config.Default()
+	cfg.Courier = config.CourierConfig{MinDelayS: 3, MaxDelayS: 3, FleetSize: 2}
+
+	virtualClock := clock.NewVirtualClock(time.Now())
+	kitchen := NewKitchen(10, cfg, virtualClock, 1)
+	virtualClock.Begin()
+	kitchen.Start()
+	kitchen.Intake.Submit([]model.Order{{ID: "1", Name: "Pizza", Temp: model.HOT, ShelfLife: 300, DecayRate: 0.45}})
+	virtualClock.Done()
+
+	if !kitchen.Drain(time.Minute) {
+		t.Fatalf("Drain(), got orders in flight, want every order completed")
+	}
+	kitchen.Stop()
+
+	writer := &metrics.Writer{}
+	kitchen.Collect(writer)
+	got := string(writer.Bytes())
+
+	for _, want := range []string{
+		`kitchen_orders_total{status="received"} 1`,
+		`kitchen_orders_total{status="picked"} 1`,
+		`kitchen_orders_total{status="expired"} 0`,
+		`kitchen_shelf_orders{shelf="hot"} 0`,
+		`kitchen_shelf_capacity{shelf="overflow"} 15`,
+		`kitchen_overflow_compartment_orders{temperature="frozen"} 0`,
+		`kitchen_channel_depth{channel="supervisor"} 0`,
+		`kitchen_couriers{status="available"} 2`,
+		`kitchen_order_wait_seconds_count 1`,
+		`kitchen_courier_delay_seconds_bucket{le="3"} 1`,
+		`kitchen_courier_delay_seconds_sum 3`,
+	} {
+		if !strings.Contains(got, want+"\n") {
+			t.Errorf("Collect(), got metrics without %q:\n%s", want, got)
+		}
+	}
+}
+
+// waitForStatus waits until the order reached the given status on a kitchen running on the wall clock
+func waitForStatus(t *testing.T, kitchen *Kitchen, orderId string, status string) {
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if details, err := kitchen.Supervisor.Report.Lookup(orderId); err == nil && details.Status == status {
+			return
+		}
+	}
+	t.Fatalf("Lookup(), got order %s never %s, want %s", orderId, status, status)
+}
+
+// simulate replays the sample orders on a virtual clock and gives the status history of every order
+func simulate(t *testing.T, cfg *config.Config, seed int64) map[string][]model.OrderStatus {
+	start := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	virtualClock := clock.NewVirtualClock(start)
+	kitchen := NewKitchen(2, cfg, virtualClock, seed)
+
+	virtualClock.Begin()
+	kitchen.Start()
+	source := order.NewFileSource("repository/order/orders.json", virtualClock)
+	batches, err := source.Stream(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("Stream(), got error %s, want none", err)
+	}
+	virtualClock.Done()
+
+	readOrders(context.Background(), kitchen.Intake, virtualClock, batches)
+	if !kitchen.Drain(time.Hour) {
+		t.Fatalf("Drain(), got orders in flight, want every order completed")
+	}
+	kitchen.Stop()
+
+	if elapsed := virtualClock.Now().Sub(start); elapsed < time.Minute {
+		t.Errorf("simulate(), got %s of virtual time elapsed, want the orders replayed one batch per second", elapsed)
+	}
+
+	ordersData := []model.Order{}
+	if err := util.ReadFile("repository/order/orders.json", &ordersData); err != nil {
+		t.Fatalf("ReadFile(), got error %s, want none", err)
+	}
+
+	histories := make(map[string][]model.OrderStatus)
+	for _, orderReq := range ordersData {
+		details, err := kitchen.Supervisor.Report.Lookup(orderReq.ID)
+		if err != nil {
+			t.Fatalf("Lookup(), got error %s, want order %s", err, orderReq.ID)
+		}
+		histories[orderReq.ID] = details.History
+	}
+	return histories
+}
+
+func TestKitchen_VirtualClock(t *testing.T) {
+	tests := []struct {
+		name      string
+		seed      int64
+		otherSeed int64
+		wantEqual bool
+	}{
+		{
+			name:      "TestKitchen_VirtualClock_SameSeed_IdenticalRuns",
+			seed:      42,
+			otherSeed: 42,
+			wantEqual: true,
+		},
+		{
+			name:      "TestKitchen_VirtualClock_OtherSeed_DifferentRuns",
+			seed:      42,
+			otherSeed: 7,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if isEqual := reflect.DeepEqual(simulate(t, config.Default(), tt.seed), simulate(t, config.Default(), tt.otherSeed)); isEqual != tt.wantEqual {
+				t.Errorf("simulate(), got identical runs %v, want %v", isEqual, tt.wantEqual)
+			}
+		})
+	}
+}
+
+func TestKitchen_VirtualClock_FullKitchenQueue(t *testing.T) {
+	// The kitchen cooks slower than the orders come in, so the source waits for room in the queue
+	cfg := config.Default()
+	cfg.Kitchen = config.KitchenConfig{Stations: 2, QueueSize: 4, CookTimeS: 1, CookTimeByTemperatureS: map[string]int{model.HOT: 2}}
+
+	histories := simulate(t, cfg, 7)
+	for orderId, history := range histories {
+		if status := history[len(history)-1].Status; status != model.ORDER_PICKED {
+			t.Errorf("simulate(), got order %s %s, want picked", orderId, status)
+		}
+	}
+
+	if !reflect.DeepEqual(histories, simulate(t, cfg, 7)) {
+		t.Errorf("simulate(), got different runs, want identical runs")
+	}
+}
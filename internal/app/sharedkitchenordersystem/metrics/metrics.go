@@ -0,0 +1,146 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// Types of the metric families
+const COUNTER string = "counter"
+const GAUGE string = "gauge"
+const HISTOGRAM string = "histogram"
+
+// Collector writes the current value of its metrics
+type Collector interface {
+	Collect(writer *Writer)
+}
+
+// Handler serves the metrics of the collector in the Prometheus text exposition format
+func Handler(collector Collector) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, fmt.Sprintf("Method '%s' not allowed", r.Method), http.StatusMethodNotAllowed)
+			return
+		}
+
+		writer := &Writer{}
+		collector.Collect(writer)
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if _, err := w.Write(writer.Bytes()); err != nil {
+			zap.S().Errorf("Metrics: Could not write metrics: %s", err)
+		}
+	})
+}
+
+// Label is a name and value pair identifying a sample within its metric family
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Writer writes metric families in the Prometheus text exposition format
+type Writer struct {
+	buffer bytes.Buffer
+}
+
+// Family starts a metric family; its samples are written right after it
+func (writer *Writer) Family(name string, metricType string, help string) {
+	fmt.Fprintf(&writer.buffer, "# HELP %s %s\n", name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help))
+	fmt.Fprintf(&writer.buffer, "# TYPE %s %s\n", name, metricType)
+}
+
+// Sample writes a sample of the metric family started last
+func (writer *Writer) Sample(name string, value float64, labels ...Label) {
+	writer.buffer.WriteString(name)
+	if len(labels) > 0 {
+		pairs := make([]string, 0, len(labels))
+		for _, label := range labels {
+			pairs = append(pairs, fmt.Sprintf(`%s="%s"`, label.Name, strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(label.Value)))
+		}
+		fmt.Fprintf(&writer.buffer, "{%s}", strings.Join(pairs, ","))
+	}
+	fmt.Fprintf(&writer.buffer, " %s\n", formatValue(value))
+}
+
+// Histogram writes the cumulative buckets, sum and count of a histogram as samples of the metric family started last
+func (writer *Writer) Histogram(name string, histogram *Histogram, labels ...Label) {
+	bounds, counts, sum, count := histogram.snapshot()
+
+	var cumulative uint64
+	for i, bound := range bounds {
+		cumulative += counts[i]
+		writer.Sample(name+"_bucket", float64(cumulative), append(labels, Label{Name: "le", Value: formatValue(bound)})...)
+	}
+	writer.Sample(name+"_bucket", float64(count), append(labels, Label{Name: "le", Value: formatValue(math.Inf(1))})...)
+	writer.Sample(name+"_sum", sum, labels...)
+	writer.Sample(name+"_count", float64(count), labels...)
+}
+
+// Bytes gives the metrics written so far
+func (writer *Writer) Bytes() []byte {
+	return writer.buffer.Bytes()
+}
+
+// formatValue formats a sample value or a bucket bound as Prometheus expects it
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// Histogram counts observed values in buckets of increasing upper bounds
+type Histogram struct {
+	locker sync.Mutex
+
+	// Upper bounds of the buckets, increasing; values above the last bound are only counted in total
+	bounds []float64
+
+	// Number of values observed by bucket, each value in the first bucket it does not exceed
+	counts []uint64
+
+	sum   float64
+	count uint64
+}
+
+// NewHistogram creates a histogram with buckets of the given upper bounds
+func NewHistogram(bounds ...float64) *Histogram {
+	sorted := append([]float64{}, bounds...)
+	sort.Float64s(sorted)
+	return &Histogram{bounds: sorted, counts: make([]uint64, len(sorted))}
+}
+
+// Observe counts a value in its bucket
+func (histogram *Histogram) Observe(value float64) {
+	histogram.locker.Lock()
+	defer histogram.locker.Unlock()
+
+	if i := sort.SearchFloat64s(histogram.bounds, value); i < len(histogram.bounds) {
+		histogram.counts[i]++
+	}
+	histogram.sum += value
+	histogram.count++
+}
+
+// snapshot gives the bucket bounds, the number of values by bucket, their sum and their number
+func (histogram *Histogram) snapshot() ([]float64, []uint64, float64, uint64) {
+	histogram.locker.Lock()
+	defer histogram.locker.Unlock()
+
+	return histogram.bounds, append([]uint64{}, histogram.counts...), histogram.sum, histogram.count
+}
@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"testing"
+)
+
+func TestWriter_Sample(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  float64
+		labels []Label
+		want   string
+	}{
+		{
+			name:  "TestWriter_Sample_NoLabels_ValueOnly",
+			value: 2.5,
+			want:  "kitchen_metric 2.5\n",
+		},
+		{
+			name:   "TestWriter_Sample_Labels_Written",
+			value:  3,
+			labels: []Label{{Name: "shelf", Value: "hot"}, {Name: "status", Value: "picked"}},
+			want:   "kitchen_metric{shelf=\"hot\",status=\"picked\"} 3\n",
+		},
+		{
+			name:   "TestWriter_Sample_SpecialCharacters_Escaped",
+			value:  1,
+			labels: []Label{{Name: "name", Value: "a \"b\" \\ c\nd"}},
+			want:   "kitchen_metric{name=\"a \\\"b\\\" \\\\ c\\nd\"} 1\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writer := &Writer{}
+			writer.Sample("kitchen_metric", tt.value, tt.labels...)
+
+			if got := string(writer.Bytes()); got != tt.want {
+				t.Errorf("Sample(), got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriter_Histogram(t *testing.T) {
+	histogram := NewHistogram(5, 1)
+	for _, value := range []float64{0.5, 1, 3, 10} {
+		histogram.Observe(value)
+	}
+
+	writer := &Writer{}
+	writer.Histogram("wait_seconds", histogram, Label{Name: "kitchen", Value: "1"})
+
+	// Buckets are cumulative; the values above the last bound are only in the +Inf bucket
+	want := "wait_seconds_bucket{kitchen=\"1\",le=\"1\"} 2\n" +
+		"wait_seconds_bucket{kitchen=\"1\",le=\"5\"} 3\n" +
+		"wait_seconds_bucket{kitchen=\"1\",le=\"+Inf\"} 4\n" +
+		"wait_seconds_sum{kitchen=\"1\"} 14.5\n" +
+		"wait_seconds_count{kitchen=\"1\"} 4\n"
+	if got := string(writer.Bytes()); got != want {
+		t.Errorf("Histogram(), got %q, want %q", got, want)
+	}
+}
@@ -1,10 +1,28 @@
 package model
 
+import (
+	"time"
+)
+
 const ORDER_RECEIVED string = "received"
+const ORDER_COOKING string = "cooking"
 const ORDER_PROCESSED string = "processed"
+const ORDER_STORED string = "stored"
+const ORDER_OVERFLOWN string = "overflown"
+const ORDER_PROMOTED string = "promoted"
 const ORDER_PICKED string = "picked"
 const ORDER_EXPIRED string = "expired"
 const ORDER_EVICTED string = "evicted"
+const ORDER_REJECTED string = "rejected"
+const ORDER_CANCELLED string = "cancelled"
+
+// Statuses lists the order statuses, in the order they are reached
+var Statuses = []string{ORDER_RECEIVED, ORDER_REJECTED, ORDER_COOKING, ORDER_PROCESSED, ORDER_STORED, ORDER_OVERFLOWN, ORDER_PROMOTED, ORDER_PICKED, ORDER_EXPIRED, ORDER_EVICTED, ORDER_CANCELLED}
+
+// IsTerminal tells whether an order with the given status is completed: picked up, expired, evicted or cancelled
+func IsTerminal(status string) bool {
+	return status == ORDER_PICKED || status == ORDER_EXPIRED || status == ORDER_EVICTED || status == ORDER_CANCELLED
+}
 
 // Order .
 type Order struct {
@@ -20,7 +38,34 @@ type Order struct {
 	DecayRate float32 `json:"decayRate"`
 }
 
+// OrderStatus is a transition in the lifecycle of an order, recorded with the time it happened and the shelf involved
 type OrderStatus struct {
-	Status  string
-	OrderId string
+	Status  string    `json:"status"`
+	OrderId string    `json:"orderId"`
+	Time    time.Time `json:"time"`
+	Shelf   string    `json:"shelf,omitempty"`
+}
+
+// OrderEvent is a status of an order as it is recorded, along with the temperature of the order if it was accepted
+type OrderEvent struct {
+	OrderStatus
+	Temp string `json:"temp,omitempty"`
+}
+
+// OrderDetails describes the last known state of an order along with its status history
+type OrderDetails struct {
+	OrderId string `json:"id"`
+
+	Status string `json:"status"`
+
+	History []OrderStatus `json:"history"`
+
+	// Shelf the order currently sits on, empty if it is not on any shelf
+	Shelf string `json:"shelf,omitempty"`
+
+	// Remaining shelf life (seconds) while the order is on a shelf
+	RemainingShelfLifeS int64 `json:"remainingShelfLife,omitempty"`
+
+	// Normalized value (1 when cooked, 0 once expired) while the order is on a shelf
+	Value float64 `json:"value,omitempty"`
 }
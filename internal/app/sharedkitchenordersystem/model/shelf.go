@@ -9,8 +9,93 @@ const COLD string = "cold"
 const FROZEN string = "frozen"
 const OVERFLOW string = "overflow"
 
+// Items which do not decay are considered expiring after this long, to keep them sortable
+const neverExpires time.Duration = 100 * 365 * 24 * time.Hour
+
+// ShelfItem is a cooked order. Its value decays from 1 to 0 at the decay rate of the order multiplied by the decay
+// modifier of the shelf it sits on
 type ShelfItem struct {
-	Order        Order
-	CreatedTime  time.Time
-	MaxLifeTimeS int64
+	Order       Order     `json:"order"`
+	CreatedTime time.Time `json:"createdTime"`
+
+	// Shelf life, in seconds, used up on the shelves the item visited before its current shelf
+	Decay float64 `json:"decay"`
+
+	// When the item was placed on its current shelf, and the decay modifier of that shelf
+	PlacedTime    time.Time `json:"placedTime"`
+	DecayModifier float32   `json:"decayModifier"`
+}
+
+// NewShelfItem creates an item cooked at the given time, to be placed on a shelf with the given decay modifier
+func NewShelfItem(order Order, createdTime time.Time, decayModifier float32) ShelfItem {
+	return ShelfItem{Order: order, CreatedTime: createdTime, PlacedTime: createdTime, DecayModifier: decayModifier}
+}
+
+// decay gives the shelf life, in seconds, used up at the given time
+func (item ShelfItem) decay(now time.Time) float64 {
+	return item.Decay + float64(item.Order.DecayRate)*now.Sub(item.PlacedTime).Seconds()*float64(item.DecayModifier)
+}
+
+// Value gives the normalized value of the item at the given time; 1 when cooked, 0 or less once expired
+func (item ShelfItem) Value(now time.Time) float64 {
+	if item.Order.ShelfLife <= 0 {
+		return 0
+	}
+
+	return (float64(item.Order.ShelfLife) - item.decay(now)) / float64(item.Order.ShelfLife)
+}
+
+// IsExpired tells whether the item has no value left at the given time
+func (item ShelfItem) IsExpired(now time.Time) bool {
+	return item.Value(now) <= 0
+}
+
+// ExpiresAt gives the time the item has no value left if it stays on its current shelf
+func (item ShelfItem) ExpiresAt() time.Time {
+	rate := float64(item.Order.DecayRate) * float64(item.DecayModifier)
+	remainingS := float64(item.Order.ShelfLife) - item.Decay
+	if rate <= 0 || remainingS/rate >= neverExpires.Seconds() {
+		return item.PlacedTime.Add(neverExpires)
+	}
+
+	return item.PlacedTime.Add(time.Duration(remainingS / rate * float64(time.Second)))
+}
+
+// Move gives the item placed on another shelf at the given time; the decay on its current shelf is charged first
+func (item ShelfItem) Move(now time.Time, decayModifier float32) ShelfItem {
+	item.Decay = item.decay(now)
+	item.PlacedTime = now
+	item.DecayModifier = decayModifier
+	return item
+}
+
+// ShelfItemSnapshot describes an item on a shelf at a given time
+type ShelfItemSnapshot struct {
+	Order Order `json:"order"`
+
+	// Seconds since the order was cooked
+	AgeS int64 `json:"age"`
+
+	// Seconds left before the item expires if it stays on its current shelf
+	RemainingShelfLifeS int64 `json:"remainingShelfLife"`
+
+	// Normalized value, 1 when cooked and 0 once expired
+	Value float64 `json:"value"`
+}
+
+// Snapshot describes the item at the given time
+func (item ShelfItem) Snapshot(now time.Time) ShelfItemSnapshot {
+	return ShelfItemSnapshot{
+		Order:               item.Order,
+		AgeS:                int64(now.Sub(item.CreatedTime).Seconds()),
+		RemainingShelfLifeS: int64(item.ExpiresAt().Sub(now).Seconds()),
+		Value:               item.Value(now),
+	}
+}
+
+// ShelfSnapshot describes the items on a shelf at a given time
+type ShelfSnapshot struct {
+	Shelf    string              `json:"shelf"`
+	Capacity int                 `json:"capacity"`
+	Items    []ShelfItemSnapshot `json:"items"`
 }
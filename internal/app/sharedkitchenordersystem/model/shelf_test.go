@@ -0,0 +1,79 @@
+package model
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestShelfItem_Value(t *testing.T) {
+	cooked := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	order := Order{ID: "1", Name: "chicken", Temp: HOT, ShelfLife: 18, DecayRate: 0.5}
+
+	tests := []struct {
+		name          string
+		item          ShelfItem
+		at            time.Duration
+		wantValue     float64
+		wantExpired   bool
+		wantExpiresAt time.Duration
+	}{
+		{
+			name:          "TestShelfItem_Value_JustCooked_FullValue",
+			item:          NewShelfItem(order, cooked, 1),
+			at:            0,
+			wantValue:     1,
+			wantExpiresAt: 36 * time.Second,
+		},
+		{
+			name:          "TestShelfItem_Value_Modifier_1",
+			item:          NewShelfItem(order, cooked, 1),
+			at:            9 * time.Second,
+			wantValue:     0.75,
+			wantExpiresAt: 36 * time.Second,
+		},
+		{
+			name:          "TestShelfItem_Value_Modifier_2",
+			item:          NewShelfItem(order, cooked, 2),
+			at:            9 * time.Second,
+			wantValue:     0.5,
+			wantExpiresAt: 18 * time.Second,
+		},
+		{
+			name:          "TestShelfItem_Value_MovedToOverflow_ChargesEachShelf",
+			item:          NewShelfItem(order, cooked, 1).Move(cooked.Add(12*time.Second), 2),
+			at:            15 * time.Second,
+			wantValue:     0.5,
+			wantExpiresAt: 24 * time.Second,
+		},
+		{
+			name:          "TestShelfItem_Value_MovedBackFromOverflow_ChargesEachShelf",
+			item:          NewShelfItem(order, cooked, 2).Move(cooked.Add(6*time.Second), 1),
+			at:            12 * time.Second,
+			wantValue:     0.5,
+			wantExpiresAt: 30 * time.Second,
+		},
+		{
+			name:          "TestShelfItem_Value_NoValueLeft_Expired",
+			item:          NewShelfItem(order, cooked, 2),
+			at:            18 * time.Second,
+			wantValue:     0,
+			wantExpired:   true,
+			wantExpiresAt: 18 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := cooked.Add(tt.at)
+			if got := tt.item.Value(now); math.Abs(got-tt.wantValue) > 1e-9 {
+				t.Errorf("Value() = %v, want %v", got, tt.wantValue)
+			}
+			if got := tt.item.IsExpired(now); got != tt.wantExpired {
+				t.Errorf("IsExpired() = %v, want %v", got, tt.wantExpired)
+			}
+			if got := tt.item.ExpiresAt(); !got.Equal(cooked.Add(tt.wantExpiresAt)) {
+				t.Errorf("ExpiresAt() = %s, want %s", got, cooked.Add(tt.wantExpiresAt))
+			}
+		})
+	}
+}
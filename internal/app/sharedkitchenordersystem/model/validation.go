@@ -0,0 +1,51 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidationError lists the reasons an order was rejected at intake
+type ValidationError struct {
+	// Position of the order in the batch it was received in
+	Index int `json:"index"`
+
+	OrderId string `json:"id"`
+
+	Reasons []string `json:"reasons"`
+}
+
+func (err ValidationError) Error() string {
+	return fmt.Sprintf("Order '%s' rejected: %s", err.OrderId, strings.Join(err.Reasons, "; "))
+}
+
+// Validate checks the order can be cooked and stored on one of the shelves for the given temperatures
+func (order Order) Validate(temperatures []string) []string {
+	reasons := []string{}
+
+	if strings.TrimSpace(order.ID) == "" {
+		reasons = append(reasons, "id is required")
+	}
+
+	if strings.TrimSpace(order.Name) == "" {
+		reasons = append(reasons, "name is required")
+	}
+
+	isKnownTemp := false
+	for _, temp := range temperatures {
+		isKnownTemp = isKnownTemp || order.Temp == temp
+	}
+	if !isKnownTemp {
+		reasons = append(reasons, fmt.Sprintf("temp '%s' must be one of %s", order.Temp, strings.Join(temperatures, ", ")))
+	}
+
+	if order.ShelfLife <= 0 {
+		reasons = append(reasons, fmt.Sprintf("shelfLife must be positive, got %d", order.ShelfLife))
+	}
+
+	if order.DecayRate < 0 {
+		reasons = append(reasons, fmt.Sprintf("decayRate must not be negative, got %.2f", order.DecayRate))
+	}
+
+	return reasons
+}
@@ -1,24 +1,75 @@
 package order
 
 import (
+	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
-	"runtime"
-	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/model"
-	util "sharedkitchenordersystem/pkg"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/clock"
 )
 
-var OrdersData []model.Order
+const FILE_SOURCE string = "file"
+const STDIN_SOURCE string = "stdin"
+const DIRECTORY_SOURCE string = "dir"
 
-// InitOrders initializes and reads orders data from orders json file
-func InitOrders() {
-	OrdersData = []model.Order{}
-	_, b, _, _ := runtime.Caller(0)
-	err := util.ReadFile(filepath.Dir(b)+"/orders.json", &OrdersData)
-	if err != nil {
-		fmt.Println(err.Error())
-		return
+// DefaultOrdersFile is the sample orders file replayed when no other source is given, relative to the root of the
+// repository
+const DefaultOrdersFile string = "internal/app/sharedkitchenordersystem/repository/order/orders.json"
+
+// DefaultOrdersPath finds the sample orders file from the working directory, then from the directory of the
+// executable, looking in their parent directories in turn, so it is found from anywhere in the repository or from the
+// directory the application was built in
+func DefaultOrdersPath() (string, error) {
+	if info, err := os.Stat(DefaultOrdersFile); err == nil && !info.IsDir() {
+		return DefaultOrdersFile, nil
+	}
+
+	dirs := []string{}
+	if workingDir, err := os.Getwd(); err == nil {
+		dirs = append(dirs, workingDir)
+	}
+	if executable, err := os.Executable(); err == nil {
+		dirs = append(dirs, filepath.Dir(executable))
+	}
+
+	for _, dir := range dirs {
+		for {
+			path := filepath.Join(dir, DefaultOrdersFile)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				return path, nil
+			}
+
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				break
+			}
+			dir = parent
+		}
+	}
+
+	return "", errors.New(fmt.Sprintf("Orders: Sample orders file '%s' not found from the working directory nor the executable; give the orders file with -orders", DefaultOrdersFile))
+}
+
+// NewOrderSource creates the order source of the given kind; path is the orders file for the file source
+// and the watched directory for the directory source
+func NewOrderSource(kind string, path string, sourceClock clock.Clock) (OrderSource, error) {
+	switch kind {
+	case FILE_SOURCE:
+		if path == "" {
+			var err error
+			if path, err = DefaultOrdersPath(); err != nil {
+				return nil, err
+			}
+		}
+		return NewFileSource(path, sourceClock), nil
+	case STDIN_SOURCE:
+		return NewStreamSource(os.Stdin, sourceClock), nil
+	case DIRECTORY_SOURCE:
+		if path == "" {
+			return nil, errors.New("Orders: Directory source requires a directory path")
+		}
+		return NewDirectorySource(path, sourceClock), nil
 	}
 
-	fmt.Printf("Orders data read: length is %d", len(OrdersData))
+	return nil, errors.New(fmt.Sprintf("Orders: Invalid order source '%s'; expected one of %s, %s, %s", kind, FILE_SOURCE, STDIN_SOURCE, DIRECTORY_SOURCE))
 }
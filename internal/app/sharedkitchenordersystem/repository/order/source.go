@@ -0,0 +1,257 @@
+package order
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/clock"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/model"
+	util "sharedkitchenordersystem/pkg"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// OrderSource reads orders and streams them to the kitchen in batches
+type OrderSource interface {
+	// Stream sends the orders read in batches of at most batchSize on the returned channel. Every batch is a unit
+	// of work on the source clock until the receiver is done with it.
+	// The channel is closed once the source has no more orders or the context is done
+	Stream(ctx context.Context, batchSize int) (<-chan []model.Order, error)
+}
+
+// FileSource replays the orders of a JSON file, one batch per interval
+type FileSource struct {
+	Path     string
+	Interval time.Duration
+	Clock    clock.Clock
+}
+
+// NewFileSource creates a source replaying the orders of a JSON file one batch per second
+func NewFileSource(path string, sourceClock clock.Clock) *FileSource {
+	return &FileSource{Path: path, Interval: time.Second, Clock: sourceClock}
+}
+
+func (source *FileSource) Stream(ctx context.Context, batchSize int) (<-chan []model.Order, error) {
+	ordersData := []model.Order{}
+	if err := util.ReadFile(source.Path, &ordersData); err != nil {
+		return nil, err
+	}
+
+	zap.S().Infof("Orders: Orders data read from '%s': length is %d", source.Path, len(ordersData))
+
+	batches := make(chan []model.Order, batchSize)
+	pending := split(ordersData, batchSize)
+
+	// The first batch is sent right away; the timer of the next batch is set before a batch is sent, so it fires
+	// before the couriers of the batch arriving at the same time
+	timer := source.Clock.NewTimer(0)
+	go func() {
+		defer close(batches)
+		for i, batch := range pending {
+			select {
+			case <-timer.C():
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			}
+
+			if i < len(pending)-1 {
+				timer = source.Clock.NewTimer(source.Interval)
+			}
+
+			source.Clock.Begin()
+			select {
+			case batches <- batch:
+			case <-ctx.Done():
+				timer.Stop()
+				source.Clock.Done()
+				source.Clock.Done()
+				return
+			}
+			source.Clock.Done()
+		}
+	}()
+
+	return batches, nil
+}
+
+// StreamSource reads newline delimited JSON orders, one order per line
+type StreamSource struct {
+	Reader io.Reader
+	Clock  clock.Clock
+}
+
+// NewStreamSource creates a source reading newline delimited JSON orders from the reader
+func NewStreamSource(reader io.Reader, sourceClock clock.Clock) *StreamSource {
+	return &StreamSource{Reader: reader, Clock: sourceClock}
+}
+
+// Stream sends orders as soon as they are read; orders already available are grouped in the same batch
+func (source *StreamSource) Stream(ctx context.Context, batchSize int) (<-chan []model.Order, error) {
+	orders := make(chan model.Order, batchSize)
+	go func() {
+		defer close(orders)
+
+		scanner := bufio.NewScanner(source.Reader)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" {
+				continue
+			}
+
+			var order model.Order
+			if err := json.Unmarshal([]byte(line), &order); err != nil {
+				zap.S().Errorf("Orders: Ignored invalid order line '%s': %s", line, err)
+				continue
+			}
+			select {
+			case orders <- order:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			zap.S().Errorf("Orders: Stopped reading orders stream: %s", err)
+		}
+	}()
+
+	batches := make(chan []model.Order, batchSize)
+	go func() {
+		defer close(batches)
+		for {
+			var batch []model.Order
+			select {
+			case order, isOpen := <-orders:
+				if !isOpen {
+					return
+				}
+				batch = []model.Order{order}
+			case <-ctx.Done():
+				return
+			}
+
+			for isDrained := false; !isDrained && len(batch) < batchSize; {
+				select {
+				case next, isOpen := <-orders:
+					if !isOpen {
+						isDrained = true
+						break
+					}
+					batch = append(batch, next)
+				default:
+					isDrained = true
+				}
+			}
+
+			source.Clock.Begin()
+			select {
+			case batches <- batch:
+			case <-ctx.Done():
+				source.Clock.Done()
+				return
+			}
+		}
+	}()
+
+	return batches, nil
+}
+
+// DirectorySource watches a directory and replays the orders of every new JSON file dropped in it
+type DirectorySource struct {
+	Dir          string
+	PollInterval time.Duration
+	Clock        clock.Clock
+}
+
+// NewDirectorySource creates a source polling the directory for new JSON files every second
+func NewDirectorySource(dir string, sourceClock clock.Clock) *DirectorySource {
+	return &DirectorySource{Dir: dir, PollInterval: time.Second, Clock: sourceClock}
+}
+
+// Stream watches the directory until the context is done; files are read in name order and each only once. A file
+// which cannot be read, e.g. while it is still being written, is tried again at the next poll until it is read
+func (source *DirectorySource) Stream(ctx context.Context, batchSize int) (<-chan []model.Order, error) {
+	if _, err := ioutil.ReadDir(source.Dir); err != nil {
+		return nil, err
+	}
+
+	batches := make(chan []model.Order, batchSize)
+	timer := source.Clock.NewTimer(0)
+	go func() {
+		defer close(batches)
+
+		seen := make(map[string]bool)
+		failed := make(map[string]bool)
+		for {
+			select {
+			case <-timer.C():
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			}
+			timer = source.Clock.NewTimer(source.PollInterval)
+
+			files, err := filepath.Glob(filepath.Join(source.Dir, "*.json"))
+			if err != nil {
+				zap.S().Errorf("Orders: Could not list directory '%s': %s", source.Dir, err)
+			}
+			sort.Strings(files)
+
+			for _, file := range files {
+				if seen[file] {
+					continue
+				}
+
+				ordersData := []model.Order{}
+				if err := util.ReadFile(file, &ordersData); err != nil {
+					if !failed[file] {
+						zap.S().Warnf("Orders: Could not read orders file '%s', trying again: %s", file, err)
+					}
+					failed[file] = true
+					continue
+				}
+				seen[file] = true
+				delete(failed, file)
+
+				zap.S().Infof("Orders: Orders data read from '%s': length is %d", file, len(ordersData))
+				for _, batch := range split(ordersData, batchSize) {
+					source.Clock.Begin()
+					select {
+					case batches <- batch:
+					case <-ctx.Done():
+						timer.Stop()
+						source.Clock.Done()
+						source.Clock.Done()
+						return
+					}
+				}
+			}
+			source.Clock.Done()
+		}
+	}()
+
+	return batches, nil
+}
+
+// split splits the orders in batches of at most batchSize orders
+func split(ordersData []model.Order, batchSize int) [][]model.Order {
+	if batchSize < 1 {
+		batchSize = 1
+	}
+
+	batches := [][]model.Order{}
+	end := 0
+	for i := 0; i < len(ordersData); i += batchSize {
+		end = int(math.Min(float64(i+batchSize), float64(len(ordersData))))
+		batches = append(batches, ordersData[i:end])
+	}
+	return batches
+}
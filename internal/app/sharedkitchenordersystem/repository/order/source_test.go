@@ -0,0 +1,193 @@
+package order
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/clock"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+// collect reads batches until the stream closes or the expected number of orders is received
+func collect(t *testing.T, batches <-chan []model.Order, wantOrders int) []model.Order {
+	orders := []model.Order{}
+	timeout := time.After(5 * time.Second)
+	for len(orders) < wantOrders {
+		select {
+		case batch, isOpen := <-batches:
+			if !isOpen {
+				return orders
+			}
+			orders = append(orders, batch...)
+		case <-timeout:
+			t.Fatalf("Stream(), got %d orders before timeout, want %d", len(orders), wantOrders)
+		}
+	}
+	return orders
+}
+
+func TestFileSource_Stream(t *testing.T) {
+	source := NewFileSource("orders.json", clock.NewWallClock())
+	batches, err := source.Stream(context.Background(), 50)
+	if err != nil {
+		t.Fatalf("Stream(), got error %v, want none", err)
+	}
+
+	orders := collect(t, batches, 1000)
+	if len(orders) != 132 {
+		t.Errorf("Stream(), got %d orders, want %d", len(orders), 132)
+	}
+
+	if _, err := NewFileSource("missing.json", clock.NewWallClock()).Stream(context.Background(), 50); err == nil {
+		t.Errorf("Stream(), got no error for missing file, want error")
+	}
+}
+
+func TestStreamSource_Stream(t *testing.T) {
+	input := `{"id":"1","name":"Banana Split","temp":"frozen","shelfLife":20,"decayRate":0.63}
+
+{"id":"2","name":"McFlury","temp":"frozen","shelfLife":375,"decayRate":0.4}
+not an order
+{"id":"3","name":"Acai Bowl","temp":"cold","shelfLife":249,"decayRate":0.3}
+`
+	batches, err := NewStreamSource(strings.NewReader(input), clock.NewWallClock()).Stream(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("Stream(), got error %v, want none", err)
+	}
+
+	orders := collect(t, batches, 10)
+	if len(orders) != 3 || orders[0].ID != "1" || orders[2].ID != "3" {
+		t.Errorf("Stream(), got orders %v, want orders 1, 2 and 3 in order", orders)
+	}
+}
+
+func TestDirectorySource_Stream(t *testing.T) {
+	dir, err := ioutil.TempDir("", "orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	source := &DirectorySource{Dir: dir, PollInterval: 10 * time.Millisecond, Clock: clock.NewWallClock()}
+	batches, err := source.Stream(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("Stream(), got error %v, want none", err)
+	}
+
+	content := `[{"id":"1","name":"Banana Split","temp":"frozen","shelfLife":20,"decayRate":0.63},{"id":"2","name":"McFlury","temp":"frozen","shelfLife":375,"decayRate":0.4},{"id":"3","name":"Acai Bowl","temp":"cold","shelfLife":249,"decayRate":0.3}]`
+	if err := ioutil.WriteFile(filepath.Join(dir, "orders.json"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	orders := collect(t, batches, 3)
+	if len(orders) != 3 {
+		t.Errorf("Stream(), got %d orders, want %d", len(orders), 3)
+	}
+
+	if _, err := NewDirectorySource(filepath.Join(dir, "missing"), clock.NewWallClock()).Stream(context.Background(), 2); err == nil {
+		t.Errorf("Stream(), got no error for missing directory, want error")
+	}
+}
+
+func TestDirectorySource_Stream_PartiallyWrittenFile_ReadOnceComplete(t *testing.T) {
+	dir, err := ioutil.TempDir("", "orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	source := &DirectorySource{Dir: dir, PollInterval: 10 * time.Millisecond, Clock: clock.NewWallClock()}
+	batches, err := source.Stream(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("Stream(), got error %v, want none", err)
+	}
+
+	content := `[{"id":"1","name":"Banana Split","temp":"frozen","shelfLife":20,"decayRate":0.63},{"id":"2","name":"McFlury","temp":"frozen","shelfLife":375,"decayRate":0.4}]`
+	file := filepath.Join(dir, "orders.json")
+	if err := ioutil.WriteFile(file, []byte(content[:len(content)/2]), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Let the directory be polled a few times while the file is incomplete
+	time.Sleep(5 * source.PollInterval)
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	orders := collect(t, batches, 2)
+	if len(orders) != 2 {
+		t.Errorf("Stream(), got %d orders, want %d", len(orders), 2)
+	}
+}
+
+func TestNewOrderSource(t *testing.T) {
+	tests := []struct {
+		name    string
+		kind    string
+		path    string
+		wantErr bool
+	}{
+		{name: "TestNewOrderSource_File_DefaultPath", kind: FILE_SOURCE},
+		{name: "TestNewOrderSource_Stdin", kind: STDIN_SOURCE},
+		{name: "TestNewOrderSource_Directory", kind: DIRECTORY_SOURCE, path: "."},
+		{name: "TestNewOrderSource_DirectoryWithoutPath_Error", kind: DIRECTORY_SOURCE, wantErr: true},
+		{name: "TestNewOrderSource_Unknown_Error", kind: "kafka", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewOrderSource(tt.kind, tt.path, clock.NewWallClock()); (err != nil) != tt.wantErr {
+				t.Errorf("NewOrderSource(), got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDefaultOrdersPath(t *testing.T) {
+	workingDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(workingDir)
+
+	outside, err := ioutil.TempDir("", "orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outside)
+
+	tests := []struct {
+		name    string
+		dir     string
+		wantErr bool
+	}{
+		{name: "TestDefaultOrdersPath_InsideRepository_Found", dir: workingDir},
+		{name: "TestDefaultOrdersPath_OutsideRepository_Error", dir: outside, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.Chdir(tt.dir); err != nil {
+				t.Fatal(err)
+			}
+
+			path, err := DefaultOrdersPath()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DefaultOrdersPath(), got error %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if _, err := NewOrderSource(FILE_SOURCE, "", clock.NewWallClock()); err == nil {
+					t.Errorf("NewOrderSource(), got no error without the sample orders file, want error")
+				}
+				return
+			}
+			if _, err := os.Stat(path); err != nil || !strings.HasSuffix(filepath.ToSlash(path), DefaultOrdersFile) {
+				t.Errorf("DefaultOrdersPath(), got path '%s' (error %v), want the sample orders file", path, err)
+			}
+		})
+	}
+}
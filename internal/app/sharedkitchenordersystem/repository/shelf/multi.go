@@ -0,0 +1,270 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/model"
+	"sync"
+	"time"
+)
+
+// MultiTemperatureShelf holds items of several temperatures, each temperature in its own compartment, up to a total
+// capacity shared by every compartment
+type MultiTemperatureShelf struct {
+	compartments map[string]*Shelf
+
+	// Temperatures accepted, in a reproducible order
+	temperatures []string
+
+	// Held by every operation so the size of the compartments is consistent with the total capacity
+	shelfLocker sync.Mutex
+	maxCapacity int
+}
+
+// NewMultiTemperatureShelf creates a shelf accepting items of the given temperatures up to the given total capacity
+func NewMultiTemperatureShelf(temperatures []string, capacity int) *MultiTemperatureShelf {
+	shelf := &MultiTemperatureShelf{
+		compartments: make(map[string]*Shelf),
+		temperatures: temperatures,
+		maxCapacity:  capacity,
+	}
+
+	for _, temp := range temperatures {
+		shelf.compartments[temp] = &Shelf{
+			sorter:      make(PriorityQueue, 0),
+			rack:        make(map[string]*Item),
+			maxCapacity: capacity,
+		}
+	}
+	return shelf
+}
+
+func (shelf *MultiTemperatureShelf) Init() {
+	for _, temp := range shelf.temperatures {
+		shelf.compartments[temp].Init()
+	}
+}
+
+// Push stores an item in the compartment of its temperature regardless of the total capacity; TryPush respects it.
+// Items of a temperature the shelf does not accept are ignored
+func (shelf *MultiTemperatureShelf) Push(shelfItem model.ShelfItem) {
+	shelf.shelfLocker.Lock()
+	defer shelf.shelfLocker.Unlock()
+
+	if compartment, isPresent := shelf.compartments[shelfItem.Order.Temp]; isPresent {
+		compartment.Push(shelfItem)
+	}
+}
+
+// TryPush stores an item in the compartment of its temperature if the shelf is not full; it tells whether the item
+// was stored
+func (shelf *MultiTemperatureShelf) TryPush(shelfItem model.ShelfItem) bool {
+	shelf.shelfLocker.Lock()
+	defer shelf.shelfLocker.Unlock()
+
+	compartment, isPresent := shelf.compartments[shelfItem.Order.Temp]
+	if !isPresent || shelf.size() >= shelf.maxCapacity {
+		return false
+	}
+
+	compartment.Push(shelfItem)
+	return true
+}
+
+// Pop removes the item with the lowest priority across the compartments
+func (shelf *MultiTemperatureShelf) Pop() (model.ShelfItem, error) {
+	shelf.shelfLocker.Lock()
+	defer shelf.shelfLocker.Unlock()
+
+	compartment := shelf.lowest()
+	if compartment == nil {
+		return (model.ShelfItem{}), errors.New("No items available to pop, shelf is empty!")
+	}
+	return compartment.Pop()
+}
+
+func (shelf *MultiTemperatureShelf) Size() int {
+	shelf.shelfLocker.Lock()
+	defer shelf.shelfLocker.Unlock()
+
+	return shelf.size()
+}
+
+// Peek peeks the item with the lowest priority across the compartments
+func (shelf *MultiTemperatureShelf) Peek() (model.ShelfItem, error) {
+	shelf.shelfLocker.Lock()
+	defer shelf.shelfLocker.Unlock()
+
+	compartment := shelf.lowest()
+	if compartment == nil {
+		return (model.ShelfItem{}), errors.New("Could not peek item from shelf, because it is empty")
+	}
+	return compartment.Peek()
+}
+
+func (shelf *MultiTemperatureShelf) IsPresent(itemID string) bool {
+	_, err := shelf.Get(itemID)
+	return err == nil
+}
+
+func (shelf *MultiTemperatureShelf) Get(itemID string) (model.ShelfItem, error) {
+	shelf.shelfLocker.Lock()
+	defer shelf.shelfLocker.Unlock()
+
+	for _, temp := range shelf.temperatures {
+		if item, err := shelf.compartments[temp].Get(itemID); err == nil {
+			return item, nil
+		}
+	}
+	return (model.ShelfItem{}), errors.New(fmt.Sprintf("Storage: Order %s not present", itemID))
+}
+
+func (shelf *MultiTemperatureShelf) GetRandomItem(rng *rand.Rand) (model.ShelfItem, error) {
+	items := shelf.Items()
+	if len(items) == 0 {
+		return (model.ShelfItem{}), errors.New("Shelf is empty!")
+	}
+	return items[rng.Intn(len(items))], nil
+}
+
+// Items gives the items of every compartment, compartment after compartment
+func (shelf *MultiTemperatureShelf) Items() []model.ShelfItem {
+	shelf.shelfLocker.Lock()
+	defer shelf.shelfLocker.Unlock()
+
+	items := []model.ShelfItem{}
+	for _, temp := range shelf.temperatures {
+		items = append(items, shelf.compartments[temp].Items()...)
+	}
+	return items
+}
+
+func (shelf *MultiTemperatureShelf) Delete(shelfItemID string) error {
+	shelf.shelfLocker.Lock()
+	defer shelf.shelfLocker.Unlock()
+
+	for _, temp := range shelf.temperatures {
+		if err := shelf.compartments[temp].Delete(shelfItemID); err == nil {
+			return nil
+		}
+	}
+	return errors.New(fmt.Sprintf("Storage: Order %s not present", shelfItemID))
+}
+
+func (shelf *MultiTemperatureShelf) Take(itemID string) (model.ShelfItem, error) {
+	shelf.shelfLocker.Lock()
+	defer shelf.shelfLocker.Unlock()
+
+	for _, temp := range shelf.temperatures {
+		if item, err := shelf.compartments[temp].Take(itemID); err == nil {
+			return item, nil
+		}
+	}
+	return (model.ShelfItem{}), errors.New(fmt.Sprintf("Storage: Order %s not present", itemID))
+}
+
+// PopExpired removes the items of every compartment expired at the given time, compartment after compartment
+func (shelf *MultiTemperatureShelf) PopExpired(now time.Time) []model.ShelfItem {
+	shelf.shelfLocker.Lock()
+	defer shelf.shelfLocker.Unlock()
+
+	expired := []model.ShelfItem{}
+	for _, temp := range shelf.temperatures {
+		expired = append(expired, shelf.compartments[temp].PopExpired(now)...)
+	}
+	return expired
+}
+
+// MaxCapacity gives the max number of items the compartments can hold together
+func (shelf *MultiTemperatureShelf) MaxCapacity() int {
+	return shelf.maxCapacity
+}
+
+// Snapshot describes the items of every compartment at the given time, compartment after compartment
+func (shelf *MultiTemperatureShelf) Snapshot(now time.Time) []model.ShelfItemSnapshot {
+	items := shelf.Items()
+	snapshots := make([]model.ShelfItemSnapshot, 0, len(items))
+	for _, item := range items {
+		snapshots = append(snapshots, item.Snapshot(now))
+	}
+	return snapshots
+}
+
+// Temperatures gives the temperatures the shelf accepts
+func (shelf *MultiTemperatureShelf) Temperatures() []string {
+	return shelf.temperatures
+}
+
+// SizeOf gives the number of items of a temperature
+func (shelf *MultiTemperatureShelf) SizeOf(temp string) int {
+	shelf.shelfLocker.Lock()
+	defer shelf.shelfLocker.Unlock()
+
+	if compartment, isPresent := shelf.compartments[temp]; isPresent {
+		return compartment.Size()
+	}
+	return 0
+}
+
+// PopOf removes the item of a temperature with the lowest priority
+func (shelf *MultiTemperatureShelf) PopOf(temp string) (model.ShelfItem, error) {
+	shelf.shelfLocker.Lock()
+	defer shelf.shelfLocker.Unlock()
+
+	compartment, isPresent := shelf.compartments[temp]
+	if !isPresent {
+		return (model.ShelfItem{}), errors.New(fmt.Sprintf("Shelf does not accept temperature '%s'", temp))
+	}
+	return compartment.Pop()
+}
+
+// PeekOf gives the item of a temperature with the lowest priority without removing it
+func (shelf *MultiTemperatureShelf) PeekOf(temp string) (model.ShelfItem, error) {
+	shelf.shelfLocker.Lock()
+	defer shelf.shelfLocker.Unlock()
+
+	compartment, isPresent := shelf.compartments[temp]
+	if !isPresent {
+		return (model.ShelfItem{}), errors.New(fmt.Sprintf("Shelf does not accept temperature '%s'", temp))
+	}
+	return compartment.Peek()
+}
+
+// ItemsOf gives the items of a temperature
+func (shelf *MultiTemperatureShelf) ItemsOf(temp string) []model.ShelfItem {
+	shelf.shelfLocker.Lock()
+	defer shelf.shelfLocker.Unlock()
+
+	if compartment, isPresent := shelf.compartments[temp]; isPresent {
+		return compartment.Items()
+	}
+	return []model.ShelfItem{}
+}
+
+// size gives the number of items of every compartment; the shelf must be locked
+func (shelf *MultiTemperatureShelf) size() int {
+	size := 0
+	for _, compartment := range shelf.compartments {
+		size += compartment.Size()
+	}
+	return size
+}
+
+// lowest gives the compartment holding the item with the lowest priority, nil if the shelf is empty; the shelf must
+// be locked
+func (shelf *MultiTemperatureShelf) lowest() *Shelf {
+	var lowest *Shelf
+	var lowestPriority int64
+	for _, temp := range shelf.temperatures {
+		compartment := shelf.compartments[temp]
+		if compartment.Size() == 0 {
+			continue
+		}
+
+		if priority := compartment.sorter.Peek().(*Item).Priority; lowest == nil || priority < lowestPriority {
+			lowest, lowestPriority = compartment, priority
+		}
+	}
+	return lowest
+}
@@ -0,0 +1,76 @@
+package repo
+
+import (
+	"fmt"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/model"
+	"sync"
+	"testing"
+)
+
+func TestMultiTemperatureShelf(t *testing.T) {
+	shelf := NewMultiTemperatureShelf([]string{model.HOT, model.COLD}, 3)
+	shelf.Init()
+
+	hot := model.ShelfItem{Order: model.Order{ID: "1", Name: "chicken", Temp: model.HOT, ShelfLife: 20, DecayRate: 1}, DecayModifier: 1}
+	cold := model.ShelfItem{Order: model.Order{ID: "2", Name: "juice", Temp: model.COLD, ShelfLife: 10, DecayRate: 1}, DecayModifier: 1}
+	hotLater := model.ShelfItem{Order: model.Order{ID: "3", Name: "soup", Temp: model.HOT, ShelfLife: 30, DecayRate: 1}, DecayModifier: 1}
+	frozen := model.ShelfItem{Order: model.Order{ID: "4", Name: "ice cream", Temp: model.FROZEN, ShelfLife: 30, DecayRate: 1}, DecayModifier: 1}
+	extra := model.ShelfItem{Order: model.Order{ID: "5", Name: "salad", Temp: model.COLD, ShelfLife: 30, DecayRate: 1}, DecayModifier: 1}
+
+	if shelf.TryPush(frozen) {
+		t.Errorf("MultiTemperatureShelf TryPush incorrect, got order of a temperature not accepted stored, want: not stored")
+	}
+
+	for _, item := range []model.ShelfItem{hot, cold, hotLater} {
+		if !shelf.TryPush(item) {
+			t.Errorf("MultiTemperatureShelf TryPush incorrect, got order %s not stored, want: stored", item.Order.ID)
+		}
+	}
+
+	// Capacity is shared by every compartment
+	if shelf.TryPush(extra) || shelf.Size() != 3 {
+		t.Errorf("MultiTemperatureShelf TryPush incorrect, got %d orders on full shelf, want: %d", shelf.Size(), 3)
+	}
+
+	if shelf.SizeOf(model.HOT) != 2 || shelf.SizeOf(model.COLD) != 1 || len(shelf.ItemsOf(model.HOT)) != 2 {
+		t.Errorf("MultiTemperatureShelf SizeOf incorrect, got %d hot and %d cold orders, want: 2 and 1", shelf.SizeOf(model.HOT), shelf.SizeOf(model.COLD))
+	}
+
+	// Peek and Pop look across compartments
+	if item, err := shelf.Peek(); err != nil || item.Order.ID != cold.Order.ID {
+		t.Errorf("MultiTemperatureShelf Peek incorrect, got order %s, want: %s", item.Order.ID, cold.Order.ID)
+	}
+
+	if item, err := shelf.PopOf(model.HOT); err != nil || item.Order.ID != hot.Order.ID {
+		t.Errorf("MultiTemperatureShelf PopOf incorrect, got order %s, want: %s", item.Order.ID, hot.Order.ID)
+	}
+
+	if item, err := shelf.Pop(); err != nil || item.Order.ID != cold.Order.ID {
+		t.Errorf("MultiTemperatureShelf Pop incorrect, got order %s, want: %s", item.Order.ID, cold.Order.ID)
+	}
+
+	if err := shelf.Delete(hotLater.Order.ID); err != nil || shelf.IsPresent(hotLater.Order.ID) || shelf.Size() != 0 {
+		t.Errorf("MultiTemperatureShelf Delete incorrect, got order %s present: %v, want: deleted", hotLater.Order.ID, shelf.IsPresent(hotLater.Order.ID))
+	}
+}
+
+func TestMultiTemperatureShelf_TryPush_Concurrent(t *testing.T) {
+	shelf := NewMultiTemperatureShelf([]string{model.HOT, model.COLD, model.FROZEN}, 15)
+	shelf.Init()
+
+	var wg sync.WaitGroup
+	for _, temp := range shelf.Temperatures() {
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(temp string, i int) {
+				defer wg.Done()
+				shelf.TryPush(model.ShelfItem{Order: model.Order{ID: fmt.Sprintf("%s-%d", temp, i), Temp: temp, ShelfLife: 10, DecayRate: 1}})
+			}(temp, i)
+		}
+	}
+	wg.Wait()
+
+	if shelf.Size() != shelf.MaxCapacity() {
+		t.Errorf("MultiTemperatureShelf TryPush incorrect, got %d orders, want: %d", shelf.Size(), shelf.MaxCapacity())
+	}
+}
@@ -4,8 +4,11 @@ import (
 	"container/heap"
 	"errors"
 	"fmt"
+	"math/rand"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/config"
 	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/model"
 	"sync"
+	"time"
 )
 
 // An Item is managed in priority queue
@@ -20,7 +23,7 @@ type PriorityQueue []*Item
 
 func (pq PriorityQueue) Len() int { return len(pq) }
 
-// Less helps decide which item needs to Pop; we want the the item with the lowest priority
+// Less helps decide which item needs to Pop; we want the the item with the lowest priority, the first to expire
 func (pq PriorityQueue) Less(i, j int) bool {
 	return pq[i].Priority < pq[j].Priority
 }
@@ -82,6 +85,9 @@ type IShelf interface {
 	// Push pushes and item into th priority queue
 	Push(model.ShelfItem)
 
+	// TryPush pushes an item into the priority queue if the shelf is not full; it tells whether the item was pushed
+	TryPush(model.ShelfItem) bool
+
 	// Pop removes an item with the lowest priority
 	Pop() (model.ShelfItem, error)
 
@@ -94,14 +100,29 @@ type IShelf interface {
 	// IsPresent checks if an item is present
 	IsPresent(itemID string) bool
 
-	// GetRandomItem gets a randoim item and removes it if present
-	GetRandomItem() (model.ShelfItem, error)
+	// Get gets an item without removing it
+	Get(itemID string) (model.ShelfItem, error)
+
+	// GetRandomItem gets a random item picked with the given random number generator
+	GetRandomItem(rng *rand.Rand) (model.ShelfItem, error)
+
+	// Items gives the items present, in a reproducible order
+	Items() []model.ShelfItem
 
 	// Delete removes an item from the shelf
 	Delete(string) error
 
+	// Take removes an item from the shelf and gives it
+	Take(itemID string) (model.ShelfItem, error)
+
+	// PopExpired removes the items expired at the given time and gives them
+	PopExpired(now time.Time) []model.ShelfItem
+
 	// MaxCapacity gives the max number of items the shelf can hold
 	MaxCapacity() int
+
+	// Snapshot describes the items present at the given time, in the order of Items
+	Snapshot(now time.Time) []model.ShelfItemSnapshot
 }
 
 type Shelf struct {
@@ -124,10 +145,27 @@ func (shelf *Shelf) Size() int {
 
 func (shelf *Shelf) Push(shelfItem model.ShelfItem) {
 	shelf.shelfLocker.Lock()
-	item := &Item{Value: shelfItem, Priority: shelfItem.MaxLifeTimeS}
+	shelf.push(shelfItem)
+	shelf.shelfLocker.Unlock()
+}
+
+func (shelf *Shelf) TryPush(shelfItem model.ShelfItem) bool {
+	shelf.shelfLocker.Lock()
+	defer shelf.shelfLocker.Unlock()
+
+	if shelf.sorter.Len() >= shelf.maxCapacity {
+		return false
+	}
+
+	shelf.push(shelfItem)
+	return true
+}
+
+// push pushes an item into the priority queue, the first to expire at the root; the shelf must be locked
+func (shelf *Shelf) push(shelfItem model.ShelfItem) {
+	item := &Item{Value: shelfItem, Priority: shelfItem.ExpiresAt().UnixNano()}
 	heap.Push(&shelf.sorter, item)
 	shelf.rack[shelfItem.Order.ID] = item
-	shelf.shelfLocker.Unlock()
 }
 
 func (shelf *Shelf) Pop() (model.ShelfItem, error) {
@@ -165,18 +203,40 @@ func (shelf *Shelf) IsPresent(itemID string) bool {
 	return isPresent
 }
 
-func (shelf *Shelf) GetRandomItem() (model.ShelfItem, error) {
+func (shelf *Shelf) Get(itemID string) (model.ShelfItem, error) {
 	shelf.shelfLocker.Lock()
 	defer shelf.shelfLocker.Unlock()
 
-	// Get random item - go does not gurantee order of a map, so the order itself is random by default
-	for id, _ := range shelf.rack {
-		randomItem := shelf.rack[id]
-		item := randomItem.Value
-		return item, nil
+	item, isPresent := shelf.rack[itemID]
+	if !isPresent {
+		return (model.ShelfItem{}), errors.New(fmt.Sprintf("Storage: Order %s not present", itemID))
 	}
 
-	return (model.ShelfItem{}), errors.New("Shelf is empty!")
+	return item.Value, nil
+}
+
+func (shelf *Shelf) GetRandomItem(rng *rand.Rand) (model.ShelfItem, error) {
+	shelf.shelfLocker.Lock()
+	defer shelf.shelfLocker.Unlock()
+
+	if shelf.sorter.Len() == 0 {
+		return (model.ShelfItem{}), errors.New("Shelf is empty!")
+	}
+
+	// Pick from the priority queue rather than the rack, whose iteration order is not reproducible
+	return shelf.sorter[rng.Intn(shelf.sorter.Len())].Value, nil
+}
+
+func (shelf *Shelf) Items() []model.ShelfItem {
+	shelf.shelfLocker.Lock()
+	defer shelf.shelfLocker.Unlock()
+
+	// List from the priority queue rather than the rack, whose iteration order is not reproducible
+	items := make([]model.ShelfItem, 0, shelf.sorter.Len())
+	for _, item := range shelf.sorter {
+		items = append(items, item.Value)
+	}
+	return items
 }
 
 func (shelf *Shelf) Delete(shelfItemID string) error {
@@ -194,56 +254,146 @@ func (shelf *Shelf) Delete(shelfItemID string) error {
 	return nil
 }
 
+func (shelf *Shelf) Take(itemID string) (model.ShelfItem, error) {
+	shelf.shelfLocker.Lock()
+	defer shelf.shelfLocker.Unlock()
+
+	item, isPresent := shelf.rack[itemID]
+	if !isPresent {
+		return (model.ShelfItem{}), errors.New(fmt.Sprintf("Storage: Order %s not present", itemID))
+	}
+
+	shelf.sorter.delete(item)
+	delete(shelf.rack, itemID)
+	return item.Value, nil
+}
+
+// PopExpired pops the items from the root of the priority queue, the first to expire, as long as they are expired
+func (shelf *Shelf) PopExpired(now time.Time) []model.ShelfItem {
+	shelf.shelfLocker.Lock()
+	defer shelf.shelfLocker.Unlock()
+
+	expired := []model.ShelfItem{}
+	for shelf.sorter.Len() > 0 && shelf.sorter.Peek().(*Item).Value.IsExpired(now) {
+		item := heap.Pop(&shelf.sorter).(*Item)
+		delete(shelf.rack, item.Value.Order.ID)
+		expired = append(expired, item.Value)
+	}
+	return expired
+}
+
 func (shelf *Shelf) MaxCapacity() int {
 	return shelf.maxCapacity
 }
 
-var hotShelf IShelf
-var coldShelf IShelf
-var frozenShelf IShelf
-var OverflowShelf map[string]IShelf
+func (shelf *Shelf) Snapshot(now time.Time) []model.ShelfItemSnapshot {
+	items := shelf.Items()
+	snapshots := make([]model.ShelfItemSnapshot, 0, len(items))
+	for _, item := range items {
+		snapshots = append(snapshots, item.Snapshot(now))
+	}
+	return snapshots
+}
+
+// Repository holds the temperature controlled shelves and the overflow shelf of a kitchen
+type Repository struct {
+	OverflowShelf *MultiTemperatureShelf
+
+	shelves           map[string]IShelf
+	ShelfTemperatures []string
 
-var shelves map[string]IShelf
-var ShelfTemperatures []string
+	ShelvesCapacity map[string]int
 
-var ShelvesCapacity map[string]int
+	// DecayModifiers holds the decay modifier of each temperature controlled shelf and the overflow shelf
+	DecayModifiers map[string]float32
 
-func Initialize() {
-	ShelvesCapacity = map[string]int{
-		model.HOT:      10,
-		model.COLD:     10,
-		model.FROZEN:   10,
-		model.OVERFLOW: 15,
+	// Held by the transfers of orders between shelves, so no other transfer sees one half done
+	transferLocker sync.Mutex
+}
+
+// New creates the temperature controlled shelves and the overflow shelf from the shelves layout
+func New(shelvesConfig []config.ShelfConfig) *Repository {
+	repository := &Repository{
+		shelves:           make(map[string]IShelf),
+		ShelfTemperatures: []string{},
+		ShelvesCapacity:   make(map[string]int),
+		DecayModifiers:    make(map[string]float32),
 	}
 
-	ShelfTemperatures := []string{model.HOT, model.COLD, model.FROZEN}
-	shelves = make(map[string]IShelf)
+	for _, shelfConfig := range shelvesConfig {
+		if len(shelfConfig.Temperatures) > 1 {
+			repository.ShelvesCapacity[model.OVERFLOW] = shelfConfig.Capacity
+			repository.DecayModifiers[model.OVERFLOW] = shelfConfig.DecayModifier
+
+			repository.OverflowShelf = NewMultiTemperatureShelf(shelfConfig.Temperatures, shelfConfig.Capacity)
+			repository.OverflowShelf.Init()
+			continue
+		}
 
-	for _, shelfType := range ShelfTemperatures {
-		shelves[shelfType] = &Shelf{
+		shelfType := shelfConfig.Temperatures[0]
+		repository.ShelfTemperatures = append(repository.ShelfTemperatures, shelfType)
+		repository.ShelvesCapacity[shelfType] = shelfConfig.Capacity
+		repository.DecayModifiers[shelfType] = shelfConfig.DecayModifier
+
+		repository.shelves[shelfType] = &Shelf{
 			sorter:      make(PriorityQueue, 0),
 			rack:        make(map[string]*Item),
-			maxCapacity: ShelvesCapacity[shelfType],
+			maxCapacity: shelfConfig.Capacity,
 		}
-		shelves[shelfType].Init()
+		repository.shelves[shelfType].Init()
 	}
 
-	OverflowShelf = make(map[string]IShelf, 0)
+	return repository
+}
+
+func (repository *Repository) ShelfFactory(shelfTemperature string) (IShelf, error) {
+	if shelf, isPresent := repository.shelves[shelfTemperature]; isPresent {
+		return shelf, nil
+	}
+
+	return nil, errors.New(fmt.Sprintf("Invalid shelfTemperature '%s' ", shelfTemperature))
+}
+
+// Transfer runs a change spanning several shelves, such as moving an order between shelves or looking for it on each
+// of them, as a whole: other transfers see the shelves either before or after it. The change must not start another
+// transfer
+func (repository *Repository) Transfer(change func()) {
+	repository.transferLocker.Lock()
+	defer repository.transferLocker.Unlock()
 
-	for _, temp := range ShelfTemperatures {
-		OverflowShelf[temp] = &Shelf{
-			sorter: make(PriorityQueue, 0),
-			rack:   make(map[string]*Item),
+	change()
+}
+
+// Locate finds the shelf an item is stored on, looking at the temperature controlled shelves before the overflow shelf
+func (repository *Repository) Locate(itemID string) (string, model.ShelfItem, bool) {
+	for _, shelfType := range repository.ShelfTemperatures {
+		if item, err := repository.shelves[shelfType].Get(itemID); err == nil {
+			return shelfType, item, true
 		}
+	}
 
-		OverflowShelf[temp].Init()
+	if item, err := repository.OverflowShelf.Get(itemID); err == nil {
+		return model.OVERFLOW, item, true
 	}
+
+	return "", model.ShelfItem{}, false
 }
 
-func ShelfFactory(shelfTemperature string) (IShelf, error) {
-	if shelf, isPresent := shelves[shelfTemperature]; isPresent {
-		return shelf, nil
-	}
+// Snapshot describes the items on every shelf at the given time, the temperature controlled shelves first and the
+// overflow shelf last. No item is moved between shelves meanwhile
+func (repository *Repository) Snapshot(now time.Time) []model.ShelfSnapshot {
+	snapshots := make([]model.ShelfSnapshot, 0, len(repository.ShelfTemperatures)+1)
+	repository.Transfer(func() {
+		for _, shelfType := range repository.ShelfTemperatures {
+			shelf := repository.shelves[shelfType]
+			snapshots = append(snapshots, model.ShelfSnapshot{Shelf: shelfType, Capacity: shelf.MaxCapacity(), Items: shelf.Snapshot(now)})
+		}
 
-	return nil, errors.New(fmt.Sprintf("Invalid shelfTemperature '%s' ", shelfTemperature))
+		snapshots = append(snapshots, model.ShelfSnapshot{
+			Shelf:    model.OVERFLOW,
+			Capacity: repository.OverflowShelf.MaxCapacity(),
+			Items:    repository.OverflowShelf.Snapshot(now),
+		})
+	})
+	return snapshots
 }
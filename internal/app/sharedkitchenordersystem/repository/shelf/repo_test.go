@@ -1,15 +1,21 @@
 package repo
 
 import (
+	"fmt"
+	"math/rand"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/config"
 	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/model"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestPriorityQueuePush(t *testing.T) {
-	shelfItem1 := model.ShelfItem{Order: model.Order{ID: "1", Name: "juice"}, MaxLifeTimeS: 10}
-	shelfItem2 := model.ShelfItem{Order: model.Order{ID: "2", Name: "icecream"}, MaxLifeTimeS: 20}
-	shelfItem3 := model.ShelfItem{Order: model.Order{ID: "3", Name: "chicken"}, MaxLifeTimeS: 30}
-	shelfItem4 := model.ShelfItem{Order: model.Order{ID: "4", Name: "egg sandwich"}, MaxLifeTimeS: 1}
+	shelfItem1 := model.ShelfItem{Order: model.Order{ID: "1", Name: "juice", ShelfLife: 10, DecayRate: 1}, DecayModifier: 1}
+	shelfItem2 := model.ShelfItem{Order: model.Order{ID: "2", Name: "icecream", ShelfLife: 20, DecayRate: 1}, DecayModifier: 1}
+	shelfItem3 := model.ShelfItem{Order: model.Order{ID: "3", Name: "chicken", ShelfLife: 30, DecayRate: 1}, DecayModifier: 1}
+	shelfItem4 := model.ShelfItem{Order: model.Order{ID: "4", Name: "egg sandwich", ShelfLife: 1, DecayRate: 1}, DecayModifier: 1}
 
 	shelf := &Shelf{
 		sorter: make(PriorityQueue, 0),
@@ -25,7 +31,7 @@ func TestPriorityQueuePush(t *testing.T) {
 	shelfItem, _ := shelf.Pop()
 
 	if shelfItem.Order.Name != shelfItem1.Order.Name {
-		t.Errorf("PriorityQueue Pop incorrect, got order with priority: %d, want: %d", shelfItem.MaxLifeTimeS, shelfItem1.MaxLifeTimeS)
+		t.Errorf("PriorityQueue Pop incorrect, got order expiring at %s, want: %s", shelfItem.ExpiresAt(), shelfItem1.ExpiresAt())
 	}
 
 	shelf.Push(shelfItem4)
@@ -34,7 +40,7 @@ func TestPriorityQueuePush(t *testing.T) {
 	shelfItem, _ = shelf.Peek()
 
 	if shelfItem.Order.Name != shelfItem4.Order.Name {
-		t.Errorf("PriorityQueue Peek incorrect, got order with priority: %d, want: %d", shelfItem.MaxLifeTimeS, shelfItem4.MaxLifeTimeS)
+		t.Errorf("PriorityQueue Peek incorrect, got order expiring at %s, want: %s", shelfItem.ExpiresAt(), shelfItem4.ExpiresAt())
 	}
 
 	// Get (Remove)
@@ -50,7 +56,7 @@ func TestPriorityQueuePush(t *testing.T) {
 	}
 
 	// Get random item
-	item, err := shelf.GetRandomItem()
+	item, err := shelf.GetRandomItem(rand.New(rand.NewSource(1)))
 	if err != nil {
 		t.Errorf("PriorityQueue GetRandomItem incorrect, got error '%s' , want: no error", err)
 	}
@@ -58,4 +64,235 @@ func TestPriorityQueuePush(t *testing.T) {
 	if !shelf.IsPresent(item.Order.ID) {
 		t.Errorf("PriorityQueue GetRandomItem  incorrect, got order %s not present, want: present", item.Order.ID)
 	}
+
+	// Items
+	if items := shelf.Items(); len(items) != 2 || !shelf.IsPresent(items[0].Order.ID) || !shelf.IsPresent(items[1].Order.ID) {
+		t.Errorf("PriorityQueue Items incorrect, got %d items, want: the %d items present", len(items), 2)
+	}
+}
+
+func TestNew(t *testing.T) {
+	repository := New([]config.ShelfConfig{
+		{Name: "Hot shelf", Temperatures: []string{model.HOT}, Capacity: 3, DecayModifier: 1},
+		{Name: "Overflow shelf", Temperatures: []string{model.HOT, model.COLD}, Capacity: 5, DecayModifier: 2},
+	})
+
+	if shelf, err := repository.ShelfFactory(model.HOT); err != nil || shelf.MaxCapacity() != 3 {
+		t.Errorf("New(), got hot shelf error %v, want shelf with capacity 3", err)
+	}
+
+	if _, err := repository.ShelfFactory(model.COLD); err == nil {
+		t.Errorf("New(), got cold shelf, want none")
+	}
+
+	if len(repository.OverflowShelf.Temperatures()) != 2 || repository.ShelvesCapacity[model.OVERFLOW] != 5 || repository.DecayModifiers[model.OVERFLOW] != 2 {
+		t.Errorf("New(), got %d overflow compartments with capacity %d and decay modifier %.0f, want 2, 5 and 2", len(repository.OverflowShelf.Temperatures()), repository.ShelvesCapacity[model.OVERFLOW], repository.DecayModifiers[model.OVERFLOW])
+	}
+
+	// Shelves of different repositories are independent
+	other := New(config.Default().Shelves)
+	otherShelf, _ := other.ShelfFactory(model.HOT)
+	otherShelf.Push(model.ShelfItem{Order: model.Order{ID: "1", Temp: model.HOT}})
+	if _, _, isPresent := repository.Locate("1"); isPresent {
+		t.Errorf("New(), got item stored in another repository, want repositories independent")
+	}
+}
+
+func TestShelf_TryPush_Concurrent(t *testing.T) {
+	shelf := &Shelf{
+		sorter:      make(PriorityQueue, 0),
+		rack:        make(map[string]*Item),
+		maxCapacity: 10,
+	}
+	shelf.Init()
+
+	var wg sync.WaitGroup
+	var pushed int32
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if shelf.TryPush(model.ShelfItem{Order: model.Order{ID: fmt.Sprintf("%d", i), ShelfLife: 10, DecayRate: 1}, DecayModifier: 1}) {
+				atomic.AddInt32(&pushed, 1)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if pushed != 10 || shelf.Size() != 10 {
+		t.Errorf("Shelf TryPush incorrect, got %d items pushed and %d on shelf, want: %d", pushed, shelf.Size(), 10)
+	}
+}
+
+func TestShelf_Take_Concurrent(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	shelf := &Shelf{
+		sorter:      make(PriorityQueue, 0),
+		rack:        make(map[string]*Item),
+		maxCapacity: 10,
+	}
+	shelf.Init()
+
+	// Half of the items are expired
+	for i := 0; i < 10; i++ {
+		shelf.Push(model.NewShelfItem(model.Order{ID: fmt.Sprintf("%d", i), ShelfLife: 10, DecayRate: 1}, now.Add(-time.Duration(i*2)*time.Second), 1))
+	}
+
+	// Couriers take every item while the garbage collector pops the expired ones; every item is removed exactly once
+	var wg sync.WaitGroup
+	var removed int32
+	for i := 0; i < 10; i++ {
+		for courier := 0; courier < 2; courier++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				if _, err := shelf.Take(fmt.Sprintf("%d", i)); err == nil {
+					atomic.AddInt32(&removed, 1)
+				}
+			}(i)
+		}
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		atomic.AddInt32(&removed, int32(len(shelf.PopExpired(now))))
+	}()
+	wg.Wait()
+
+	if removed != 10 || shelf.Size() != 0 {
+		t.Errorf("Shelf Take incorrect, got %d items removed and %d left, want: %d removed", removed, shelf.Size(), 10)
+	}
+}
+
+func TestShelf_PopExpired(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	shelf := &Shelf{
+		sorter:      make(PriorityQueue, 0),
+		rack:        make(map[string]*Item),
+		maxCapacity: 10,
+	}
+	shelf.Init()
+
+	fresh := model.NewShelfItem(model.Order{ID: "1", ShelfLife: 10, DecayRate: 1}, now, 1)
+	expired := model.NewShelfItem(model.Order{ID: "2", ShelfLife: 10, DecayRate: 1}, now.Add(-10*time.Second), 1)
+	longExpired := model.NewShelfItem(model.Order{ID: "3", ShelfLife: 10, DecayRate: 1}, now.Add(-20*time.Second), 1)
+	shelf.Push(fresh)
+	shelf.Push(expired)
+	shelf.Push(longExpired)
+
+	items := shelf.PopExpired(now)
+	if len(items) != 2 || items[0].Order.ID != longExpired.Order.ID || items[1].Order.ID != expired.Order.ID {
+		t.Errorf("Shelf PopExpired incorrect, got %d items, want: orders %s and %s", len(items), longExpired.Order.ID, expired.Order.ID)
+	}
+
+	if !shelf.IsPresent(fresh.Order.ID) || shelf.Size() != 1 {
+		t.Errorf("Shelf PopExpired incorrect, got order %s removed, want: kept", fresh.Order.ID)
+	}
+}
+
+func TestRepository_Snapshot(t *testing.T) {
+	repository := New(config.Default().Shelves)
+	cooked := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	hotShelf, _ := repository.ShelfFactory(model.HOT)
+	hotShelf.Push(model.NewShelfItem(model.Order{ID: "1", Name: "Pizza", Temp: model.HOT, ShelfLife: 100, DecayRate: 1}, cooked, 1))
+	repository.OverflowShelf.Push(model.NewShelfItem(model.Order{ID: "2", Name: "Ice Cream", Temp: model.FROZEN, ShelfLife: 100, DecayRate: 0.5}, cooked, 2))
+
+	snapshots := repository.Snapshot(cooked.Add(10 * time.Second))
+
+	wantShelves := []string{model.HOT, model.COLD, model.FROZEN, model.OVERFLOW}
+	if len(snapshots) != len(wantShelves) {
+		t.Fatalf("Snapshot(), got %d shelves, want %d", len(snapshots), len(wantShelves))
+	}
+
+	tests := []struct {
+		name          string
+		shelf         int
+		wantCapacity  int
+		wantItem      model.ShelfItemSnapshot
+		wantItemCount int
+	}{
+		{
+			name:          "TestRepository_Snapshot_HotShelf_ItemDescribed",
+			shelf:         0,
+			wantCapacity:  10,
+			wantItem:      model.ShelfItemSnapshot{AgeS: 10, RemainingShelfLifeS: 90, Value: 0.9},
+			wantItemCount: 1,
+		},
+		{
+			name:         "TestRepository_Snapshot_EmptyShelf_NoItems",
+			shelf:        1,
+			wantCapacity: 10,
+		},
+		{
+			name:          "TestRepository_Snapshot_OverflowShelf_ItemDecaysWithModifier",
+			shelf:         3,
+			wantCapacity:  15,
+			wantItem:      model.ShelfItemSnapshot{AgeS: 10, RemainingShelfLifeS: 90, Value: 0.9},
+			wantItemCount: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snapshot := snapshots[tt.shelf]
+			if snapshot.Shelf != wantShelves[tt.shelf] || snapshot.Capacity != tt.wantCapacity {
+				t.Errorf("Snapshot(), got shelf '%s' of capacity %d, want shelf '%s' of capacity %d", snapshot.Shelf, snapshot.Capacity, wantShelves[tt.shelf], tt.wantCapacity)
+			}
+
+			if len(snapshot.Items) != tt.wantItemCount {
+				t.Fatalf("Snapshot(), got %d items, want %d", len(snapshot.Items), tt.wantItemCount)
+			}
+			if tt.wantItemCount == 0 {
+				return
+			}
+
+			item := snapshot.Items[0]
+			if item.AgeS != tt.wantItem.AgeS || item.RemainingShelfLifeS != tt.wantItem.RemainingShelfLifeS || fmt.Sprintf("%.2f", item.Value) != fmt.Sprintf("%.2f", tt.wantItem.Value) {
+				t.Errorf("Snapshot(), got item aged %ds with %ds left and value %.2f, want aged %ds with %ds left and value %.2f", item.AgeS, item.RemainingShelfLifeS, item.Value, tt.wantItem.AgeS, tt.wantItem.RemainingShelfLifeS, tt.wantItem.Value)
+			}
+		})
+	}
+}
+
+func TestRepository_Transfer_SnapshotSeesItemOnce(t *testing.T) {
+	repository := New(config.Default().Shelves)
+	hot, _ := repository.ShelfFactory(model.HOT)
+	item := model.NewShelfItem(model.Order{ID: "1", Name: "chicken", Temp: model.HOT, ShelfLife: 100, DecayRate: 1}, time.Now(), 1)
+	hot.Push(item)
+
+	// Move the item back and forth between the hot shelf and the overflow shelf while taking snapshots
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+
+			repository.Transfer(func() {
+				if _, err := hot.Take(item.Order.ID); err == nil {
+					repository.OverflowShelf.Push(item)
+					return
+				}
+				repository.OverflowShelf.Take(item.Order.ID)
+				hot.Push(item)
+			})
+		}
+	}()
+
+	for i := 0; i < 1000; i++ {
+		items := 0
+		for _, snapshot := range repository.Snapshot(time.Now()) {
+			items += len(snapshot.Items)
+		}
+		if items != 1 {
+			t.Fatalf("Snapshot(), got the item %d times, want once", items)
+		}
+	}
+	close(stop)
+	<-done
 }
@@ -0,0 +1,109 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/model"
+	"time"
+)
+
+// Snapshot is the state of a kitchen: the orders on its shelves and the statuses reported so far
+type Snapshot struct {
+	// Time the snapshot was taken on the kitchen clock
+	Time time.Time `json:"time"`
+
+	// Orders on each shelf by shelf type, model.OVERFLOW for the overflow shelf
+	Shelves map[string][]model.ShelfItem `json:"shelves"`
+
+	Report Report `json:"report"`
+}
+
+// Report is the state of the report book of a kitchen
+type Report struct {
+	// Status history of every order
+	History map[string][]model.OrderStatus `json:"history"`
+
+	// Number of statuses reported for orders without an id, by status
+	Unidentified map[string]int `json:"unidentified"`
+
+	// Ids of the orders accepted at intake
+	Accepted []string `json:"accepted"`
+
+	// Temperature of the orders accepted, by order id
+	Temperatures map[string]string `json:"temperatures,omitempty"`
+
+	// Value of the orders picked up when they were picked up, by order id
+	Values map[string]float64 `json:"values,omitempty"`
+
+	// Ids of the orders cancelled, which may not be taken off yet
+	Cancelled []string `json:"cancelled,omitempty"`
+}
+
+// Store saves the state of a kitchen and loads it back when the kitchen restarts
+type Store interface {
+	// Save replaces the saved state with the given snapshot
+	Save(snapshot Snapshot) error
+
+	// Load gives the saved state; it tells whether a state was saved
+	Load() (Snapshot, bool, error)
+}
+
+// FileStore saves the state of a kitchen in a JSON file. A snapshot is written next to the file and renamed over
+// it, so a crash while saving leaves the previous snapshot in place
+type FileStore struct {
+	path string
+}
+
+// NewFileStore creates a store saving the state in the given file
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+func (store *FileStore) Save(snapshot Snapshot) error {
+	content, err := json.Marshal(snapshot)
+	if err != nil {
+		return errors.New(fmt.Sprintf("State: Could not encode snapshot: %s", err))
+	}
+
+	file, err := ioutil.TempFile(filepath.Dir(store.path), filepath.Base(store.path)+".*.tmp")
+	if err != nil {
+		return errors.New(fmt.Sprintf("State: Could not save snapshot to '%s': %s", store.path, err))
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.Write(content)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), store.path)
+	}
+
+	if err != nil {
+		return errors.New(fmt.Sprintf("State: Could not save snapshot to '%s': %s", store.path, err))
+	}
+	return nil
+}
+
+func (store *FileStore) Load() (Snapshot, bool, error) {
+	content, err := ioutil.ReadFile(store.path)
+	if os.IsNotExist(err) {
+		return Snapshot{}, false, nil
+	}
+	if err != nil {
+		return Snapshot{}, false, errors.New(fmt.Sprintf("State: Could not load snapshot from '%s': %s", store.path, err))
+	}
+
+	var snapshot Snapshot
+	if err := json.Unmarshal(content, &snapshot); err != nil {
+		return Snapshot{}, false, errors.New(fmt.Sprintf("State: Invalid snapshot in '%s': %s", store.path, err))
+	}
+	return snapshot, true, nil
+}
@@ -0,0 +1,82 @@
+package state
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/model"
+	"testing"
+	"time"
+)
+
+func TestFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cooked := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	item := model.NewShelfItem(model.Order{ID: "1", Name: "chicken", Temp: model.HOT, ShelfLife: 100, DecayRate: 0.5}, cooked, 1).Move(cooked.Add(10*time.Second), 2)
+	snapshot := Snapshot{
+		Time:    cooked.Add(20 * time.Second),
+		Shelves: map[string][]model.ShelfItem{model.OVERFLOW: {item}},
+		Report: Report{
+			History: map[string][]model.OrderStatus{
+				"1": {{Status: model.ORDER_RECEIVED, OrderId: "1", Time: cooked}, {Status: model.ORDER_OVERFLOWN, OrderId: "1", Time: cooked.Add(10 * time.Second), Shelf: model.OVERFLOW}},
+			},
+			Unidentified: map[string]int{model.ORDER_REJECTED: 1},
+			Accepted:     []string{"1"},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		path        string
+		save        []Snapshot
+		wantPresent bool
+		wantErr     bool
+	}{
+		{
+			name: "TestFileStore_NothingSaved_NotPresent",
+			path: filepath.Join(dir, "empty.json"),
+		},
+		{
+			name:        "TestFileStore_Saved_Loaded",
+			path:        filepath.Join(dir, "saved.json"),
+			save:        []Snapshot{{Time: cooked}, snapshot},
+			wantPresent: true,
+		},
+		{
+			name:    "TestFileStore_MissingDirectory_Error",
+			path:    filepath.Join(dir, "missing", "state.json"),
+			save:    []Snapshot{snapshot},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewFileStore(tt.path)
+			for _, saved := range tt.save {
+				if err := store.Save(saved); (err != nil) != tt.wantErr {
+					t.Fatalf("Save(), got error %v, want error %v", err, tt.wantErr)
+				}
+			}
+
+			loaded, isPresent, err := store.Load()
+			if err != nil || isPresent != tt.wantPresent {
+				t.Fatalf("Load(), got present %v and error %v, want present %v", isPresent, err, tt.wantPresent)
+			}
+
+			if tt.wantPresent && !reflect.DeepEqual(loaded, snapshot) {
+				t.Errorf("Load(), got %+v, want last saved snapshot %+v", loaded, snapshot)
+			}
+
+			// No temporary snapshot is left behind
+			if files, _ := filepath.Glob(filepath.Join(dir, "*.tmp")); len(files) != 0 {
+				t.Errorf("Save(), got temporary files %v left, want none", files)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package dispatch
+
+import (
+	"context"
+	"math/rand"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/clock"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/model"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Availability of a courier
+const COURIER_AVAILABLE string = "available"
+const COURIER_EN_ROUTE string = "enRoute"
+const COURIER_WAITING string = "waiting"
+const COURIER_DELIVERING string = "delivering"
+
+// Courier of the fleet; once sent for an order, it travels to the kitchen, picks up the order the dispatcher hands
+// over and delivers it before it is available again
+type Courier struct {
+	ID int
+
+	// Draws the time the courier takes to arrive at the kitchen, uniformly within [minDelay, maxDelay]
+	rng      *rand.Rand
+	minDelay time.Duration
+	maxDelay time.Duration
+
+	// Time the courier takes to deliver an order and come back
+	delivery time.Duration
+
+	// Assignments and orders to pick up handed over by the dispatcher
+	assignments chan assignment
+	pickups     chan pickup
+
+	locker sync.Mutex
+	status string
+
+	// Order the courier is sent for, then the order it picks up; nil if the courier is available
+	order *model.Order
+
+	// Time the courier was sent
+	assignedTime time.Time
+}
+
+// readyOrder is an order ready to be picked up since the given time
+type readyOrder struct {
+	order     model.Order
+	readyTime time.Time
+}
+
+// assignment sends a courier for an order, with the timer of its arrival at the kitchen. The courier is called off
+// once recall is closed
+type assignment struct {
+	sentFor readyOrder
+	arrival clock.Timer
+	recall  chan struct{}
+}
+
+// arrival is a courier arrived at the kitchen at the given time
+type arrival struct {
+	courier     *Courier
+	sentFor     readyOrder
+	recall      chan struct{}
+	arrivedTime time.Time
+}
+
+// isRecalled tells whether the courier was called off before the dispatcher handled its arrival
+func (courierArrival arrival) isRecalled() bool {
+	select {
+	case <-courierArrival.recall:
+		return true
+	default:
+		return false
+	}
+}
+
+// pickup hands over an order to a courier arrived at the kitchen
+type pickup struct {
+	readyOrder
+	arrivedTime time.Time
+}
+
+// Status gives the availability of the courier and its order, nil if it is available
+func (courier *Courier) Status() (string, *model.Order) {
+	courier.locker.Lock()
+	defer courier.locker.Unlock()
+
+	return courier.status, courier.order
+}
+
+// assign sends the available courier to the kitchen for an order. It gives the assignment to hand over to the
+// courier, with the timer of its arrival
+func (courier *Courier) assign(sentFor readyOrder, kitchenClock clock.Clock) assignment {
+	courier.locker.Lock()
+	defer courier.locker.Unlock()
+
+	courier.status = COURIER_EN_ROUTE
+	courier.order = &sentFor.order
+	courier.assignedTime = kitchenClock.Now()
+
+	delay := courier.minDelay
+	if spread := courier.maxDelay - courier.minDelay; spread > 0 {
+		delay += time.Duration(courier.rng.Int63n(int64(spread/time.Second)+1)) * time.Second
+	}
+	return assignment{sentFor: sentFor, arrival: kitchenClock.NewTimer(delay), recall: make(chan struct{})}
+}
+
+// setStatus sets the availability of the courier and its order; an available courier has no order. It gives the
+// time elapsed since the courier was sent
+func (courier *Courier) setStatus(status string, order *model.Order, now time.Time) time.Duration {
+	courier.locker.Lock()
+	defer courier.locker.Unlock()
+
+	courier.status = status
+	if order != nil || status == COURIER_AVAILABLE {
+		courier.order = order
+	}
+	return now.Sub(courier.assignedTime)
+}
+
+// run - worker picking up the orders the courier is handed over once arrived at the kitchen; the courier is
+// returned to the dispatcher once it delivered its order, or once it is called off before picking up an order
+func (courier *Courier) run(ctx context.Context, service *Service) {
+	kitchenClock := service.bus.Clock
+	for {
+		var task assignment
+		select {
+		case <-ctx.Done():
+			return
+		case task = <-courier.assignments:
+			kitchenClock.Done()
+		}
+
+		var arrivedTime time.Time
+		select {
+		case <-ctx.Done():
+			task.arrival.Stop()
+			zap.S().Infof("Dispatch: Courier %d sent for Order '%s'(%s) called off", courier.ID, task.sentFor.order.Name, task.sentFor.order.ID)
+			return
+		case <-task.recall:
+			task.arrival.Stop()
+			courier.recalled(ctx, service, task)
+			continue
+		case arrivedTime = <-task.arrival.C():
+		}
+
+		// Wait at the kitchen for the dispatcher to hand over an order
+		service.report.RecordCourierDelay(courier.setStatus(COURIER_WAITING, nil, arrivedTime))
+		kitchenClock.Begin()
+		select {
+		case <-ctx.Done():
+			return
+		case service.arrived <- arrival{courier: courier, sentFor: task.sentFor, recall: task.recall, arrivedTime: arrivedTime}:
+		}
+		kitchenClock.Done()
+
+		var handed pickup
+		select {
+		case <-ctx.Done():
+			return
+		case <-task.recall:
+			courier.recalled(ctx, service, task)
+			continue
+		case handed = <-courier.pickups:
+		}
+
+		now := kitchenClock.Now()
+		courier.setStatus(COURIER_DELIVERING, &handed.order, now)
+		if service.pickUp(courier, handed.order) {
+			service.report.RecordPickupWait(service.strategy, now.Sub(handed.readyTime), now.Sub(handed.arrivedTime))
+		}
+
+		// The delivery timer is created before the pickup is done, so the clock does not move past it
+		if courier.delivery > 0 {
+			delivered := kitchenClock.NewTimer(courier.delivery)
+			kitchenClock.Done()
+
+			select {
+			case <-ctx.Done():
+				delivered.Stop()
+				return
+			case <-delivered.C():
+			}
+		}
+
+		courier.returnTo(ctx, service)
+	}
+}
+
+// recalled returns the courier called off to the dispatcher
+func (courier *Courier) recalled(ctx context.Context, service *Service, task assignment) {
+	zap.S().Infof("Dispatch: Courier %d sent for Order '%s'(%s) called off; the order will not be picked up", courier.ID, task.sentFor.order.Name, task.sentFor.order.ID)
+	courier.returnTo(ctx, service)
+}
+
+// returnTo makes the courier available again and returns it to the dispatcher; the unit of work the courier was
+// handling is done once it is returned
+func (courier *Courier) returnTo(ctx context.Context, service *Service) {
+	kitchenClock := service.bus.Clock
+	busy := courier.setStatus(COURIER_AVAILABLE, nil, kitchenClock.Now())
+	service.report.RecordCourierTrip(courier.ID, busy)
+
+	kitchenClock.Begin()
+	select {
+	case <-ctx.Done():
+		return
+	case service.returned <- courier:
+	}
+	kitchenClock.Done()
+}
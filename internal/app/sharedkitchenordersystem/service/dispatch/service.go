@@ -1,94 +1,280 @@
 package dispatch
 
 import (
+	"context"
 	"math/rand"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/bus"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/config"
 	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/model"
 	repo "sharedkitchenordersystem/internal/app/sharedkitchenordersystem/repository/shelf"
 	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/service/supervisor"
-	"strings"
 	"time"
 
 	"go.uber.org/zap"
 )
 
-// Start starts the dispatch service
-func Start(noOfOrdersToRead int) {
-	internalProcess()
+// Service sends the couriers of the fleet for the orders ready and hands over the orders to the couriers arrived at
+// the kitchen, according to the dispatch strategy; the couriers pick up the orders concurrently
+type Service struct {
+	bus     *bus.Bus
+	shelves *repo.Repository
+	report  *supervisor.ReportBook
+
+	// Dispatch strategy, one of config.DispatchStrategies
+	strategy string
+
+	fleet []*Courier
+
+	// Couriers arrived at the kitchen, and returning to the dispatcher once they delivered their order
+	arrived  chan arrival
+	returned chan *Courier
+}
+
+// New creates the dispatch service with a fleet of couriers arriving within the given delay range, matched with the
+// orders by the given dispatch strategy. Each courier draws its delays from its own generator seeded from the given one
+func New(eventBus *bus.Bus, shelves *repo.Repository, report *supervisor.ReportBook, courier config.CourierConfig, strategy string, rng *rand.Rand) *Service {
+	service := &Service{
+		bus:      eventBus,
+		shelves:  shelves,
+		report:   report,
+		strategy: strategy,
+		arrived:  make(chan arrival, courier.FleetSize),
+		returned: make(chan *Courier, courier.FleetSize),
+	}
+
+	for id := 1; id <= courier.FleetSize; id++ {
+		service.fleet = append(service.fleet, &Courier{
+			ID:          id,
+			rng:         rand.New(rand.NewSource(rng.Int63())),
+			minDelay:    time.Duration(courier.MinDelayS) * time.Second,
+			maxDelay:    time.Duration(courier.MaxDelayS) * time.Second,
+			delivery:    time.Duration(courier.DeliveryS) * time.Second,
+			assignments: make(chan assignment, 1),
+			pickups:     make(chan pickup, 1),
+			status:      COURIER_AVAILABLE,
+		})
+	}
+	report.SetFleetSize(courier.FleetSize)
+	return service
+}
+
+// Start starts the dispatch service and the couriers; they stop once the context is done
+func (service *Service) Start(ctx context.Context) {
+	for _, courier := range service.fleet {
+		go courier.run(ctx, service)
+	}
+	service.internalProcess(ctx)
+}
+
+// Fleet gives the couriers of the fleet
+func (service *Service) Fleet() []*Courier {
+	return service.fleet
 }
 
-// internalProcess processes the messages from the dispatch channel
-func internalProcess() {
+// dispatcher is the state of the dispatch worker
+type dispatcher struct {
+	// Orders a courier is to be sent for once one is available, in the order they are ready
+	requests []readyOrder
+
+	// Couriers available, the one available for the longest time first
+	available []*Courier
+
+	// With the fifo strategy, the orders ready no courier picked up yet and the couriers waiting at the kitchen for
+	// one, the longest waiting first
+	ready     []readyOrder
+	atKitchen []arrival
+
+	// Recalls of the couriers en route, by id of the order they were sent for
+	enRoute map[string]chan struct{}
+}
+
+// internalProcess processes the messages from the dispatch and cancel pickup channels and the couriers. A courier is
+// sent for every order ready, the courier available for the longest time first; the orders waiting for a courier are
+// queued. Once arrived, a courier picks up the order it was sent for with the matched strategy, or the order waiting
+// the longest with the fifo strategy. A courier sent for an order no one is to pick up anymore is called off
+func (service *Service) internalProcess(ctx context.Context) {
 	go func() {
+		state := &dispatcher{available: append([]*Courier{}, service.fleet...), enRoute: make(map[string]chan struct{})}
+
 		for {
 			select {
-			case orderReq, isOpen := <-supervisor.DispatchChannel:
-				if !isOpen {
-					supervisor.DispatchChannel = nil
-					break
+			case <-ctx.Done():
+				if len(state.requests) > 0 {
+					zap.S().Infof("Dispatch: '%d' orders left waiting for a courier", len(state.requests))
 				}
-				// Send order ready event
-				rand.Seed(time.Now().UnixNano())
+				zap.S().Info("Dispatch: Stopped")
+				return
+			case orderReq := <-service.bus.DispatchChannel:
+				service.orderReady(state, readyOrder{order: orderReq, readyTime: service.bus.Clock.Now()})
+				service.bus.Handled()
+			case orderId := <-service.bus.CancelPickupChannel:
+				service.cancelPickup(state, orderId)
+				service.bus.Handled()
+			case courierArrival := <-service.arrived:
+				service.courierArrived(state, courierArrival)
+				service.bus.Clock.Done()
+			case courier := <-service.returned:
+				state.available = append(state.available, courier)
+				service.assign(state)
+				service.bus.Clock.Done()
+			}
+		}
+	}()
+}
 
-				// Courier arrived randomly after this time
-				time.Sleep(time.Duration(rand.Intn(6-2+1)+2) * time.Second)
-				//time.Sleep(6 * time.Second)
+// orderReady hands over an order ready to a courier waiting at the kitchen with the fifo strategy, or sends a
+// courier for it
+func (service *Service) orderReady(state *dispatcher, ready readyOrder) {
+	if service.report.IsCancelled(ready.order.ID) {
+		zap.S().Infof("Dispatch: Order '%s'(%s) is cancelled; no courier sent", ready.order.Name, ready.order.ID)
+		return
+	}
 
-				// Courier picking up the order
-				shelf, err := repo.ShelfFactory(orderReq.Temp)
+	if service.strategy == config.DISPATCH_FIFO {
+		if len(state.atKitchen) > 0 {
+			courierArrival := state.atKitchen[0]
+			state.atKitchen = state.atKitchen[1:]
+			service.handOver(courierArrival, ready)
+			return
+		}
+		state.ready = append(state.ready, ready)
+	}
 
-				if err != nil {
-					zap.S().Infof("Dispatch: Invalid Order '%s'(%s); ignored unknown order item temperature '%s'", orderReq.ID, orderReq.Name, orderReq.Temp)
-					continue
-				}
+	state.requests = append(state.requests, ready)
+	service.assign(state)
+}
 
-				// If item not available in normal racks, check in overflow rack
-				isPresent := shelf.IsPresent(orderReq.ID)
-				isOrderDispatched := false
-				pickedUpShelfType := ""
-
-				// Check if present in normal shelves
-				if isPresent {
-					shelf.Delete(orderReq.ID)
-					isOrderDispatched = true
-					pickedUpShelfType = orderReq.Temp
-					zap.S().Infof("Dispatch: Order '%s'(%s) removed from shelf '%s' by courier", orderReq.ID, orderReq.Name, orderReq.Temp)
-				} else {
-					// Check if present in overflow shelf
-					overflownShelf := repo.OverflowShelf[strings.ToLower(orderReq.Temp)]
-					if isPresent = overflownShelf.IsPresent(orderReq.ID); isPresent {
-						overflownShelf.Delete(orderReq.ID)
-						isOrderDispatched = true
-						pickedUpShelfType = model.OVERFLOW
-					}
-				}
+// courierArrived hands over an order to a courier arrived at the kitchen: the order it was sent for with the matched
+// strategy, or the order waiting the longest on the shelves with the fifo strategy. With the fifo strategy, the
+// courier waits at the kitchen if no order is ready
+func (service *Service) courierArrived(state *dispatcher, courierArrival arrival) {
+	// The courier called off is returning to the dispatcher
+	if courierArrival.isRecalled() {
+		return
+	}
+	delete(state.enRoute, courierArrival.sentFor.order.ID)
 
-				if isOrderDispatched {
-					zap.S().Infof("Dispatch: Courier picked up Order '%s'(%s) from '%s' shelf ", orderReq.Name, orderReq.ID, pickedUpShelfType)
-
-					// Send OrderStatus event
-					supervisor.SupervisorChannel <- model.OrderStatus{OrderId: orderReq.ID, Status: model.ORDER_PICKED}
-
-					// Once courier picked up the order (shelf item), send new space available event
-					if pickedUpShelfType != model.OVERFLOW {
-						supervisor.NewSpaceAvailableChannel <- orderReq.Temp
-						zap.S().Infof("Dispatch: New space available in shelf for '%s'", orderReq.Temp)
-					}
-				} else {
-					// Order could not be found, probably discarded - should be confirmed discarded/expired with supervisor
-					var status string = "Not Available"
-					if supervisor.Report.IsTrashed(orderReq.ID) {
-						status = model.ORDER_EXPIRED
-					} else if supervisor.Report.IsEvicted(orderReq.ID) {
-						status = model.ORDER_EVICTED
-					}
-
-					zap.S().Infof("Dispatch: Courier could not find the Order '%s'(%s) in shelves; it is '%s'", orderReq.Name, orderReq.ID, status)
-				}
-			}
+	if service.strategy != config.DISPATCH_FIFO {
+		service.handOver(courierArrival, courierArrival.sentFor)
+		return
+	}
 
-			if supervisor.DispatchChannel == nil {
-				break
-			}
+	// Orders expired, evicted or cancelled meanwhile are no longer ready; they were dropped when their pickup was
+	// cancelled. An order ready may not be on a shelf for a moment, while it is stored or moved between shelves
+	if len(state.ready) > 0 {
+		ready := state.ready[0]
+		state.ready = state.ready[1:]
+		service.handOver(courierArrival, ready)
+		return
+	}
+
+	zap.S().Infof("Dispatch: Courier %d waiting at the kitchen for an order", courierArrival.courier.ID)
+	state.atKitchen = append(state.atKitchen, courierArrival)
+}
+
+// cancelPickup drops an order no one is to pick up anymore from the orders waiting for a courier, and calls off the
+// courier sent for it if it is still en route
+func (service *Service) cancelPickup(state *dispatcher, orderId string) {
+	state.requests = withoutOrder(state.requests, orderId)
+	state.ready = withoutOrder(state.ready, orderId)
+
+	if recall, isPresent := state.enRoute[orderId]; isPresent {
+		delete(state.enRoute, orderId)
+
+		// The courier handles the recall as a unit of work
+		service.bus.Clock.Begin()
+		close(recall)
+	}
+}
+
+// withoutOrder gives the orders but the one with the given id
+func withoutOrder(orders []readyOrder, orderId string) []readyOrder {
+	kept := orders[:0]
+	for _, ready := range orders {
+		if ready.order.ID != orderId {
+			kept = append(kept, ready)
 		}
-	}()
+	}
+	return kept
+}
+
+// handOver hands over an order to a courier arrived at the kitchen
+func (service *Service) handOver(courierArrival arrival, ready readyOrder) {
+	service.bus.Clock.Begin()
+	courierArrival.courier.pickups <- pickup{readyOrder: ready, arrivedTime: courierArrival.arrivedTime}
+}
+
+// assign sends the available couriers for the orders waiting for one, in turn
+func (service *Service) assign(state *dispatcher) {
+	for len(state.requests) > 0 && len(state.available) > 0 {
+		ready, courier := state.requests[0], state.available[0]
+		state.requests, state.available = state.requests[1:], state.available[1:]
+
+		// The arrival timer is created before the assignment is handed over to the courier
+		task := courier.assign(ready, service.bus.Clock)
+		state.enRoute[ready.order.ID] = task.recall
+		zap.S().Infof("Dispatch: Courier %d sent for Order '%s'(%s)", courier.ID, ready.order.Name, ready.order.ID)
+		service.bus.Clock.Begin()
+		courier.assignments <- task
+	}
+}
+
+// pickUp picks up the order from the shelves once the courier arrived. It tells whether the order was picked up
+func (service *Service) pickUp(courier *Courier, orderReq model.Order) bool {
+	// Courier picking up the order
+	shelf, err := service.shelves.ShelfFactory(orderReq.Temp)
+
+	if err != nil {
+		zap.S().Infof("Dispatch: Invalid Order '%s'(%s); ignored unknown order item temperature '%s'", orderReq.ID, orderReq.Name, orderReq.Temp)
+		return false
+	}
+
+	// If item not available in normal racks, check in overflow rack
+	isOrderDispatched := false
+	pickedUpShelfType := ""
+	var item model.ShelfItem
+
+	// Check if present in normal shelves; the order is not moved between the shelves while looking for it
+	service.shelves.Transfer(func() {
+		if item, err = shelf.Take(orderReq.ID); err == nil {
+			isOrderDispatched = true
+			pickedUpShelfType = orderReq.Temp
+		} else if item, err = service.shelves.OverflowShelf.Take(orderReq.ID); err == nil {
+			// Present in overflow shelf
+			isOrderDispatched = true
+			pickedUpShelfType = model.OVERFLOW
+		}
+	})
+	if pickedUpShelfType == orderReq.Temp {
+		zap.S().Infof("Dispatch: Order '%s'(%s) removed from shelf '%s' by courier", orderReq.ID, orderReq.Name, orderReq.Temp)
+	}
+
+	if isOrderDispatched {
+		zap.S().Infof("Dispatch: Courier %d picked up Order '%s'(%s) from '%s' shelf ", courier.ID, orderReq.Name, orderReq.ID, pickedUpShelfType)
+		now := service.bus.Clock.Now()
+		service.report.RecordValue(orderReq.ID, item.Value(now))
+
+		// Send OrderStatus event
+		service.bus.ReportStatus(model.OrderStatus{OrderId: orderReq.ID, Status: model.ORDER_PICKED, Time: now, Shelf: pickedUpShelfType})
+
+		// Once courier picked up the order (shelf item), send new space available event
+		if pickedUpShelfType != model.OVERFLOW {
+			service.bus.NewSpaceAvailable(orderReq.Temp)
+			zap.S().Infof("Dispatch: New space available in shelf for '%s'", orderReq.Temp)
+		}
+	} else {
+		// Order could not be found, probably discarded - should be confirmed discarded/expired with supervisor
+		var status string = "Not Available"
+		if service.report.IsCancelled(orderReq.ID) {
+			status = model.ORDER_CANCELLED
+		} else if service.report.IsTrashed(orderReq.ID) {
+			status = model.ORDER_EXPIRED
+		} else if service.report.IsEvicted(orderReq.ID) {
+			status = model.ORDER_EVICTED
+		}
+
+		zap.S().Infof("Dispatch: Courier %d could not find the Order '%s'(%s) in shelves; it is '%s'", courier.ID, orderReq.Name, orderReq.ID, status)
+	}
+
+	return isOrderDispatched
 }
@@ -1,33 +1,334 @@
 package dispatch
 
-import "testing"
+import (
+	"context"
+	"math/rand"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/bus"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/clock"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/config"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/model"
+	repo "sharedkitchenordersystem/internal/app/sharedkitchenordersystem/repository/shelf"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/service/supervisor"
+	"testing"
+	"time"
+)
 
-func TestStart(t *testing.T) {
-	type args struct {
-		noOfOrdersToRead int
-	}
+func TestService_Start(t *testing.T) {
 	tests := []struct {
-		name string
-		args args
+		name           string
+		order          model.Order
+		storeOnShelf   string
+		wantStatus     string
+		wantShelf      string
+		wantSpaceEvent bool
 	}{
-		// TODO: Add test cases.
+		{
+			name:           "TestService_Start_OrderOnShelf_Picked",
+			order:          model.Order{ID: "1", Name: "chicken", Temp: model.HOT, ShelfLife: 20, DecayRate: 1},
+			storeOnShelf:   model.HOT,
+			wantStatus:     model.ORDER_PICKED,
+			wantShelf:      model.HOT,
+			wantSpaceEvent: true,
+		},
+		{
+			name:         "TestService_Start_OrderOnOverflowShelf_Picked",
+			order:        model.Order{ID: "2", Name: "juice", Temp: model.COLD, ShelfLife: 20, DecayRate: 1},
+			storeOnShelf: model.OVERFLOW,
+			wantStatus:   model.ORDER_PICKED,
+			wantShelf:    model.OVERFLOW,
+		},
+		{
+			name:  "TestService_Start_OrderNotOnShelves_NotPicked",
+			order: model.Order{ID: "3", Name: "ice cream", Temp: model.FROZEN, ShelfLife: 20, DecayRate: 1},
+		},
 	}
+
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			Start(tt.args.noOfOrdersToRead)
+			eventBus := bus.New(10, clock.NewWallClock())
+			shelves := repo.New(config.Default().Shelves)
+			report := supervisor.New(eventBus, shelves, config.Default().IdleTimeoutS).Report
+
+			item := model.NewShelfItem(tt.order, time.Now(), 1)
+			switch tt.storeOnShelf {
+			case model.OVERFLOW:
+				shelves.OverflowShelf.Push(item)
+			case "":
+			default:
+				shelf, _ := shelves.ShelfFactory(tt.storeOnShelf)
+				shelf.Push(item)
+			}
+
+			ctx, stop := context.WithCancel(context.Background())
+			defer stop()
+			New(eventBus, shelves, report, config.CourierConfig{FleetSize: 1}, config.DISPATCH_MATCHED, rand.New(rand.NewSource(1))).Start(ctx)
+			eventBus.DispatchChannel <- tt.order
+
+			select {
+			case status := <-eventBus.SupervisorChannel:
+				if tt.wantStatus == "" {
+					t.Fatalf("Start(), got status %s for order %s, want none", status.Status, status.OrderId)
+				}
+				if status.OrderId != tt.order.ID || status.Status != tt.wantStatus || status.Shelf != tt.wantShelf {
+					t.Errorf("Start(), got order %s %s from shelf %s, want %s from shelf %s", status.OrderId, status.Status, status.Shelf, tt.wantStatus, tt.wantShelf)
+				}
+			case <-time.After(200 * time.Millisecond):
+				if tt.wantStatus != "" {
+					t.Fatalf("Start(), no status reported for order %s, want %s", tt.order.ID, tt.wantStatus)
+				}
+			}
+
+			select {
+			case temp := <-eventBus.NewSpaceAvailableChannel:
+				if !tt.wantSpaceEvent || temp != tt.order.Temp {
+					t.Errorf("Start(), got new space available on shelf %s, want space event %v", temp, tt.wantSpaceEvent)
+				}
+			case <-time.After(100 * time.Millisecond):
+				if tt.wantSpaceEvent {
+					t.Errorf("Start(), no new space available event for shelf %s", tt.order.Temp)
+				}
+			}
+
+			if _, _, isOnShelf := shelves.Locate(tt.order.ID); isOnShelf {
+				t.Errorf("Start(), order %s still on the shelves", tt.order.ID)
+			}
 		})
 	}
 }
 
-func Test_internalProcess(t *testing.T) {
+func TestService_Fleet_OrdersWaitForAvailableCourier(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	virtualClock := clock.NewVirtualClock(start)
+	eventBus := bus.New(10, virtualClock)
+	shelves := repo.New(config.Default().Shelves)
+	report := supervisor.New(eventBus, shelves, config.Default().IdleTimeoutS).Report
+
+	// Orders on the overflow shelf free no space, so couriers only report the pickups
+	orders := []model.Order{
+		{ID: "1", Name: "chicken", Temp: model.HOT, ShelfLife: 300, DecayRate: 0},
+		{ID: "2", Name: "juice", Temp: model.COLD, ShelfLife: 300, DecayRate: 0},
+		{ID: "3", Name: "ice cream", Temp: model.FROZEN, ShelfLife: 300, DecayRate: 0},
+	}
+	for _, orderReq := range orders {
+		shelves.OverflowShelf.Push(model.NewShelfItem(orderReq, start, 2))
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	virtualClock.Begin()
+	service := New(eventBus, shelves, report, config.CourierConfig{MinDelayS: 1, MaxDelayS: 1, FleetSize: 2, DeliveryS: 2}, config.DISPATCH_MATCHED, rand.New(rand.NewSource(1)))
+	service.Start(ctx)
+	for _, orderReq := range orders {
+		eventBus.Dispatch(orderReq)
+	}
+	virtualClock.Done()
+
+	// Two couriers arrive together; the third order waits for the first courier back from its delivery
+	wantPickedAfter := map[string]time.Duration{"1": time.Second, "2": time.Second, "3": 4 * time.Second}
+	for range orders {
+		select {
+		case status := <-eventBus.SupervisorChannel:
+			if elapsed := status.Time.Sub(start); status.Status != model.ORDER_PICKED || elapsed != wantPickedAfter[status.OrderId] {
+				t.Errorf("Start(), got order %s %s after %s, want picked after %s", status.OrderId, status.Status, elapsed, wantPickedAfter[status.OrderId])
+			}
+			eventBus.Handled()
+		case <-time.After(time.Second):
+			t.Fatalf("Start(), got no status reported, want every order picked")
+		}
+	}
+
+	// Every courier is available again once it delivered its order
+	for _, courier := range service.Fleet() {
+		for i := 0; i < 100; i++ {
+			if status, _ := courier.Status(); status == COURIER_AVAILABLE {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if status, orderReq := courier.Status(); status != COURIER_AVAILABLE || orderReq != nil {
+			t.Errorf("Status(), got courier %d %s, want available", courier.ID, status)
+		}
+	}
+}
+
+func TestService_Strategy(t *testing.T) {
 	tests := []struct {
-		name string
+		name              string
+		strategy          string
+		wantCourierStatus string
+		wantPickedAfter   time.Duration
 	}{
-		// TODO: Add test cases.
+		{
+			name:              "TestService_Strategy_Matched_CourierSentForEveryOrder",
+			strategy:          config.DISPATCH_MATCHED,
+			wantCourierStatus: COURIER_AVAILABLE,
+			wantPickedAfter:   2 * time.Second,
+		},
+		{
+			name:              "TestService_Strategy_Fifo_CourierSentForEveryOrder",
+			strategy:          config.DISPATCH_FIFO,
+			wantCourierStatus: COURIER_AVAILABLE,
+			wantPickedAfter:   2 * time.Second,
+		},
 	}
+
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			internalProcess()
+			start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+			virtualClock := clock.NewVirtualClock(start)
+			eventBus := bus.New(10, virtualClock)
+			shelves := repo.New(config.Default().Shelves)
+			report := supervisor.New(eventBus, shelves, config.Default().IdleTimeoutS).Report
+
+			ctx, stop := context.WithCancel(context.Background())
+			defer stop()
+			virtualClock.Begin()
+			service := New(eventBus, shelves, report, config.CourierConfig{MinDelayS: 1, MaxDelayS: 1, FleetSize: 1}, tt.strategy, rand.New(rand.NewSource(1)))
+			service.Start(ctx)
+
+			// The first order is gone from the shelves by the time the courier arrives
+			eventBus.Dispatch(model.Order{ID: "1", Name: "chicken", Temp: model.HOT, ShelfLife: 300, DecayRate: 0})
+			virtualClock.Done()
+
+			courier := service.Fleet()[0]
+			for i := 0; i < 100; i++ {
+				if status, _ := courier.Status(); status == tt.wantCourierStatus {
+					break
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			if status, _ := courier.Status(); status != tt.wantCourierStatus {
+				t.Fatalf("Status(), got courier %s once arrived, want %s", status, tt.wantCourierStatus)
+			}
+
+			// Orders on the overflow shelf free no space, so the courier only reports the pickup
+			next := model.Order{ID: "2", Name: "juice", Temp: model.COLD, ShelfLife: 300, DecayRate: 0}
+			shelves.OverflowShelf.Push(model.NewShelfItem(next, virtualClock.Now(), 2))
+			eventBus.Dispatch(next)
+
+			select {
+			case status := <-eventBus.SupervisorChannel:
+				if elapsed := status.Time.Sub(start); status.OrderId != next.ID || status.Status != model.ORDER_PICKED || elapsed != tt.wantPickedAfter {
+					t.Errorf("Start(), got order %s %s after %s, want order %s picked after %s", status.OrderId, status.Status, elapsed, next.ID, tt.wantPickedAfter)
+				}
+				eventBus.Handled()
+			case <-time.After(time.Second):
+				t.Fatalf("Start(), got no status reported, want order %s picked", next.ID)
+			}
 		})
 	}
 }
+
+func TestService_Fifo_CourierWaitingPicksNextOrder(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	virtualClock := clock.NewVirtualClock(start)
+	eventBus := bus.New(10, virtualClock)
+	shelves := repo.New(config.Default().Shelves)
+	report := supervisor.New(eventBus, shelves, config.Default().IdleTimeoutS).Report
+
+	// Orders on the overflow shelf free no space, so the couriers only report the pickups
+	first := model.Order{ID: "1", Name: "chicken", Temp: model.HOT, ShelfLife: 300, DecayRate: 0}
+	second := model.Order{ID: "2", Name: "juice", Temp: model.COLD, ShelfLife: 300, DecayRate: 0}
+	next := model.Order{ID: "3", Name: "ice cream", Temp: model.FROZEN, ShelfLife: 300, DecayRate: 0}
+	shelves.OverflowShelf.Push(model.NewShelfItem(first, start, 2))
+	shelves.OverflowShelf.Push(model.NewShelfItem(second, start, 2))
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	virtualClock.Begin()
+	service := New(eventBus, shelves, report, config.CourierConfig{MinDelayS: 1, MaxDelayS: 1, FleetSize: 2}, config.DISPATCH_FIFO, rand.New(rand.NewSource(1)))
+
+	// The courier sent for the first order arrives after the one sent for the second order
+	slow := service.Fleet()[0]
+	slow.minDelay, slow.maxDelay = 3*time.Second, 3*time.Second
+	service.Start(ctx)
+	eventBus.Dispatch(first)
+	eventBus.Dispatch(second)
+	virtualClock.Done()
+
+	// The first courier arrived picks the order waiting the longest
+	select {
+	case status := <-eventBus.SupervisorChannel:
+		if elapsed := status.Time.Sub(start); status.OrderId != first.ID || status.Status != model.ORDER_PICKED || elapsed != time.Second {
+			t.Errorf("Start(), got order %s %s after %s, want order %s picked after 1s", status.OrderId, status.Status, elapsed, first.ID)
+		}
+
+		// The second order is cancelled once no courier is en route for it
+		eventBus.CancelPickup(second.ID)
+		eventBus.Handled()
+	case <-time.After(time.Second):
+		t.Fatalf("Start(), got no status reported, want order %s picked", first.ID)
+	}
+
+	for i := 0; i < 100; i++ {
+		if status, _ := slow.Status(); status == COURIER_WAITING {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if status, _ := slow.Status(); status != COURIER_WAITING {
+		t.Fatalf("Status(), got courier %s once arrived with no order ready, want waiting", status)
+	}
+
+	shelves.OverflowShelf.Push(model.NewShelfItem(next, virtualClock.Now(), 2))
+	eventBus.Dispatch(next)
+
+	select {
+	case status := <-eventBus.SupervisorChannel:
+		if elapsed := status.Time.Sub(start); status.OrderId != next.ID || status.Status != model.ORDER_PICKED || elapsed != 3*time.Second {
+			t.Errorf("Start(), got order %s %s after %s, want order %s picked by the courier waiting after 3s", status.OrderId, status.Status, elapsed, next.ID)
+		}
+		eventBus.Handled()
+	case <-time.After(time.Second):
+		t.Fatalf("Start(), got no status reported, want order %s picked", next.ID)
+	}
+}
+
+func TestService_CancelPickup_CourierRecalled(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	virtualClock := clock.NewVirtualClock(start)
+	eventBus := bus.New(10, virtualClock)
+	shelves := repo.New(config.Default().Shelves)
+	report := supervisor.New(eventBus, shelves, config.Default().IdleTimeoutS).Report
+
+	// Orders on the overflow shelf free no space, so the courier only reports the pickup
+	cancelled := model.Order{ID: "1", Name: "chicken", Temp: model.HOT, ShelfLife: 300, DecayRate: 0}
+	next := model.Order{ID: "2", Name: "juice", Temp: model.COLD, ShelfLife: 300, DecayRate: 0}
+	shelves.OverflowShelf.Push(model.NewShelfItem(cancelled, start, 2))
+	shelves.OverflowShelf.Push(model.NewShelfItem(next, start, 2))
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	virtualClock.Begin()
+	service := New(eventBus, shelves, report, config.CourierConfig{MinDelayS: 10, MaxDelayS: 10, FleetSize: 1}, config.DISPATCH_MATCHED, rand.New(rand.NewSource(1)))
+	service.Start(ctx)
+	eventBus.Dispatch(cancelled)
+
+	// The clock does not move until the courier is en route for the first order
+	courier := service.Fleet()[0]
+	for i := 0; i < 100; i++ {
+		if status, orderReq := courier.Status(); status == COURIER_EN_ROUTE && orderReq != nil && orderReq.ID == cancelled.ID {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The courier called off is sent for the next order right away
+	eventBus.CancelPickup(cancelled.ID)
+	eventBus.Dispatch(next)
+	virtualClock.Done()
+
+	select {
+	case status := <-eventBus.SupervisorChannel:
+		if elapsed := status.Time.Sub(start); status.OrderId != next.ID || status.Status != model.ORDER_PICKED || elapsed != 10*time.Second {
+			t.Errorf("Start(), got order %s %s after %s, want order %s picked after 10s", status.OrderId, status.Status, elapsed, next.ID)
+		}
+		eventBus.Handled()
+	case <-time.After(time.Second):
+		t.Fatalf("Start(), got no status reported, want order %s picked", next.ID)
+	}
+
+	if !shelves.OverflowShelf.IsPresent(cancelled.ID) {
+		t.Errorf("Start(), got order %s picked up, want left on the shelf", cancelled.ID)
+	}
+}
@@ -0,0 +1,200 @@
+package intake
+
+import (
+	"errors"
+	"fmt"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/bus"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/model"
+	repo "sharedkitchenordersystem/internal/app/sharedkitchenordersystem/repository/shelf"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/service/supervisor"
+	"sort"
+
+	"go.uber.org/zap"
+)
+
+// Kitchen admits the orders sent to it up to the room left in its queue
+type Kitchen interface {
+	// Admit admits orders if the queue has room for them; it tells whether they were admitted
+	Admit(orders int) bool
+
+	// WaitToAdmit admits orders once the queue has room for them
+	WaitToAdmit(orders int)
+
+	// Reserve admits orders once the queue has room for them without waiting; it gives nil if they are admitted
+	// right away, else a channel closed once they are admitted
+	Reserve(orders int) <-chan struct{}
+}
+
+// ErrKitchenBusy is given when the kitchen queue has no room for the orders submitted
+var ErrKitchenBusy = errors.New("Intake: Kitchen queue is full; retry later")
+
+// Service validates the orders taken in and sends the valid ones to the kitchen
+type Service struct {
+	bus     *bus.Bus
+	shelves *repo.Repository
+	report  *supervisor.ReportBook
+	kitchen Kitchen
+}
+
+// New creates the intake service validating orders against the given shelves and sending them to the given kitchen
+func New(eventBus *bus.Bus, shelves *repo.Repository, report *supervisor.ReportBook, kitchen Kitchen) *Service {
+	return &Service{bus: eventBus, shelves: shelves, report: report, kitchen: kitchen}
+}
+
+// Validate validates the orders against the temperatures the kitchen has shelves for and the ids of the orders
+// already accepted. It gives the valid orders and the reasons the other orders were rejected
+func (service *Service) Validate(orders []model.Order) ([]model.Order, []model.ValidationError) {
+	valid := make([]model.Order, 0, len(orders))
+	rejected := []model.ValidationError{}
+	ids := make(map[string]bool, len(orders))
+
+	for i, order := range orders {
+		reasons := order.Validate(service.shelves.ShelfTemperatures)
+		if order.ID != "" && (ids[order.ID] || service.report.IsAccepted(order.ID)) {
+			reasons = append(reasons, fmt.Sprintf("id '%s' is already taken", order.ID))
+		}
+		ids[order.ID] = true
+
+		if len(reasons) > 0 {
+			rejected = append(rejected, model.ValidationError{Index: i, OrderId: order.ID, Reasons: reasons})
+			continue
+		}
+		valid = append(valid, order)
+	}
+
+	return valid, rejected
+}
+
+// Reject reports the rejected orders to the supervisor
+func (service *Service) Reject(rejected []model.ValidationError) {
+	for _, rejection := range rejected {
+		zap.S().Infof("Intake: %s", rejection.Error())
+
+		// Send OrderStatus event
+		service.bus.ReportStatus(model.OrderStatus{OrderId: rejection.OrderId, Status: model.ORDER_REJECTED, Time: service.bus.Clock.Now()})
+	}
+}
+
+// Submit rejects the invalid orders and sends the valid ones to the kitchen once its queue has room for them
+func (service *Service) Submit(orders []model.Order) []model.ValidationError {
+	valid, rejected := service.accept(orders)
+
+	if len(valid) > 0 {
+		service.kitchen.WaitToAdmit(len(valid))
+		service.bus.Cook(valid)
+	}
+
+	return rejected
+}
+
+// SubmitLater rejects the invalid orders and sends the valid ones to the kitchen if its queue has room for them, without
+// waiting. Otherwise it gives the valid orders with a channel closed once the kitchen admitted them; they must be sent
+// with Cook then
+func (service *Service) SubmitLater(orders []model.Order) ([]model.Order, <-chan struct{}) {
+	valid, _ := service.accept(orders)
+	if len(valid) == 0 {
+		return nil, nil
+	}
+
+	if admitted := service.kitchen.Reserve(len(valid)); admitted != nil {
+		return valid, admitted
+	}
+	service.Cook(valid)
+	return nil, nil
+}
+
+// Cook sends orders admitted to the kitchen queue to the kitchen
+func (service *Service) Cook(orders []model.Order) {
+	service.bus.Cook(orders)
+}
+
+// TrySubmit takes in the orders only if every one of them is valid and the kitchen queue has room for them. Otherwise
+// none is taken in: the invalid orders are rejected and given, or ErrKitchenBusy is given
+func (service *Service) TrySubmit(orders []model.Order) ([]model.ValidationError, error) {
+	valid, rejected := service.Validate(orders)
+	if len(rejected) == 0 {
+		accepted, taken := service.report.AcceptIfNew(valid)
+		if len(taken) > 0 {
+			service.report.Withdraw(accepted)
+			rejected = takenIds(orders, taken)
+		}
+	}
+	if len(rejected) > 0 {
+		service.Reject(rejected)
+		return rejected, nil
+	}
+
+	if len(valid) > 0 && !service.kitchen.Admit(len(valid)) {
+		service.report.Withdraw(valid)
+		zap.S().Infof("Intake: Kitchen queue has no room for '%d' orders; refused", len(valid))
+		return nil, ErrKitchenBusy
+	}
+
+	if len(valid) > 0 {
+		service.bus.Cook(valid)
+	}
+
+	return nil, nil
+}
+
+// accept validates the orders, accepts the valid ones whose id is not taken yet and rejects the others. It gives the
+// orders accepted and the orders rejected
+func (service *Service) accept(orders []model.Order) ([]model.Order, []model.ValidationError) {
+	valid, rejected := service.Validate(orders)
+
+	// Another intake may have accepted the same id since the orders were validated
+	accepted, taken := service.report.AcceptIfNew(valid)
+	if len(taken) > 0 {
+		rejected = append(rejected, takenIds(orders, taken)...)
+		sort.Slice(rejected, func(i, j int) bool { return rejected[i].Index < rejected[j].Index })
+	}
+
+	// The valid orders are accepted first, so the rejection of an order repeating their id is not mistaken for theirs
+	service.Reject(rejected)
+	return accepted, rejected
+}
+
+// takenIds gives the rejections of the valid orders whose id was taken by an order accepted meanwhile; the ids of the
+// valid orders are unique among the orders submitted
+func takenIds(orders []model.Order, taken []model.Order) []model.ValidationError {
+	rejected := make([]model.ValidationError, 0, len(taken))
+	for _, order := range taken {
+		for i := range orders {
+			if orders[i].ID == order.ID {
+				rejected = append(rejected, model.ValidationError{Index: i, OrderId: order.ID, Reasons: []string{fmt.Sprintf("id '%s' is already taken", order.ID)}})
+				break
+			}
+		}
+	}
+	return rejected
+}
+
+// Cancel cancels an accepted order which is not completed yet. The order is taken off its shelf if it is on one,
+// making room on it as a pickup does; otherwise the service holding it drops it. The courier sent for it is called off
+func (service *Service) Cancel(orderId string) error {
+	if err := service.report.Cancel(orderId); err != nil {
+		return err
+	}
+	zap.S().Infof("Intake: Order '%s' cancelled", orderId)
+
+	if shelfType, _, isOnShelf := service.shelves.Locate(orderId); isOnShelf {
+		var shelf repo.IShelf = service.shelves.OverflowShelf
+		if shelfType != model.OVERFLOW {
+			shelf, _ = service.shelves.ShelfFactory(shelfType)
+		}
+
+		// The order may have been picked up or moved to another shelf meanwhile
+		if item, err := shelf.Take(orderId); err == nil {
+			zap.S().Infof("Intake: Order '%s'(%s) removed from shelf '%s'", item.Order.ID, item.Order.Name, shelfType)
+
+			// Send OrderStatus event
+			service.bus.ReportStatus(model.OrderStatus{OrderId: orderId, Status: model.ORDER_CANCELLED, Time: service.bus.Clock.Now(), Shelf: shelfType})
+			if shelfType != model.OVERFLOW {
+				service.bus.NewSpaceAvailable(shelfType)
+			}
+		}
+	}
+
+	service.bus.CancelPickup(orderId)
+	return nil
+}
@@ -0,0 +1,295 @@
+package intake
+
+import (
+	"context"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/bus"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/clock"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/config"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/model"
+	repo "sharedkitchenordersystem/internal/app/sharedkitchenordersystem/repository/shelf"
+	kitchenService "sharedkitchenordersystem/internal/app/sharedkitchenordersystem/service/kitchen"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/service/supervisor"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestService creates an intake service with the default shelves and a running supervisor
+func newTestService() (*Service, *bus.Bus, *supervisor.ReportBook) {
+	eventBus := bus.New(10, clock.NewWallClock())
+	shelves := repo.New(config.Default().Shelves)
+	s := supervisor.New(eventBus, shelves, config.Default().IdleTimeoutS)
+	s.Start(context.Background())
+	return New(eventBus, shelves, s.Report, kitchenService.New(eventBus, shelves, s.Report, config.Default().Kitchen)), eventBus, s.Report
+}
+
+func TestValidate(t *testing.T) {
+	service, _, _ := newTestService()
+
+	tests := []struct {
+		name        string
+		order       model.Order
+		wantReasons int
+	}{
+		{
+			name:  "TestValidate_ValidOrder_Accepted",
+			order: model.Order{ID: "1", Name: "Banana Split", Temp: model.FROZEN, ShelfLife: 20, DecayRate: 0.63},
+		},
+		{
+			name:  "TestValidate_ZeroDecayRate_Accepted",
+			order: model.Order{ID: "1", Name: "Water", Temp: model.COLD, ShelfLife: 20, DecayRate: 0},
+		},
+		{
+			name:        "TestValidate_MissingIdAndName_Rejected",
+			order:       model.Order{Temp: model.FROZEN, ShelfLife: 20, DecayRate: 0.63},
+			wantReasons: 2,
+		},
+		{
+			name:        "TestValidate_UnknownTemp_Rejected",
+			order:       model.Order{ID: "1", Name: "Banana Split", Temp: "Frozen", ShelfLife: 20, DecayRate: 0.63},
+			wantReasons: 1,
+		},
+		{
+			name:        "TestValidate_NonPositiveShelfLifeAndNegativeDecayRate_Rejected",
+			order:       model.Order{ID: "1", Name: "Banana Split", Temp: model.FROZEN, ShelfLife: 0, DecayRate: -0.1},
+			wantReasons: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			valid, rejected := service.Validate([]model.Order{tt.order})
+
+			if tt.wantReasons == 0 {
+				if len(valid) != 1 || len(rejected) != 0 {
+					t.Errorf("Validate(), got rejected %v, want order accepted", rejected)
+				}
+				return
+			}
+
+			if len(valid) != 0 || len(rejected) != 1 || len(rejected[0].Reasons) != tt.wantReasons {
+				t.Errorf("Validate(), got rejected %v, want %d reasons", rejected, tt.wantReasons)
+			}
+		})
+	}
+}
+
+func TestSubmit(t *testing.T) {
+	service, eventBus, report := newTestService()
+
+	rejected := service.Submit([]model.Order{
+		{ID: "1", Name: "Banana Split", Temp: model.FROZEN, ShelfLife: 20, DecayRate: 0.63},
+		{ID: "2", Name: "Pizza", Temp: "warm", ShelfLife: 300, DecayRate: 0.45},
+	})
+
+	if len(rejected) != 1 || rejected[0].OrderId != "2" || rejected[0].Index != 1 {
+		t.Errorf("Submit(), got rejected %v, want order 2 at index 1", rejected)
+	}
+
+	select {
+	case orders := <-eventBus.KitchenChannel:
+		if len(orders) != 1 || orders[0].ID != "1" {
+			t.Errorf("Submit(), got orders %v sent to kitchen, want order 1", orders)
+		}
+	default:
+		t.Errorf("Submit(), no orders sent to kitchen")
+	}
+
+	// Wait for the supervisor to record the rejection
+	for i := 0; i < 100; i++ {
+		if details, err := report.Lookup("2"); err == nil {
+			if details.Status != model.ORDER_REJECTED {
+				t.Errorf("Submit(), got order 2 status %s, want %s", details.Status, model.ORDER_REJECTED)
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("Submit(), order 2 rejection not reported")
+}
+
+func TestTrySubmit(t *testing.T) {
+	tests := []struct {
+		name         string
+		orders       []model.Order
+		wantRejected []int
+	}{
+		{
+			name: "TestTrySubmit_ValidOrders_Accepted",
+			orders: []model.Order{
+				{ID: "1", Name: "Banana Split", Temp: model.FROZEN, ShelfLife: 20, DecayRate: 0.63},
+				{ID: "2", Name: "Pizza", Temp: model.HOT, ShelfLife: 300, DecayRate: 0.45},
+			},
+		},
+		{
+			name: "TestTrySubmit_InvalidOrder_NoneAccepted",
+			orders: []model.Order{
+				{ID: "1", Name: "Banana Split", Temp: model.FROZEN, ShelfLife: 20, DecayRate: 0.63},
+				{ID: "2", Name: "Pizza", Temp: "warm", ShelfLife: 300, DecayRate: 0.45},
+			},
+			wantRejected: []int{1},
+		},
+		{
+			name: "TestTrySubmit_TakenId_NoneAccepted",
+			orders: []model.Order{
+				{ID: "1", Name: "Banana Split", Temp: model.FROZEN, ShelfLife: 20, DecayRate: 0.63},
+				{ID: "taken", Name: "Pizza", Temp: model.HOT, ShelfLife: 300, DecayRate: 0.45},
+			},
+			wantRejected: []int{1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, eventBus, report := newTestService()
+			report.Accept([]model.Order{{ID: "taken"}})
+
+			rejected, err := service.TrySubmit(tt.orders)
+			if err != nil {
+				t.Fatalf("TrySubmit(), got error %s, want none", err)
+			}
+
+			indexes := []int{}
+			for _, rejection := range rejected {
+				indexes = append(indexes, rejection.Index)
+			}
+			if len(indexes) != len(tt.wantRejected) || (len(indexes) > 0 && indexes[0] != tt.wantRejected[0]) {
+				t.Errorf("TrySubmit(), got rejected indexes %v, want %v", indexes, tt.wantRejected)
+			}
+
+			// Either every order is taken in or none is
+			wantAccepted := len(tt.wantRejected) == 0
+			for _, order := range tt.orders {
+				if order.ID != "taken" && report.IsAccepted(order.ID) != wantAccepted {
+					t.Errorf("TrySubmit(), got order %s accepted %v, want %v", order.ID, !wantAccepted, wantAccepted)
+				}
+			}
+			select {
+			case orders := <-eventBus.KitchenChannel:
+				if !wantAccepted {
+					t.Errorf("TrySubmit(), got orders %v sent to kitchen, want none", orders)
+				}
+			default:
+				if wantAccepted {
+					t.Errorf("TrySubmit(), no orders sent to kitchen")
+				}
+			}
+		})
+	}
+}
+
+func TestTrySubmit_SameIdAtOnce_AcceptedOnce(t *testing.T) {
+	service, _, _ := newTestService()
+
+	var accepted int32
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			order := model.Order{ID: "1", Name: "Banana Split", Temp: model.FROZEN, ShelfLife: 20, DecayRate: 0.63}
+			if rejected, err := service.TrySubmit([]model.Order{order}); err == nil && len(rejected) == 0 {
+				atomic.AddInt32(&accepted, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if accepted != 1 {
+		t.Errorf("TrySubmit(), got order accepted %d times, want once", accepted)
+	}
+}
+
+func TestValidate_DuplicateIds(t *testing.T) {
+	service, _, report := newTestService()
+	report.Accept([]model.Order{{ID: "1"}})
+
+	_, rejected := service.Validate([]model.Order{
+		{ID: "1", Name: "Banana Split", Temp: model.FROZEN, ShelfLife: 20, DecayRate: 0.63},
+		{ID: "2", Name: "Pizza", Temp: model.HOT, ShelfLife: 300, DecayRate: 0.45},
+		{ID: "2", Name: "Pizza", Temp: model.HOT, ShelfLife: 300, DecayRate: 0.45},
+	})
+
+	if len(rejected) != 2 || rejected[0].Index != 0 || rejected[1].Index != 2 {
+		t.Errorf("Validate(), got rejected %v, want the accepted id at index 0 and the repeated id at index 2", rejected)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	service, eventBus, report := newTestService()
+	now := eventBus.Clock.Now()
+
+	hot, _ := service.shelves.ShelfFactory(model.HOT)
+	hot.Push(model.NewShelfItem(model.Order{ID: "1", Name: "Pizza", Temp: model.HOT, ShelfLife: 300, DecayRate: 0.45}, now, 1))
+	service.shelves.OverflowShelf.Push(model.NewShelfItem(model.Order{ID: "2", Name: "Yogurt", Temp: model.COLD, ShelfLife: 263, DecayRate: 0.37}, now, 2))
+	report.Accept([]model.Order{{ID: "1"}, {ID: "2"}, {ID: "3"}})
+
+	tests := []struct {
+		name         string
+		orderId      string
+		wantShelf    string
+		wantNewSpace string
+	}{
+		{
+			name:         "TestCancel_OrderOnShelf_TakenOffWithNewSpace",
+			orderId:      "1",
+			wantShelf:    model.HOT,
+			wantNewSpace: model.HOT,
+		},
+		{
+			name:      "TestCancel_OrderOnOverflowShelf_TakenOff",
+			orderId:   "2",
+			wantShelf: model.OVERFLOW,
+		},
+		{
+			name:    "TestCancel_OrderInKitchen_LeftToKitchen",
+			orderId: "3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := service.Cancel(tt.orderId); err != nil {
+				t.Fatalf("Cancel(), got error %s, want none", err)
+			}
+
+			if _, _, isOnShelf := service.shelves.Locate(tt.orderId); isOnShelf {
+				t.Errorf("Cancel(), got order %s on a shelf, want taken off", tt.orderId)
+			}
+
+			if cancelled := <-eventBus.CancelPickupChannel; cancelled != tt.orderId {
+				t.Errorf("Cancel(), got pickup of order %s cancelled, want %s", cancelled, tt.orderId)
+			}
+
+			select {
+			case temp := <-eventBus.NewSpaceAvailableChannel:
+				if temp != tt.wantNewSpace {
+					t.Errorf("Cancel(), got new space available on shelf '%s', want '%s'", temp, tt.wantNewSpace)
+				}
+			default:
+				if tt.wantNewSpace != "" {
+					t.Errorf("Cancel(), no new space available, want on shelf '%s'", tt.wantNewSpace)
+				}
+			}
+
+			if tt.wantShelf == "" {
+				return
+			}
+			for i := 0; i < 100; i++ {
+				if details, err := report.Lookup(tt.orderId); err == nil && details.Status == model.ORDER_CANCELLED {
+					if last := details.History[len(details.History)-1]; last.Shelf != tt.wantShelf {
+						t.Errorf("Cancel(), got order %s cancelled on shelf '%s', want '%s'", tt.orderId, last.Shelf, tt.wantShelf)
+					}
+					return
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			t.Errorf("Cancel(), got order %s never cancelled, want cancelled", tt.orderId)
+		})
+	}
+
+	if err := service.Cancel("1"); err == nil {
+		t.Errorf("Cancel(), got order 1 cancelled twice, want error")
+	}
+}
@@ -1,61 +1,265 @@
 package kitchen
 
 import (
+	"context"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/bus"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/config"
 	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/model"
+	repo "sharedkitchenordersystem/internal/app/sharedkitchenordersystem/repository/shelf"
 	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/service/supervisor"
-	"sharedkitchenordersystem/internal/pkg"
+	"sync"
 	"time"
 
 	"go.uber.org/zap"
 )
 
-// Start starts the kitchen service
-func Start(noOfOrdersToRead int) {
-	internalProcess()
+// Service cooks the orders received on the bus at its cooking stations, in the order they are received, and sends
+// them to storage and dispatch once cooked. The orders waiting for a station are bounded by the size of its queue
+type Service struct {
+	bus     *bus.Bus
+	shelves *repo.Repository
+	report  *supervisor.ReportBook
+
+	stations []*Station
+
+	// Time an order takes to cook by dish name, else by temperature, else the default cook time
+	cookTime       time.Duration
+	cookTimeByTemp map[string]time.Duration
+	cookTimeByDish map[string]time.Duration
+
+	// Stations returning to the scheduler once they cooked their order
+	freed chan *Station
+
+	// Orders admitted to the kitchen which are not at a station yet, up to queueSize, and the senders waiting for
+	// room in the queue, the longest waiting first
+	locker    sync.Mutex
+	queueSize int
+	queued    int
+	waiting   []admission
+}
+
+// admission is a sender waiting for room for its orders in the queue; it is signalled once they are admitted
+type admission struct {
+	orders   int
+	admitted chan struct{}
+}
+
+// New creates the kitchen service with the given cooking stations; the orders cancelled before they are cooked are
+// not cooked
+func New(eventBus *bus.Bus, shelves *repo.Repository, report *supervisor.ReportBook, kitchen config.KitchenConfig) *Service {
+	service := &Service{
+		bus:            eventBus,
+		shelves:        shelves,
+		report:         report,
+		cookTime:       time.Duration(kitchen.CookTimeS) * time.Second,
+		cookTimeByTemp: make(map[string]time.Duration),
+		cookTimeByDish: make(map[string]time.Duration),
+		freed:          make(chan *Station, kitchen.Stations),
+		queueSize:      kitchen.QueueSize,
+	}
+
+	for temp, cookTimeS := range kitchen.CookTimeByTemperatureS {
+		service.cookTimeByTemp[temp] = time.Duration(cookTimeS) * time.Second
+	}
+	for dish, cookTimeS := range kitchen.CookTimeByDishS {
+		service.cookTimeByDish[dish] = time.Duration(cookTimeS) * time.Second
+	}
+
+	for id := 1; id <= kitchen.Stations; id++ {
+		service.stations = append(service.stations, &Station{ID: id, tasks: make(chan cookTask, 1)})
+	}
+	return service
+}
+
+// Start starts the kitchen service and its stations; they stop taking orders when the context is done
+func (service *Service) Start(ctx context.Context) {
+	for _, station := range service.stations {
+		go station.run(ctx, service)
+	}
+	service.internalProcess(ctx)
+}
+
+// Stations gives the cooking stations of the kitchen
+func (service *Service) Stations() []*Station {
+	return service.stations
+}
+
+// Admit admits orders to the kitchen queue if it has room for them; an empty queue admits any number of orders.
+// It tells whether the orders were admitted
+func (service *Service) Admit(orders int) bool {
+	service.locker.Lock()
+	defer service.locker.Unlock()
+
+	if len(service.waiting) > 0 || !service.hasRoomFor(orders) {
+		return false
+	}
+	service.queued += orders
+	return true
+}
+
+// WaitToAdmit admits orders to the kitchen queue once it has room for them, after the orders of the senders waiting
+// before. It must be called while handling a unit of work, released while waiting so the kitchen clock moves on
+func (service *Service) WaitToAdmit(orders int) {
+	admitted := service.Reserve(orders)
+	if admitted == nil {
+		return
+	}
+
+	service.bus.Clock.Done()
+	<-admitted
+}
+
+// Reserve admits orders to the kitchen queue once it has room for them, after the orders of the senders waiting
+// before, without waiting. It gives nil if the orders are admitted right away, else a channel closed once they are
+// admitted; a unit of work is begun for the sender then
+func (service *Service) Reserve(orders int) <-chan struct{} {
+	service.locker.Lock()
+	defer service.locker.Unlock()
+
+	if len(service.waiting) == 0 && service.hasRoomFor(orders) {
+		service.queued += orders
+		return nil
+	}
+
+	sender := admission{orders: orders, admitted: make(chan struct{})}
+	service.waiting = append(service.waiting, sender)
+	zap.S().Infof("Kitchen: Queue full with '%d' orders; waiting for room for '%d' more", service.queued, orders)
+	return sender.admitted
+}
+
+// Queued gives the number of orders admitted to the kitchen which are not at a station yet
+func (service *Service) Queued() int {
+	service.locker.Lock()
+	defer service.locker.Unlock()
+
+	return service.queued
 }
 
-// internalProcess reads and processes the event messages from Kitchen Channel queue
-func internalProcess() {
+// hasRoomFor tells whether the queue has room for the orders; the service must be locked
+func (service *Service) hasRoomFor(orders int) bool {
+	return service.queued == 0 || service.queued+orders <= service.queueSize
+}
+
+// release frees the room of an order taken off the queue and admits the orders of the senders waiting for room, in
+// turn. A sender admitted resumes its unit of work
+func (service *Service) release() {
+	service.locker.Lock()
+	defer service.locker.Unlock()
+
+	// Orders sent to the kitchen without being admitted take no room
+	if service.queued > 0 {
+		service.queued--
+	}
+	for len(service.waiting) > 0 && service.hasRoomFor(service.waiting[0].orders) {
+		sender := service.waiting[0]
+		service.waiting = service.waiting[1:]
+		service.queued += sender.orders
+
+		service.bus.Clock.Begin()
+		close(sender.admitted)
+	}
+}
+
+// internalProcess schedules the orders received from the kitchen channel on the cooking stations, the station free
+// for the longest time first; the orders waiting for a station are queued in the order they are received
+func (service *Service) internalProcess(ctx context.Context) {
 	go func() {
+		free := append([]*Station{}, service.stations...)
+		queue := []model.Order{}
+
 		for {
 			select {
-			case orderReqs, more := <-supervisor.KitchenChannel:
-				if !more {
-					supervisor.KitchenChannel = nil
-					break
+			case <-ctx.Done():
+				if len(queue) > 0 {
+					zap.S().Infof("Kitchen: '%d' orders left waiting for a cooking station", len(queue))
 				}
-				go func(orderReqs []model.Order) {
-					for _, orderReq := range orderReqs {
-						zap.S().Infof("Kitchen: Order '%s' (%s) getting processed", orderReq.Name, orderReq.ID)
-
-						// Send order status event
-						supervisor.SupervisorChannel <- model.OrderStatus{OrderId: orderReq.ID, Status: model.ORDER_RECEIVED}
-
-						// Send order ready event
-						shelfItem := model.ShelfItem{
-							Order:        orderReq,
-							CreatedTime:  time.Now(),
-							MaxLifeTimeS: pkg.CalculateMaxAge(orderReq.ShelfLife, orderReq.DecayRate, 1), // assume 1 for now, then change dynamically later
-						}
-						zap.S().Infof("Kitchen: Order '%s'(%s) is ready and expires in %d(s)", orderReq.Name, orderReq.ID, shelfItem.MaxLifeTimeS)
-
-						// Send OrderStatus event
-						supervisor.SupervisorChannel <- model.OrderStatus{OrderId: orderReq.ID, Status: model.ORDER_PROCESSED}
-
-						// Send StoreOrder event
-						zap.S().Infof("Kitchen: Order '%s' (%s) sent to Storage to get stored", shelfItem.Order.Name, shelfItem.Order.ID)
-						supervisor.StorageChannel <- shelfItem
-
-						// Send InitiateDispatcher event
-						zap.S().Infof("Kitchen: Order '%s'(%s) is ready for dispatch and sent to Dispatch at %s", orderReq.Name, orderReq.ID, time.Now())
-						supervisor.DispatchChannel <- orderReq
-					}
-				}(orderReqs)
-			}
+				zap.S().Info("Kitchen: Stopped")
+				return
+			case orderReqs := <-service.bus.KitchenChannel:
+				for _, orderReq := range orderReqs {
+					zap.S().Infof("Kitchen: Order '%s' (%s) received and waiting for a cooking station", orderReq.Name, orderReq.ID)
 
-			if supervisor.KitchenChannel == nil {
-				break
+					// Send order status event
+					service.bus.ReportStatus(model.OrderStatus{OrderId: orderReq.ID, Status: model.ORDER_RECEIVED, Time: service.bus.Clock.Now()})
+				}
+				queue = append(queue, orderReqs...)
+				free, queue = service.schedule(free, queue)
+				service.bus.Handled()
+			case station := <-service.freed:
+				free = append(free, station)
+				free, queue = service.schedule(free, queue)
+				service.bus.Clock.Done()
 			}
 		}
 	}()
 }
+
+// schedule starts cooking the orders queued at the free stations, in turn. Orders cancelled meanwhile are not
+// cooked. It gives the stations still free and the orders still queued
+func (service *Service) schedule(free []*Station, queue []model.Order) ([]*Station, []model.Order) {
+	for len(free) > 0 && len(queue) > 0 {
+		orderReq := queue[0]
+		queue = queue[1:]
+		service.release()
+
+		if service.report.IsCancelled(orderReq.ID) {
+			zap.S().Infof("Kitchen: Order '%s' (%s) cancelled before it was cooked", orderReq.Name, orderReq.ID)
+
+			// Send OrderStatus event
+			service.bus.ReportStatus(model.OrderStatus{OrderId: orderReq.ID, Status: model.ORDER_CANCELLED, Time: service.bus.Clock.Now()})
+			continue
+		}
+
+		station := free[0]
+		free = free[1:]
+
+		// The timer of the cooked order is created before the task is handed over to the station. The order starts
+		// cooking at the time it is reported cooking, so it is not cooked before
+		now := service.bus.Clock.Now()
+		task := station.cook(orderReq, now, service.cookTimeOf(orderReq), service.bus.Clock)
+		zap.S().Infof("Kitchen: Order '%s' (%s) getting cooked at station %d", orderReq.Name, orderReq.ID, station.ID)
+
+		// Send order status event
+		service.bus.ReportStatus(model.OrderStatus{OrderId: orderReq.ID, Status: model.ORDER_COOKING, Time: now})
+		service.bus.Clock.Begin()
+		station.tasks <- task
+	}
+	return free, queue
+}
+
+// cookTimeOf gives the time an order takes to cook
+func (service *Service) cookTimeOf(orderReq model.Order) time.Duration {
+	if cookTime, isPresent := service.cookTimeByDish[orderReq.Name]; isPresent {
+		return cookTime
+	}
+	if cookTime, isPresent := service.cookTimeByTemp[orderReq.Temp]; isPresent {
+		return cookTime
+	}
+	return service.cookTime
+}
+
+// cooked sends an order cooked to storage and dispatch, unless it was cancelled while cooking
+func (service *Service) cooked(orderReq model.Order, now time.Time) {
+	if service.report.IsCancelled(orderReq.ID) {
+		zap.S().Infof("Kitchen: Order '%s' (%s) cancelled while cooking; discarded", orderReq.Name, orderReq.ID)
+
+		// Send OrderStatus event
+		service.bus.ReportStatus(model.OrderStatus{OrderId: orderReq.ID, Status: model.ORDER_CANCELLED, Time: now})
+		return
+	}
+
+	// Send order ready event
+	shelfItem := model.NewShelfItem(orderReq, now, service.shelves.DecayModifiers[orderReq.Temp])
+	zap.S().Infof("Kitchen: Order '%s'(%s) is ready and expires at %s on its shelf", orderReq.Name, orderReq.ID, shelfItem.ExpiresAt())
+
+	// Send OrderStatus event
+	service.bus.ReportStatus(model.OrderStatus{OrderId: orderReq.ID, Status: model.ORDER_PROCESSED, Time: shelfItem.CreatedTime})
+
+	// Send StoreOrder event
+	zap.S().Infof("Kitchen: Order '%s' (%s) sent to Storage to get stored", shelfItem.Order.Name, shelfItem.Order.ID)
+	service.bus.Store(shelfItem)
+
+	// Send InitiateDispatcher event
+	zap.S().Infof("Kitchen: Order '%s'(%s) is ready for dispatch and sent to Dispatch at %s", orderReq.Name, orderReq.ID, now)
+	service.bus.Dispatch(orderReq)
+}
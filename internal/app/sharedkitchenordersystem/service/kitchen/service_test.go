@@ -1,35 +1,257 @@
 package kitchen
 
 import (
+	"context"
+	"reflect"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/bus"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/clock"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/config"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/model"
+	repo "sharedkitchenordersystem/internal/app/sharedkitchenordersystem/repository/shelf"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/service/supervisor"
 	"testing"
+	"time"
 )
 
-func TestStart(t *testing.T) {
-	type args struct {
-		noOfOrdersToRead int
+func TestService_Start(t *testing.T) {
+	eventBus := bus.New(10, clock.NewWallClock())
+	shelves := repo.New(config.Default().Shelves)
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	New(eventBus, shelves, supervisor.New(eventBus, shelves, config.Default().IdleTimeoutS).Report, config.Default().Kitchen).Start(ctx)
+
+	orders := []model.Order{
+		{ID: "1", Name: "chicken", Temp: model.HOT, ShelfLife: 20, DecayRate: 1},
+		{ID: "2", Name: "juice", Temp: model.COLD, ShelfLife: 10, DecayRate: 1},
+	}
+	eventBus.KitchenChannel <- orders
+
+	// The orders are cooked at stations of their own, so only the statuses of each order are in sequence
+	histories := make(map[string][]string)
+	times := make(map[string][]time.Time)
+	for range orders {
+		for i := 0; i < 3; i++ {
+			select {
+			case status := <-eventBus.SupervisorChannel:
+				histories[status.OrderId] = append(histories[status.OrderId], status.Status)
+				times[status.OrderId] = append(times[status.OrderId], status.Time)
+			case <-time.After(time.Second):
+				t.Fatalf("Start(), got statuses %v, want every order processed", histories)
+			}
+		}
+	}
+
+	wantHistory := []string{model.ORDER_RECEIVED, model.ORDER_COOKING, model.ORDER_PROCESSED}
+	for _, order := range orders {
+		if !reflect.DeepEqual(histories[order.ID], wantHistory) {
+			t.Errorf("Start(), got order %s statuses %v, want %v", order.ID, histories[order.ID], wantHistory)
+		}
+		for i := 1; i < len(times[order.ID]); i++ {
+			if times[order.ID][i].Before(times[order.ID][i-1]) {
+				t.Errorf("Start(), got order %s status times %v, want them in order", order.ID, times[order.ID])
+			}
+		}
+	}
+
+	stored := make(map[string]bool)
+	dispatched := make(map[string]bool)
+	for range orders {
+		select {
+		case shelfItem := <-eventBus.StorageChannel:
+			if shelfItem.DecayModifier != 1 || shelfItem.Value(shelfItem.CreatedTime) != 1 {
+				t.Errorf("Start(), got order %s sent to storage with decay modifier %.2f, want decay modifier 1", shelfItem.Order.ID, shelfItem.DecayModifier)
+			}
+			stored[shelfItem.Order.ID] = true
+		case <-time.After(time.Second):
+			t.Fatalf("Start(), got orders %v sent to storage, want every order", stored)
+		}
+
+		select {
+		case orderReq := <-eventBus.DispatchChannel:
+			dispatched[orderReq.ID] = true
+		case <-time.After(time.Second):
+			t.Fatalf("Start(), got orders %v sent to dispatch, want every order", dispatched)
+		}
+	}
+
+	for _, order := range orders {
+		if !stored[order.ID] || !dispatched[order.ID] {
+			t.Errorf("Start(), got order %s sent to storage %v and dispatch %v, want both", order.ID, stored[order.ID], dispatched[order.ID])
+		}
 	}
+}
+
+func TestService_Start_CancelledOrder_NotCooked(t *testing.T) {
+	eventBus := bus.New(10, clock.NewWallClock())
+	shelves := repo.New(config.Default().Shelves)
+	report := supervisor.New(eventBus, shelves, config.Default().IdleTimeoutS).Report
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	New(eventBus, shelves, report, config.Default().Kitchen).Start(ctx)
+
+	report.Accept([]model.Order{{ID: "1"}})
+	if err := report.Cancel("1"); err != nil {
+		t.Fatalf("Cancel(), got error %s, want none", err)
+	}
+	eventBus.KitchenChannel <- []model.Order{{ID: "1", Name: "chicken", Temp: model.HOT, ShelfLife: 20, DecayRate: 1}}
+
+	for _, wantStatus := range []string{model.ORDER_RECEIVED, model.ORDER_CANCELLED} {
+		select {
+		case status := <-eventBus.SupervisorChannel:
+			if status.OrderId != "1" || status.Status != wantStatus {
+				t.Errorf("Start(), got order %s status %s, want order 1 status %s", status.OrderId, status.Status, wantStatus)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Start(), no status reported for order 1, want %s", wantStatus)
+		}
+	}
+
+	select {
+	case shelfItem := <-eventBus.StorageChannel:
+		t.Errorf("Start(), got cancelled order %s sent to storage, want not cooked", shelfItem.Order.ID)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestService_CookTime(t *testing.T) {
 	tests := []struct {
-		name string
-		args args
+		name               string
+		stations           int
+		wantProcessedAfter map[string]time.Duration
 	}{
-		// TODO: Add test cases.
+		{
+			name:               "TestService_CookTime_OneStation_OrdersCookedInTurn",
+			stations:           1,
+			wantProcessedAfter: map[string]time.Duration{"1": 5 * time.Second, "2": 7 * time.Second, "3": 8 * time.Second},
+		},
+		{
+			name:               "TestService_CookTime_TwoStations_StationFreedCooksNextOrder",
+			stations:           2,
+			wantProcessedAfter: map[string]time.Duration{"1": 5 * time.Second, "2": 2 * time.Second, "3": 3 * time.Second},
+		},
 	}
+
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			Start(tt.args.noOfOrdersToRead)
+			start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+			virtualClock := clock.NewVirtualClock(start)
+			eventBus := bus.New(10, virtualClock)
+			shelves := repo.New(config.Default().Shelves)
+			kitchenSupervisor := supervisor.New(eventBus, shelves, config.Default().IdleTimeoutS)
+
+			ctx, stop := context.WithCancel(context.Background())
+			defer stop()
+			virtualClock.Begin()
+			kitchenSupervisor.Start(ctx)
+			New(eventBus, shelves, kitchenSupervisor.Report, config.KitchenConfig{
+				Stations:               tt.stations,
+				QueueSize:              10,
+				CookTimeS:              1,
+				CookTimeByTemperatureS: map[string]int{model.HOT: 5, model.COLD: 4},
+				CookTimeByDishS:        map[string]int{"juice": 2},
+			}).Start(ctx)
+			go drainCookedOrders(ctx, eventBus)
+
+			// The dish cook time comes before the temperature one, the temperature one before the default
+			eventBus.Cook([]model.Order{
+				{ID: "1", Name: "chicken", Temp: model.HOT, ShelfLife: 300, DecayRate: 1},
+				{ID: "2", Name: "juice", Temp: model.COLD, ShelfLife: 300, DecayRate: 1},
+				{ID: "3", Name: "ice cream", Temp: model.FROZEN, ShelfLife: 300, DecayRate: 1},
+			})
+			virtualClock.Done()
+
+			for orderId, wantAfter := range tt.wantProcessedAfter {
+				var details model.OrderDetails
+				for i := 0; i < 100; i++ {
+					if details, _ = kitchenSupervisor.Report.Lookup(orderId); details.Status == model.ORDER_PROCESSED {
+						break
+					}
+					time.Sleep(10 * time.Millisecond)
+				}
+
+				if details.Status != model.ORDER_PROCESSED {
+					t.Fatalf("Lookup(), got order %s %s, want processed", orderId, details.Status)
+				}
+				if elapsed := details.History[len(details.History)-1].Time.Sub(start); elapsed != wantAfter {
+					t.Errorf("Lookup(), got order %s processed after %s, want after %s", orderId, elapsed, wantAfter)
+				}
+			}
 		})
 	}
 }
 
-func Test_internalProcess(t *testing.T) {
+func TestService_Admit(t *testing.T) {
+	eventBus := bus.New(10, clock.NewWallClock())
+	shelves := repo.New(config.Default().Shelves)
+	service := New(eventBus, shelves, supervisor.New(eventBus, shelves, config.Default().IdleTimeoutS).Report, config.KitchenConfig{Stations: 1, QueueSize: 2})
+
 	tests := []struct {
-		name string
+		name         string
+		orders       int
+		wantAdmitted bool
+		wantQueued   int
 	}{
-		// TODO: Add test cases.
+		{
+			name:         "TestService_Admit_EmptyQueue_AdmitsMoreThanQueueSize",
+			orders:       3,
+			wantAdmitted: true,
+			wantQueued:   3,
+		},
+		{
+			name:       "TestService_Admit_FullQueue_Refused",
+			orders:     1,
+			wantQueued: 3,
+		},
 	}
+
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			internalProcess()
+			if admitted := service.Admit(tt.orders); admitted != tt.wantAdmitted {
+				t.Errorf("Admit(), got admitted %v, want %v", admitted, tt.wantAdmitted)
+			}
+
+			if queued := service.Queued(); queued != tt.wantQueued {
+				t.Errorf("Queued(), got %d orders queued, want %d", queued, tt.wantQueued)
+			}
 		})
 	}
+
+	// A sender waiting for room is admitted once enough orders left the queue
+	admitted := make(chan struct{})
+	go func() {
+		service.WaitToAdmit(2)
+		close(admitted)
+	}()
+
+	for i := 0; i < 3; i++ {
+		select {
+		case <-admitted:
+			t.Fatalf("WaitToAdmit(), got orders admitted with %d orders queued, want waiting for room", service.Queued())
+		case <-time.After(10 * time.Millisecond):
+		}
+		service.release()
+	}
+
+	select {
+	case <-admitted:
+	case <-time.After(time.Second):
+		t.Fatalf("WaitToAdmit(), got orders still waiting with the queue empty, want admitted")
+	}
+	if queued := service.Queued(); queued != 2 {
+		t.Errorf("Queued(), got %d orders queued, want 2", queued)
+	}
+}
+
+// drainCookedOrders handles the orders sent to storage and dispatch until the context is done
+func drainCookedOrders(ctx context.Context, eventBus *bus.Bus) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-eventBus.StorageChannel:
+			eventBus.Handled()
+		case <-eventBus.DispatchChannel:
+			eventBus.Handled()
+		}
+	}
 }
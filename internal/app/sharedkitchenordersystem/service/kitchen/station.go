@@ -0,0 +1,90 @@
+package kitchen
+
+import (
+	"context"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/clock"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/model"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Station of the kitchen; it cooks one order at a time
+type Station struct {
+	ID int
+
+	// Orders to cook handed over by the scheduler
+	tasks chan cookTask
+
+	locker sync.Mutex
+
+	// Order the station is cooking, nil if it is free
+	order *model.Order
+}
+
+// cookTask hands over an order to a station, with the timer firing once it is cooked and the time it is cooked at
+type cookTask struct {
+	order  model.Order
+	cooked clock.Timer
+	ready  time.Time
+}
+
+// Order gives the order the station is cooking, nil if it is free
+func (station *Station) Order() *model.Order {
+	station.locker.Lock()
+	defer station.locker.Unlock()
+
+	return station.order
+}
+
+// cook starts cooking an order at the free station. It gives the task to hand over to the station, with the timer
+// firing once the order is cooked
+func (station *Station) cook(orderReq model.Order, started time.Time, cookTime time.Duration, kitchenClock clock.Clock) cookTask {
+	station.locker.Lock()
+	defer station.locker.Unlock()
+
+	station.order = &orderReq
+	return cookTask{order: orderReq, cooked: kitchenClock.NewTimer(cookTime), ready: started.Add(cookTime)}
+}
+
+// run - worker cooking the orders handed over to the station; the station is returned to the scheduler once the
+// order it cooked is sent to storage and dispatch
+func (station *Station) run(ctx context.Context, service *Service) {
+	kitchenClock := service.bus.Clock
+	for {
+		var task cookTask
+		select {
+		case <-ctx.Done():
+			return
+		case task = <-station.tasks:
+			kitchenClock.Done()
+		}
+
+		var now time.Time
+		select {
+		case <-ctx.Done():
+			task.cooked.Stop()
+			zap.S().Infof("Kitchen: Order '%s' (%s) left unfinished at station %d", task.order.Name, task.order.ID, station.ID)
+			return
+		case <-task.cooked.C():
+			// Cooked once the cook time elapsed from the time it started cooking, which the timer fires after
+			now = task.ready
+		}
+
+		service.cooked(task.order, now)
+
+		station.locker.Lock()
+		station.order = nil
+		station.locker.Unlock()
+
+		// Return to the scheduler
+		kitchenClock.Begin()
+		select {
+		case <-ctx.Done():
+			return
+		case service.freed <- station:
+		}
+		kitchenClock.Done()
+	}
+}
@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"math/rand"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/config"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/model"
+	repo "sharedkitchenordersystem/internal/app/sharedkitchenordersystem/repository/shelf"
+	"time"
+)
+
+// Eviction is the order an eviction policy takes off the overflow shelf
+type Eviction struct {
+	Item model.ShelfItem
+
+	// Temperature controlled shelf the order moves back to; empty if the order is discarded
+	MoveTo string
+}
+
+// Loss gives the value lost to the eviction at the given time
+func (eviction Eviction) Loss(now time.Time) float64 {
+	if eviction.MoveTo != "" {
+		return 0
+	}
+
+	if value := eviction.Item.Value(now); value > 0 {
+		return value
+	}
+	return 0
+}
+
+// EvictionPolicy chooses the order taken off a full overflow shelf to make room for an incoming order
+type EvictionPolicy interface {
+	// Name gives the name the policy is configured with
+	Name() string
+
+	// Evict chooses among the orders on the overflow shelf, which is never empty
+	Evict(candidates []model.ShelfItem, shelves *repo.Repository, now time.Time) Eviction
+}
+
+// NewEvictionPolicies creates every eviction policy by name; the random policy draws from the given generator
+func NewEvictionPolicies(rng *rand.Rand) map[string]EvictionPolicy {
+	lowestValue := &lowestValuePolicy{}
+	return map[string]EvictionPolicy{
+		config.EVICT_RANDOM:         &randomPolicy{rng: rng},
+		config.EVICT_LOWEST_VALUE:   lowestValue,
+		config.EVICT_SOONEST_EXPIRY: &soonestExpiryPolicy{},
+		config.EVICT_OLDEST:         &oldestPolicy{},
+		config.EVICT_RELOCATE:       &relocatePolicy{fallback: lowestValue},
+	}
+}
+
+// randomPolicy discards a random order
+type randomPolicy struct {
+	rng *rand.Rand
+}
+
+func (policy *randomPolicy) Name() string { return config.EVICT_RANDOM }
+
+func (policy *randomPolicy) Evict(candidates []model.ShelfItem, shelves *repo.Repository, now time.Time) Eviction {
+	return Eviction{Item: candidates[policy.rng.Intn(len(candidates))]}
+}
+
+// lowestValuePolicy discards the order with the lowest value left
+type lowestValuePolicy struct{}
+
+func (policy *lowestValuePolicy) Name() string { return config.EVICT_LOWEST_VALUE }
+
+func (policy *lowestValuePolicy) Evict(candidates []model.ShelfItem, shelves *repo.Repository, now time.Time) Eviction {
+	return Eviction{Item: first(candidates, func(a, b model.ShelfItem) bool { return a.Value(now) < b.Value(now) })}
+}
+
+// soonestExpiryPolicy discards the order which would expire first on the overflow shelf
+type soonestExpiryPolicy struct{}
+
+func (policy *soonestExpiryPolicy) Name() string { return config.EVICT_SOONEST_EXPIRY }
+
+func (policy *soonestExpiryPolicy) Evict(candidates []model.ShelfItem, shelves *repo.Repository, now time.Time) Eviction {
+	return Eviction{Item: first(candidates, func(a, b model.ShelfItem) bool { return a.ExpiresAt().Before(b.ExpiresAt()) })}
+}
+
+// oldestPolicy discards the order cooked first
+type oldestPolicy struct{}
+
+func (policy *oldestPolicy) Name() string { return config.EVICT_OLDEST }
+
+func (policy *oldestPolicy) Evict(candidates []model.ShelfItem, shelves *repo.Repository, now time.Time) Eviction {
+	return Eviction{Item: first(candidates, func(a, b model.ShelfItem) bool { return a.CreatedTime.Before(b.CreatedTime) })}
+}
+
+// relocatePolicy moves the order which would expire first back to its temperature controlled shelf if that shelf
+// has space; otherwise the fallback policy chooses the order to discard
+type relocatePolicy struct {
+	fallback EvictionPolicy
+}
+
+func (policy *relocatePolicy) Name() string { return config.EVICT_RELOCATE }
+
+func (policy *relocatePolicy) Evict(candidates []model.ShelfItem, shelves *repo.Repository, now time.Time) Eviction {
+	movable := make([]model.ShelfItem, 0, len(candidates))
+	for _, candidate := range candidates {
+		if shelf, err := shelves.ShelfFactory(candidate.Order.Temp); err == nil && shelf.Size() < shelf.MaxCapacity() {
+			movable = append(movable, candidate)
+		}
+	}
+
+	if len(movable) == 0 {
+		return policy.fallback.Evict(candidates, shelves, now)
+	}
+
+	item := first(movable, func(a, b model.ShelfItem) bool { return a.ExpiresAt().Before(b.ExpiresAt()) })
+	return Eviction{Item: item, MoveTo: item.Order.Temp}
+}
+
+// first gives the first of the candidates in the given order; ties go to the earliest candidate
+func first(candidates []model.ShelfItem, less func(a, b model.ShelfItem) bool) model.ShelfItem {
+	chosen := candidates[0]
+	for _, candidate := range candidates[1:] {
+		if less(candidate, chosen) {
+			chosen = candidate
+		}
+	}
+	return chosen
+}
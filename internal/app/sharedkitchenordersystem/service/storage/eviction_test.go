@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"fmt"
+	"math/rand"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/config"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/model"
+	repo "sharedkitchenordersystem/internal/app/sharedkitchenordersystem/repository/shelf"
+	"testing"
+	"time"
+)
+
+func TestEvictionPolicy_Evict(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	onOverflow := func(order model.Order, cookedAgo time.Duration) model.ShelfItem {
+		return model.NewShelfItem(order, now.Add(-cookedAgo), 2)
+	}
+
+	// Value 0.3, expires in 15s
+	lowestValue := onOverflow(model.Order{ID: "1", Name: "chicken", Temp: model.HOT, ShelfLife: 100, DecayRate: 1}, 35*time.Second)
+	// Value 0.8, expires in 240s
+	oldest := onOverflow(model.Order{ID: "2", Name: "juice", Temp: model.COLD, ShelfLife: 300, DecayRate: 0.5}, 60*time.Second)
+	// Value 0.5, expires in 5s
+	soonestExpiry := onOverflow(model.Order{ID: "3", Name: "ice cream", Temp: model.FROZEN, ShelfLife: 20, DecayRate: 1}, 5*time.Second)
+	candidates := []model.ShelfItem{lowestValue, oldest, soonestExpiry}
+
+	fullShelves := repo.New(config.Default().Shelves)
+	for _, temp := range fullShelves.ShelfTemperatures {
+		shelf, _ := fullShelves.ShelfFactory(temp)
+		for i := 0; i < shelf.MaxCapacity(); i++ {
+			shelf.Push(model.NewShelfItem(model.Order{ID: fmt.Sprintf("%s-%d", temp, i), Temp: temp, ShelfLife: 100, DecayRate: 1}, now, 1))
+		}
+	}
+
+	tests := []struct {
+		name       string
+		policy     string
+		shelves    *repo.Repository
+		wantItem   string
+		wantMoveTo string
+		wantLoss   float64
+	}{
+		{
+			name:     "TestEvictionPolicy_Evict_LowestValue_DiscardsLowestValue",
+			policy:   config.EVICT_LOWEST_VALUE,
+			shelves:  repo.New(config.Default().Shelves),
+			wantItem: lowestValue.Order.ID,
+			wantLoss: 0.3,
+		},
+		{
+			name:     "TestEvictionPolicy_Evict_SoonestExpiry_DiscardsSoonestExpiry",
+			policy:   config.EVICT_SOONEST_EXPIRY,
+			shelves:  repo.New(config.Default().Shelves),
+			wantItem: soonestExpiry.Order.ID,
+			wantLoss: 0.5,
+		},
+		{
+			name:     "TestEvictionPolicy_Evict_Oldest_DiscardsOldest",
+			policy:   config.EVICT_OLDEST,
+			shelves:  repo.New(config.Default().Shelves),
+			wantItem: oldest.Order.ID,
+			wantLoss: 0.8,
+		},
+		{
+			name:       "TestEvictionPolicy_Evict_RelocateShelfHasSpace_MovesSoonestExpiry",
+			policy:     config.EVICT_RELOCATE,
+			shelves:    repo.New(config.Default().Shelves),
+			wantItem:   soonestExpiry.Order.ID,
+			wantMoveTo: model.FROZEN,
+			wantLoss:   0,
+		},
+		{
+			name:     "TestEvictionPolicy_Evict_RelocateShelvesFull_DiscardsLowestValue",
+			policy:   config.EVICT_RELOCATE,
+			shelves:  fullShelves,
+			wantItem: lowestValue.Order.ID,
+			wantLoss: 0.3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eviction := NewEvictionPolicies(rand.New(rand.NewSource(1)))[tt.policy].Evict(candidates, tt.shelves, now)
+			if eviction.Item.Order.ID != tt.wantItem || eviction.MoveTo != tt.wantMoveTo {
+				t.Errorf("Evict(), got order %s moved to '%s', want order %s moved to '%s'", eviction.Item.Order.ID, eviction.MoveTo, tt.wantItem, tt.wantMoveTo)
+			}
+
+			if loss := eviction.Loss(now); loss < tt.wantLoss-1e-9 || loss > tt.wantLoss+1e-9 {
+				t.Errorf("Loss(), got %.2f, want %.2f", loss, tt.wantLoss)
+			}
+		})
+	}
+}
+
+func Test_onSpaceOverflownEventReceived_OverflowFull(t *testing.T) {
+	tests := []struct {
+		name          string
+		policy        string
+		wantOnHot     bool
+		wantEvictedID string
+	}{
+		{
+			name:          "Test_onSpaceOverflownEventReceived_OverflowFull_LowestValueEvictsAcrossCompartments",
+			policy:        config.EVICT_LOWEST_VALUE,
+			wantEvictedID: "cold-0",
+		},
+		{
+			name:      "Test_onSpaceOverflownEventReceived_OverflowFull_RelocateMovesBack",
+			policy:    config.EVICT_RELOCATE,
+			wantOnHot: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := newTestServiceWithPolicy(tt.policy)
+			now := service.bus.Clock.Now()
+
+			// The cold order has the lowest value; the hot ones expire first
+			cold := model.NewShelfItem(model.Order{ID: "cold-0", Temp: model.COLD, ShelfLife: 300, DecayRate: 1}, now.Add(-100*time.Second), 2)
+			service.shelves.OverflowShelf.Push(cold)
+			for i := 1; i < service.shelves.ShelvesCapacity[model.OVERFLOW]; i++ {
+				item := model.NewShelfItem(model.Order{ID: fmt.Sprintf("hot-%d", i), Temp: model.HOT, ShelfLife: 100, DecayRate: 1}, now.Add(-time.Duration(i)*time.Second), 2)
+				service.shelves.OverflowShelf.Push(item)
+			}
+
+			incoming := model.NewShelfItem(model.Order{ID: "frozen-0", Temp: model.FROZEN, ShelfLife: 100, DecayRate: 1}, now, 1)
+			service.onSpaceOverflownEventReceived(incoming)
+
+			if !service.shelves.OverflowShelf.IsPresent(incoming.Order.ID) {
+				t.Errorf("onSpaceOverflownEventReceived(), got order %s not on overflow shelf, want stored", incoming.Order.ID)
+			}
+
+			if size := service.shelves.OverflowShelf.Size(); size != service.shelves.ShelvesCapacity[model.OVERFLOW] {
+				t.Errorf("onSpaceOverflownEventReceived(), got %d orders on overflow shelf, want %d", size, service.shelves.ShelvesCapacity[model.OVERFLOW])
+			}
+
+			if tt.wantEvictedID != "" && service.shelves.OverflowShelf.IsPresent(tt.wantEvictedID) {
+				t.Errorf("onSpaceOverflownEventReceived(), got order %s on overflow shelf, want evicted", tt.wantEvictedID)
+			}
+
+			if hot := getShelf(service, model.HOT); (hot.Size() == 1) != tt.wantOnHot {
+				t.Errorf("onSpaceOverflownEventReceived(), got %d orders moved back to hot shelf, want moved: %v", hot.Size(), tt.wantOnHot)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/clock"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/model"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// rebalance - worker to move orders from the overflow shelf back to temperature controlled shelves with free capacity
+// every time the timer fires, so orders are not stranded on the overflow shelf when new space available events are
+// missed or coalesced
+func (service *Service) rebalance(ctx context.Context, timer clock.Timer) {
+	for {
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C():
+		}
+
+		service.moveBackStrandedOrders(service.bus.Clock.Now())
+
+		timer = service.bus.Clock.NewTimer(service.rebalanceInterval)
+		service.bus.Clock.Done()
+	}
+}
+
+// moveBackStrandedOrders fills the free capacity of every temperature controlled shelf with the orders of the overflow
+// shelf which save the most value by moving
+func (service *Service) moveBackStrandedOrders(now time.Time) {
+	for _, temp := range service.shelves.ShelfTemperatures {
+		shelf, _ := service.shelves.ShelfFactory(temp)
+		freeCapacity := shelf.MaxCapacity() - shelf.Size()
+		if freeCapacity <= 0 || service.shelves.OverflowShelf.SizeOf(temp) == 0 {
+			continue
+		}
+
+		// Expired orders are left to the garbage collector
+		candidates := []model.ShelfItem{}
+		for _, item := range service.shelves.OverflowShelf.ItemsOf(temp) {
+			if !item.IsExpired(now) {
+				candidates = append(candidates, item)
+			}
+		}
+
+		saved := make(map[string]float64, len(candidates))
+		for _, item := range candidates {
+			saved[item.Order.ID] = service.valueSaved(item, now)
+		}
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return saved[candidates[i].Order.ID] > saved[candidates[j].Order.ID]
+		})
+
+		for i := 0; i < len(candidates) && freeCapacity > 0; i++ {
+			// An order taken off as cancelled once moved leaves the space free
+			if moved, freed := service.moveBack(candidates[i], now); moved {
+				zap.S().Infof("Storage: Rebalancer moved Order '%s'(%s) off the overflow shelf, saving value %.2f", candidates[i].Order.ID, candidates[i].Order.Name, saved[candidates[i].Order.ID])
+				if !freed {
+					freeCapacity--
+				}
+			}
+		}
+	}
+}
+
+// valueSaved gives the value an order on the overflow shelf keeps by moving to its temperature controlled shelf now,
+// at the time it would have expired on the overflow shelf
+func (service *Service) valueSaved(item model.ShelfItem, now time.Time) float64 {
+	moved := item.Move(now, service.shelves.DecayModifiers[item.Order.Temp])
+	if value := moved.Value(item.ExpiresAt()); value > 0 {
+		return value
+	}
+	return 0
+}
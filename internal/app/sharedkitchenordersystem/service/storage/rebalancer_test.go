@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"fmt"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/model"
+	"testing"
+	"time"
+)
+
+func Test_moveBackStrandedOrders(t *testing.T) {
+	service := newTestService()
+	now := service.bus.Clock.Now()
+
+	// Leave room for two orders on the hot shelf
+	hot := getShelf(service, model.HOT)
+	for i := 0; i < hot.MaxCapacity()-2; i++ {
+		hot.Push(model.NewShelfItem(model.Order{ID: fmt.Sprintf("hot-%d", i), Temp: model.HOT, ShelfLife: 100, DecayRate: 1}, now, 1))
+	}
+
+	overflow := service.shelves.OverflowShelf
+	onOverflow := func(id string, value float64) model.ShelfItem {
+		// Decays by 0.02 a second on the overflow shelf
+		cooked := now.Add(-time.Duration((1 - value) / 0.02 * float64(time.Second)))
+		item := model.NewShelfItem(model.Order{ID: id, Temp: model.HOT, ShelfLife: 100, DecayRate: 1}, cooked, 2)
+		overflow.Push(item)
+		return item
+	}
+	onOverflow("high", 0.9)
+	onOverflow("low", 0.2)
+	onOverflow("medium", 0.6)
+	onOverflow("expired", 0)
+	cold := model.NewShelfItem(model.Order{ID: "cold", Temp: model.COLD, ShelfLife: 100, DecayRate: 1}, now, 2)
+	service.shelves.OverflowShelf.Push(cold)
+
+	service.moveBackStrandedOrders(now)
+
+	tests := []struct {
+		name          string
+		orderId       string
+		temp          string
+		wantMovedBack bool
+	}{
+		{name: "Test_moveBackStrandedOrders_MostValueSaved_MovedBack", orderId: "high", temp: model.HOT, wantMovedBack: true},
+		{name: "Test_moveBackStrandedOrders_SecondMostValueSaved_MovedBack", orderId: "medium", temp: model.HOT, wantMovedBack: true},
+		{name: "Test_moveBackStrandedOrders_ShelfFull_Stays", orderId: "low", temp: model.HOT, wantMovedBack: false},
+		{name: "Test_moveBackStrandedOrders_Expired_Stays", orderId: "expired", temp: model.HOT, wantMovedBack: false},
+		{name: "Test_moveBackStrandedOrders_OtherTemperature_MovedBack", orderId: "cold", temp: model.COLD, wantMovedBack: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isOnShelf := getShelf(service, tt.temp).IsPresent(tt.orderId)
+			isOnOverflow := service.shelves.OverflowShelf.IsPresent(tt.orderId)
+			if isOnShelf != tt.wantMovedBack || isOnOverflow == tt.wantMovedBack {
+				t.Errorf("moveBackStrandedOrders(), got order %s on %s shelf: %v, on overflow shelf: %v, want moved back: %v", tt.orderId, tt.temp, isOnShelf, isOnOverflow, tt.wantMovedBack)
+			}
+		})
+	}
+}
@@ -1,284 +1,418 @@
 package storage
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"math/rand"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/bus"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/clock"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/config"
 	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/model"
 	repo "sharedkitchenordersystem/internal/app/sharedkitchenordersystem/repository/shelf"
 	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/service/supervisor"
-	"sharedkitchenordersystem/internal/pkg"
 	"strings"
 	"time"
 
 	"go.uber.org/zap"
 )
 
-// Start starts the storage service
-func Start(noOfOrdersToRead int) {
-	repo.Initialize()
-	internalProcess()
+// Service stores the cooked orders on the shelves and garbage collects the expired ones
+type Service struct {
+	bus     *bus.Bus
+	shelves *repo.Repository
+
+	report *supervisor.ReportBook
+
+	// Chooses the orders taken off a full overflow shelf
+	policy EvictionPolicy
+
+	// The other policies, asked for the order they would have chosen on every eviction, to compare the value lost
+	shadows []EvictionPolicy
+
+	// Time between two scans of the rebalancer; it does not run if 0
+	rebalanceInterval time.Duration
+}
+
+// New creates the storage service storing orders on the given shelves and making room on a full overflow shelf with
+// the given eviction policy. The random eviction policy draws from the given generator. The rebalancer moves orders
+// from the overflow shelf back to the temperature controlled shelves every rebalanceInterval, unless it is 0
+func New(eventBus *bus.Bus, shelves *repo.Repository, report *supervisor.ReportBook, policyName string, rebalanceInterval time.Duration, rng *rand.Rand) *Service {
+	policy, isPresent := NewEvictionPolicies(rng)[policyName]
+	if !isPresent {
+		zap.S().Warnf("Storage: Unknown eviction policy '%s', evicting random orders", policyName)
+		policy = NewEvictionPolicies(rng)[config.EVICT_RANDOM]
+	}
+
+	// The shadow random policy draws from its own generator, not to change the orders the policy in use evicts
+	shadows := []EvictionPolicy{}
+	shadowPolicies := NewEvictionPolicies(rand.New(rand.NewSource(rng.Int63())))
+	for _, name := range config.EvictionPolicies {
+		if name != policy.Name() {
+			shadows = append(shadows, shadowPolicies[name])
+		}
+	}
+
+	return &Service{bus: eventBus, shelves: shelves, report: report, policy: policy, shadows: shadows, rebalanceInterval: rebalanceInterval}
+}
+
+// Start starts the storage service; its workers stop when the context is done
+func (service *Service) Start(ctx context.Context) {
+	service.internalProcess(ctx)
 }
 
-func internalProcess() {
+func (service *Service) internalProcess(ctx context.Context) {
 	// Process SpaceOverflown events
-	go processSpaceOverflownEvents()
+	go service.processSpaceOverflownEvents(ctx)
 
 	// Process newShelfSPaceAvailable events
-	go processNewShelfSpaceAvailable()
+	go service.processNewShelfSpaceAvailable(ctx)
 
 	// Spin a worker to check and garbage collect expired orders from normal shelves
-	go collectTempControlledShelvesExpiredOrders()
+	go service.collectTempControlledShelvesExpiredOrders(ctx, service.bus.Clock.NewTimer(time.Second))
 
 	// Spin a worker to check and garbage collect expired orders from overflown shelves
-	go collectOverflownShelveExpiredOrders()
+	go service.collectOverflownShelveExpiredOrders(ctx, service.bus.Clock.NewTimer(time.Second))
+
+	// Spin a worker to move orders stranded on the overflow shelf back to temperature controlled shelves with free capacity
+	if service.rebalanceInterval > 0 {
+		go service.rebalance(ctx, service.bus.Clock.NewTimer(service.rebalanceInterval))
+	}
 
 	go func() {
 		for {
 			select {
-			case shelfItem, isOpen := <-supervisor.StorageChannel:
-				if !isOpen {
-					supervisor.StorageChannel = nil
-					break
-				}
+			case <-ctx.Done():
+				zap.S().Info("Storage: Stopped")
+				return
+			case shelfItem := <-service.bus.StorageChannel:
 				zap.S().Infof("Storage: Order '%s' (%s) getting stored", shelfItem.Order.Name, shelfItem.Order.ID)
 
-				storeItem(shelfItem)
+				service.storeItem(shelfItem)
 				// Send order stored event
-				zap.S().Infof("Storage: Order '%s'(%s) is stored at %s", shelfItem.Order.Name, shelfItem.Order.ID, time.Now())
-			}
-
-			if supervisor.StorageChannel == nil {
-				break
+				zap.S().Infof("Storage: Order '%s'(%s) is stored at %s", shelfItem.Order.Name, shelfItem.Order.ID, service.bus.Clock.Now())
+				service.bus.Handled()
 			}
 		}
 	}()
 }
 
 // storeItem stores a processed order in the shelf
-func storeItem(shelfItem model.ShelfItem) error {
-	var shelf repo.IShelf
-	var capacity int
-	var currentLen int
-
-	shelf, err := repo.ShelfFactory(shelfItem.Order.Temp)
+func (service *Service) storeItem(shelfItem model.ShelfItem) error {
+	shelf, err := service.shelves.ShelfFactory(shelfItem.Order.Temp)
 
 	if err != nil {
 		return err
 	}
-	capacity = shelf.MaxCapacity()
-	currentLen = shelf.Size()
+
+	// Dont store the item if cancelled
+	if service.report.IsCancelled(shelfItem.Order.ID) {
+		// Send OrderStatus event - cancelled
+		service.bus.ReportStatus(model.OrderStatus{OrderId: shelfItem.Order.ID, Status: model.ORDER_CANCELLED, Time: service.bus.Clock.Now()})
+		errMsg := fmt.Sprintf("Storage: Order '%s'(%s) cancelled and not stored", shelfItem.Order.ID, shelfItem.Order.Name)
+		zap.S().Infof(errMsg)
+		return errors.New(errMsg)
+	}
+
+	// Dont store the item if already expired
+	if now := service.bus.Clock.Now(); shelfItem.IsExpired(now) {
+		// Send OrderStatus event - expired
+		service.reportDiscarded(model.OrderStatus{OrderId: shelfItem.Order.ID, Status: model.ORDER_EXPIRED, Time: now})
+		errMsg := fmt.Sprintf("Storage: Order '%s'(%s) expired and not even stored; value %.2f", shelfItem.Order.ID, shelfItem.Order.Name, shelfItem.Value(now))
+		zap.S().Infof(errMsg)
+		return errors.New(errMsg)
+	}
 
 	// If the shelf's max capaacity is reached , send an overflown event and quit
-	if currentLen >= capacity {
+	if !shelf.TryPush(shelfItem) {
 		msg := fmt.Sprintf("Storage: Reached %s shelf capacity: Raise overflown event for Order '%s'(%s)", shelfItem.Order.Temp, shelfItem.Order.Name, shelfItem.Order.ID)
 		zap.S().Infof(msg)
 
 		// Raise overflow event
-		supervisor.OverflownChannel <- shelfItem
+		service.bus.Overflow(shelfItem)
 		return errors.New(msg)
 	}
 
-	// Dont store the item if already expired
-	currAge := int64(time.Now().Sub(shelfItem.CreatedTime).Seconds())
-	if currAge >= shelfItem.MaxLifeTimeS {
-		// Send OrderStatus event - expired
-		supervisor.SupervisorChannel <- model.OrderStatus{OrderId: shelfItem.Order.ID, Status: model.ORDER_EXPIRED}
-		errMsg := fmt.Sprintf("Storage: Order '%s'(%s) expired and not even stored; current age %d(s), max allowed age %d(s)", shelfItem.Order.ID, shelfItem.Order.Name, currAge, shelfItem.MaxLifeTimeS)
-		zap.S().Infof(errMsg)
-		return errors.New(errMsg)
+	// Send OrderStatus event - stored
+	service.bus.ReportStatus(model.OrderStatus{OrderId: shelfItem.Order.ID, Status: model.ORDER_STORED, Time: service.bus.Clock.Now(), Shelf: shelfItem.Order.Temp})
+	if service.takeOffIfCancelled(shelf, shelfItem.Order.Temp, shelfItem) {
+		service.bus.NewSpaceAvailable(shelfItem.Order.Temp)
 	}
-
-	shelf.Push(shelfItem)
 	return nil
 }
 
-// collectOverflownShelveExpiredOrders - worker to  check for expired orders in overflown shelves
-func collectOverflownShelveExpiredOrders() {
-	for {
-		// Remove overflown shelf expired orders
-		for _, overflowCompartment := range repo.OverflowShelf {
-			item, err := overflowCompartment.Peek()
-
-			if err != nil {
-				continue
-			}
-
-			checkAndRemoveOverflownExpiredOrders(overflowCompartment, item)
-		}
-		time.Sleep(time.Second)
+// takeOffIfCancelled takes an order just put on a shelf off it if it was cancelled meanwhile, as the cancellation may
+// have missed it while it was moving between shelves. It tells whether the order was taken off; the space it frees on
+// a temperature controlled shelf is left to the caller, which may be the worker using new space available events
+func (service *Service) takeOffIfCancelled(shelf repo.IShelf, shelfType string, shelfItem model.ShelfItem) bool {
+	if !service.report.IsCancelled(shelfItem.Order.ID) {
+		return false
 	}
-}
 
-// collectOverflownShelveExpiredOrders checks and garbage collects expired orders from Overflow shelves
-func checkAndRemoveOverflownExpiredOrders(shelf repo.IShelf, shelfItem model.ShelfItem) {
-	if shelfItem == (model.ShelfItem{}) {
-		return
+	// Taken off by the cancellation itself or picked up by a courier otherwise
+	if _, err := shelf.Take(shelfItem.Order.ID); err != nil {
+		return false
 	}
+	zap.S().Infof("Storage: Order '%s'(%s) cancelled and removed from '%s' shelf", shelfItem.Order.ID, shelfItem.Order.Name, shelfType)
 
-	var err error = nil
-	for shelfItem != (model.ShelfItem{}) && err == nil {
-		currAge := int64(time.Now().Sub(shelfItem.CreatedTime).Seconds())
-		if currAge-shelfItem.MaxLifeTimeS >= 0 {
-			shelf.Pop()
+	// Send OrderStatus event - cancelled
+	service.bus.ReportStatus(model.OrderStatus{OrderId: shelfItem.Order.ID, Status: model.ORDER_CANCELLED, Time: service.bus.Clock.Now(), Shelf: shelfType})
+	return true
+}
 
-			// Send OrderStatus event
-			supervisor.SupervisorChannel <- model.OrderStatus{OrderId: shelfItem.Order.ID, Status: model.ORDER_EXPIRED}
+// reportDiscarded reports an order expired or evicted, and calls off the courier sent for it
+func (service *Service) reportDiscarded(status model.OrderStatus) {
+	service.bus.ReportStatus(status)
+	service.bus.CancelPickup(status.OrderId)
+}
 
-			zap.S().Infof("Storage: Order '%s'(%s) expired and removed; current age %d(s), max allowed age %d(s)", shelfItem.Order.ID, shelfItem.Order.Name, currAge, shelfItem.MaxLifeTimeS)
-			zap.S().Infof("Storage: Total number of items in overflow shelf '%d' at %s", shelf.Size(), time.Now())
-		} else {
-			break
+// collectOverflownShelveExpiredOrders - worker to  check for expired orders in overflown shelves every time the timer fires
+func (service *Service) collectOverflownShelveExpiredOrders(ctx context.Context, timer clock.Timer) {
+	for {
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C():
 		}
 
-		shelfItem, err = shelf.Peek()
+		// Remove overflown shelf expired orders
+		service.checkAndRemoveOverflownExpiredOrders(service.shelves.OverflowShelf, service.bus.Clock.Now())
+
+		timer = service.bus.Clock.NewTimer(time.Second)
+		service.bus.Clock.Done()
+	}
+}
+
+// checkAndRemoveOverflownExpiredOrders checks and garbage collects expired orders from Overflow shelves
+func (service *Service) checkAndRemoveOverflownExpiredOrders(shelf repo.IShelf, now time.Time) {
+	for _, shelfItem := range shelf.PopExpired(now) {
+		// Send OrderStatus event
+		service.reportDiscarded(model.OrderStatus{OrderId: shelfItem.Order.ID, Status: model.ORDER_EXPIRED, Time: now, Shelf: model.OVERFLOW})
+
+		zap.S().Infof("Storage: Order '%s'(%s) expired and removed; value %.2f", shelfItem.Order.ID, shelfItem.Order.Name, shelfItem.Value(now))
+		zap.S().Infof("Storage: Total number of items in overflow shelf '%d' at %s", shelf.Size(), now)
 	}
 }
 
 // collectTempControlledShelvesExpiredOrders checks and garbage colelcts any expired orsers from tempertaure controlled shelves (normal)
-func collectTempControlledShelvesExpiredOrders() {
+// every time the timer fires
+func (service *Service) collectTempControlledShelvesExpiredOrders(ctx context.Context, timer clock.Timer) {
 	for {
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C():
+		}
 
-		for _, shelfType := range repo.ShelfTemperatures {
-			shelf, _ := repo.ShelfFactory(shelfType)
-			shelftem, err := shelf.Peek()
-			if err == nil {
-				removeOrders(shelf, shelftem)
-			}
+		for _, shelfType := range service.shelves.ShelfTemperatures {
+			shelf, _ := service.shelves.ShelfFactory(shelfType)
+			service.removeOrders(shelf, service.bus.Clock.Now())
 		}
 
-		time.Sleep(time.Second)
+		timer = service.bus.Clock.NewTimer(time.Second)
+		service.bus.Clock.Done()
 	}
 }
 
-// removeOrders Removes the order with lowest priority which is available at root of priorityqueue (priority - order age)
-// The order which ages soon or already aged would be at top of the tree
-func removeOrders(shelf repo.IShelf, shelfItem model.ShelfItem) {
-	if shelfItem == (model.ShelfItem{}) {
-		return
-	}
-
-	var err error = nil
+// removeOrders Removes the orders with lowest priority which are available at root of priorityqueue (priority - expiry time)
+// as long as they are expired. The order which ages soon or already aged would be at top of the tree
+func (service *Service) removeOrders(shelf repo.IShelf, now time.Time) {
+	for _, shelfItem := range shelf.PopExpired(now) {
+		zap.S().Infof("Storage: Order '%s'(%s) expired and removed; value %.2f", shelfItem.Order.ID, shelfItem.Order.Name, shelfItem.Value(now))
 
-	for shelfItem != (model.ShelfItem{}) && err == nil {
-		currAge := int64(time.Now().Sub(shelfItem.CreatedTime).Seconds())
-		if currAge-shelfItem.MaxLifeTimeS >= 0 {
-			shelf.Pop()
-			zap.S().Infof("Storage: Order '%s'(%s) expired and removed; current age %d(s), max allowed age %d(s)", shelfItem.Order.ID, shelfItem.Order.Name, currAge, shelfItem.MaxLifeTimeS)
-
-			// Send OrderStatus event
-			supervisor.SupervisorChannel <- model.OrderStatus{OrderId: shelfItem.Order.ID, Status: model.ORDER_EXPIRED}
-
-			// Fire event - NewSpaceAvailable
-			supervisor.NewSpaceAvailableChannel <- shelfItem.Order.Temp
-			zap.S().Infof("Storage: New space available in shelf for '%s' at %s", shelfItem.Order.Temp, time.Now())
-		} else {
-			break
-		}
+		// Send OrderStatus event
+		service.reportDiscarded(model.OrderStatus{OrderId: shelfItem.Order.ID, Status: model.ORDER_EXPIRED, Time: now, Shelf: shelfItem.Order.Temp})
 
-		shelfItem, err = shelf.Peek()
+		// Fire event - NewSpaceAvailable
+		service.bus.NewSpaceAvailable(shelfItem.Order.Temp)
+		zap.S().Infof("Storage: New space available in shelf for '%s' at %s", shelfItem.Order.Temp, now)
 	}
 }
 
 // On SpaceOverflown event received, overflow shelf stores the overflown shelf item. If enough space is
 // not available on overflow shelf, it will remove a random shelf item and stores the incoming shelf item
-func processSpaceOverflownEvents() {
+func (service *Service) processSpaceOverflownEvents(ctx context.Context) {
 	for {
 		select {
-		case overflownShelfItem, isOpen := <-supervisor.OverflownChannel:
-			if !isOpen {
-				supervisor.OverflownChannel = nil
-				break
-			}
-			onSpaceOverflownEventReceived(overflownShelfItem)
-		}
-		if supervisor.OverflownChannel == nil {
-			break
+		case <-ctx.Done():
+			return
+		case overflownShelfItem := <-service.bus.OverflownChannel:
+			service.onSpaceOverflownEventReceived(overflownShelfItem)
+			service.bus.Handled()
 		}
 	}
 }
 
 // onSpaceOverflownEventReceived processes spaceOverflownEvent events
-func onSpaceOverflownEventReceived(overflownShelfItem model.ShelfItem) {
+func (service *Service) onSpaceOverflownEventReceived(overflownShelfItem model.ShelfItem) {
 	zap.S().Infof("Storage: Overflow shelf received Order '%s'(%s) to store in overflow shelf", overflownShelfItem.Order.Name, overflownShelfItem.Order.ID)
 
-	overflownShelf := repo.OverflowShelf[strings.ToLower(overflownShelfItem.Order.Temp)]
-	var overflowShelfCurrentSize int = 0
-	// Get size of all compartments together (total size is overflow shelf size)
-	for _, compartment := range repo.OverflowShelf {
-		overflowShelfCurrentSize += compartment.Size()
-	}
+	overflownShelf := service.shelves.OverflowShelf
 
-	// Calculate max order age for overflow shelf
-	maxAgeForOverflowShelf := pkg.CalculateMaxAge(overflownShelfItem.Order.ShelfLife, overflownShelfItem.Order.DecayRate, 2)
-	currentOrderAge := int64(time.Now().Sub(overflownShelfItem.CreatedTime).Seconds())
+	// Dont store the order if cancelled
+	if service.report.IsCancelled(overflownShelfItem.Order.ID) {
+		// Send OrderStatus event
+		service.bus.ReportStatus(model.OrderStatus{OrderId: overflownShelfItem.Order.ID, Status: model.ORDER_CANCELLED, Time: service.bus.Clock.Now()})
+
+		zap.S().Infof("Storage: Overflow shelf dropped order '%s'(%s) because it is cancelled", overflownShelfItem.Order.Name, overflownShelfItem.Order.ID)
+		return
+	}
 
 	// Check if the order is not expired, if so discard it or else store
-	if currentOrderAge >= maxAgeForOverflowShelf {
+	now := service.bus.Clock.Now()
+	if overflownShelfItem.IsExpired(now) {
 		// Send OrderStatus event
-		supervisor.SupervisorChannel <- model.OrderStatus{OrderId: overflownShelfItem.Order.ID, Status: model.ORDER_EXPIRED}
+		service.reportDiscarded(model.OrderStatus{OrderId: overflownShelfItem.Order.ID, Status: model.ORDER_EXPIRED, Time: now})
 
-		zap.S().Infof("Storage: Overflow shelf marked order '%s'(%s) as trash because it is expired. Expected below %d(s) but was %d(s)", overflownShelfItem.Order.Name, overflownShelfItem.Order.ID, maxAgeForOverflowShelf, currentOrderAge)
+		zap.S().Infof("Storage: Overflow shelf marked order '%s'(%s) as trash because it is expired; value %.2f", overflownShelfItem.Order.Name, overflownShelfItem.Order.ID, overflownShelfItem.Value(now))
 		return
 	}
 
-	// Check if overflow reached its max capacity. If so, remove a random order and make some space available for incoming item
-	if overflowShelfCurrentSize >= repo.ShelvesCapacity[model.OVERFLOW] {
-		zap.S().Infof("Storage: Overflow shelf reached its max size, removing random shelf item")
+	// From now on the order decays at the overflow shelf rate
+	overflownShelfItem = overflownShelfItem.Move(now, service.shelves.DecayModifiers[model.OVERFLOW])
 
-		randomItem, err := overflownShelf.GetRandomItem()
+	// Check if overflow reached its max capacity. If so, take an order off as the eviction policy chooses and make some space available for incoming item
+	if !overflownShelf.TryPush(overflownShelfItem) {
+		zap.S().Infof("Storage: Overflow shelf reached its max size, taking an order off with eviction policy '%s'", service.policy.Name())
 
-		if err == nil {
-			overflownShelf.Delete(randomItem.Order.ID)
-			zap.S().Infof("Storage: Overflow shelf removed random element: Order '%s'(%s)", randomItem.Order.ID, randomItem.Order.Name)
+		service.makeRoom(now)
 
+		if !overflownShelf.TryPush(overflownShelfItem) {
 			// Send OrderStatus event
-			supervisor.SupervisorChannel <- model.OrderStatus{OrderId: randomItem.Order.ID, Status: model.ORDER_EVICTED}
+			service.reportDiscarded(model.OrderStatus{OrderId: overflownShelfItem.Order.ID, Status: model.ORDER_EVICTED, Time: now})
+			zap.S().Infof("Storage: Overflow shelf has no room for order '%s'(%s); discarded", overflownShelfItem.Order.Name, overflownShelfItem.Order.ID)
+			return
 		}
 	}
 
-	// Update max age for overflown shelf
-	overflownShelfItem.MaxLifeTimeS = maxAgeForOverflowShelf - currentOrderAge
-	overflownShelf.Push(overflownShelfItem)
+	// Send OrderStatus event - moved to overflow shelf
+	service.bus.ReportStatus(model.OrderStatus{OrderId: overflownShelfItem.Order.ID, Status: model.ORDER_OVERFLOWN, Time: service.bus.Clock.Now(), Shelf: model.OVERFLOW})
+	service.takeOffIfCancelled(overflownShelf, model.OVERFLOW, overflownShelfItem)
 }
 
-// processNewShelfSpaceAvailable processes NewShelfSpaceAvailableEvent events usually fired by Normal Shelves worker and Dispatch service
-func processNewShelfSpaceAvailable() {
-	for {
-		select {
-		case newShelfSpaceTempType, more := <-supervisor.NewSpaceAvailableChannel:
-			if !more {
-				supervisor.NewSpaceAvailableChannel = nil
-				break
-			}
+// makeRoom takes an order off the full overflow shelf as the eviction policy chooses, and records the value lost to
+// it. The policy is asked again while the order it chose cannot be taken off, such as an order to move back to a shelf
+// filled in the meantime, which the policy then finds full
+func (service *Service) makeRoom(now time.Time) {
+	overflownShelf := service.shelves.OverflowShelf
+	for attempt := 0; attempt < overflownShelf.MaxCapacity(); attempt++ {
+		candidates := overflownShelf.Items()
+		if len(candidates) == 0 {
+			return
+		}
+
+		eviction := service.policy.Evict(candidates, service.shelves, now)
+		if service.evict(eviction, now) {
+			service.recordEvictionLoss(eviction, candidates, now)
+			return
+		}
+		zap.S().Infof("Storage: Overflow shelf could not take Order '%s'(%s) off as eviction policy '%s' chose; choosing again", eviction.Item.Order.ID, eviction.Item.Order.Name, service.policy.Name())
+	}
+}
 
-			onNewShelfSpaceAvailableReceived(newShelfSpaceTempType)
+// evict takes the order chosen by the eviction policy off the overflow shelf, moving it back to its temperature
+// controlled shelf or discarding it; it tells whether the order was taken off
+func (service *Service) evict(eviction Eviction, now time.Time) bool {
+	item := eviction.Item
+	if eviction.MoveTo != "" {
+		moved, freed := service.moveBack(item, now)
+		if freed {
+			service.bus.NewSpaceAvailable(item.Order.Temp)
 		}
+		return moved
+	}
+
+	if _, err := service.shelves.OverflowShelf.Take(item.Order.ID); err != nil {
+		return false
+	}
+	zap.S().Infof("Storage: Overflow shelf evicted Order '%s'(%s); value lost %.2f", item.Order.ID, item.Order.Name, eviction.Loss(now))
+
+	// Send OrderStatus event
+	service.reportDiscarded(model.OrderStatus{OrderId: item.Order.ID, Status: model.ORDER_EVICTED, Time: now, Shelf: model.OVERFLOW})
+	return true
+}
+
+// moveBack moves an order from the overflow shelf back to its temperature controlled shelf; it tells whether the order
+// was moved, and whether it was then taken off as cancelled, freeing the space on the shelf again. Room is reserved on
+// the temperature controlled shelf before the order is taken off the overflow shelf, so an order which cannot be moved
+// stays where it is
+func (service *Service) moveBack(item model.ShelfItem, now time.Time) (bool, bool) {
+	shelf, _ := service.shelves.ShelfFactory(item.Order.Temp)
+	moved := false
+	service.shelves.Transfer(func() {
+		// The temperature controlled shelf may have been filled in the meantime
+		if !shelf.TryPush(item.Move(now, service.shelves.DecayModifiers[item.Order.Temp])) {
+			return
+		}
+
+		// The order may have been picked up, expired or evicted in the meantime
+		if _, err := service.shelves.OverflowShelf.Take(item.Order.ID); err != nil {
+			shelf.Take(item.Order.ID)
+			return
+		}
+		moved = true
+	})
+	if !moved {
+		return false, false
+	}
+	zap.S().Infof("Storage: Overflow shelf moved Order '%s'(%s) back to %s shelf", item.Order.ID, item.Order.Name, item.Order.Temp)
+
+	// Send OrderStatus events - promoted from overflow shelf and stored
+	service.bus.ReportStatus(model.OrderStatus{OrderId: item.Order.ID, Status: model.ORDER_PROMOTED, Time: now, Shelf: model.OVERFLOW})
+	service.bus.ReportStatus(model.OrderStatus{OrderId: item.Order.ID, Status: model.ORDER_STORED, Time: now, Shelf: item.Order.Temp})
+	return true, service.takeOffIfCancelled(shelf, item.Order.Temp, item)
+}
+
+// recordEvictionLoss records the value lost to an eviction along with the value the other policies would have lost
+// on the same overflow shelf
+func (service *Service) recordEvictionLoss(eviction Eviction, candidates []model.ShelfItem, now time.Time) {
+	losses := map[string]float64{service.policy.Name(): eviction.Loss(now)}
+	for _, shadow := range service.shadows {
+		losses[shadow.Name()] = shadow.Evict(candidates, service.shelves, now).Loss(now)
+	}
+	service.report.RecordEvictionLoss(service.policy.Name(), losses)
+}
 
-		if supervisor.NewSpaceAvailableChannel == nil {
-			break
+// processNewShelfSpaceAvailable processes NewShelfSpaceAvailableEvent events usually fired by Normal Shelves worker and Dispatch service
+func (service *Service) processNewShelfSpaceAvailable(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case newShelfSpaceTempType := <-service.bus.NewSpaceAvailableChannel:
+			service.onNewShelfSpaceAvailableReceived(newShelfSpaceTempType)
+			service.bus.Handled()
 		}
 	}
 }
 
 // onNewShelfSpaceAvailableReceived processes NewShelfSpaceAvailableEvent events usually fired by normal shelves garbage collector
-func onNewShelfSpaceAvailableReceived(newShelfSpaceTempType string) {
+func (service *Service) onNewShelfSpaceAvailableReceived(newShelfSpaceTempType string) {
 	// Send order stored event
 	zap.S().Infof("Storage: Overflow cabin received new shelf space available for %s temp", newShelfSpaceTempType)
 
-	// On new shelf space available, promote an item from overflow shelf to corresponding shelf with that temperature
-	var shelf repo.IShelf = repo.OverflowShelf[strings.ToLower(newShelfSpaceTempType)]
-	item, err := shelf.Pop()
-
-	if err == nil {
-		// Recalculate the max life time as per the normal shelves Factor value
-		// Need not to check if this item is already expired before moving to main shelf, the main shelf is responsible to check before storing
-		maxAgeForNormalShelves := pkg.CalculateMaxAge(item.Order.ShelfLife, item.Order.DecayRate, 1)
-		currentAge := int64(time.Now().Sub(item.CreatedTime).Seconds())
-
-		// Send StoreOrder event
-		zap.S().Infof("Storage: Order '%s' (%s) removed from overflow and sent to store on normal temp shelf", item.Order.Name, item.Order.ID)
-		item.MaxLifeTimeS = maxAgeForNormalShelves - currentAge
-		supervisor.StorageChannel <- item
-		zap.S().Infof("Storage: Total number of items in shelf '%d' at %s", shelf.Size(), time.Now())
+	// On new shelf space available, promote an item from overflow shelf to corresponding shelf with that temperature.
+	// It is moved straight to the shelf, so it is on one shelf or the other at all times
+	shelf := service.shelves.OverflowShelf
+	for {
+		item, err := shelf.PeekOf(strings.ToLower(newShelfSpaceTempType))
+		if err != nil {
+			return
+		}
+
+		// Need not to check if this item is already expired before moving to main shelf, the main shelf garbage
+		// collector removes it
+		moved, freed := service.moveBack(item, service.bus.Clock.Now())
+		if moved {
+			zap.S().Infof("Storage: Total number of items in shelf '%d' at %s", shelf.Size(), service.bus.Clock.Now())
+		}
+
+		// An order cancelled meanwhile frees the space again; it is used right away rather than told to this worker,
+		// whose channel may be full
+		if !freed {
+			return
+		}
 	}
 }
@@ -1,16 +1,25 @@
 package storage
 
 import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/bus"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/clock"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/config"
 	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/model"
 	repo "sharedkitchenordersystem/internal/app/sharedkitchenordersystem/repository/shelf"
 	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/service/supervisor"
-	"sharedkitchenordersystem/internal/pkg"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
 
 func Test_storeItem(t *testing.T) {
+	service := newTestService()
+
 	type args struct {
 		shelfItem model.ShelfItem
 	}
@@ -20,40 +29,95 @@ func Test_storeItem(t *testing.T) {
 	}{
 		{
 			name: "Test_storeItem_ShouldStore_HotShelfItem_Success",
-			args: args{model.ShelfItem{Order: model.Order{ID: "1", Name: "chicken", DecayRate: 1, ShelfLife: 20, Temp: "hot"},
-				MaxLifeTimeS: pkg.CalculateMaxAge(20, 1, 1), CreatedTime: time.Now()}},
+			args: args{model.NewShelfItem(model.Order{ID: "1", Name: "chicken", DecayRate: 1, ShelfLife: 20, Temp: "hot"}, time.Now(), 1)},
 		},
 		{
 			name: "Test_storeItem_ShouldStore_ColdShelfItem_Success",
-			args: args{model.ShelfItem{Order: model.Order{ID: "2", Name: "juice", DecayRate: 1, ShelfLife: 10, Temp: "cold"},
-				MaxLifeTimeS: pkg.CalculateMaxAge(10, 1, 1), CreatedTime: time.Now()}},
+			args: args{model.NewShelfItem(model.Order{ID: "2", Name: "juice", DecayRate: 1, ShelfLife: 10, Temp: "cold"}, time.Now(), 1)},
 		},
 		{
 			name: "Test_storeItem_ShouldStore_FrozenShelfItem_Success",
-			args: args{model.ShelfItem{Order: model.Order{ID: "3", Name: "ice cream", DecayRate: 1, ShelfLife: 15, Temp: "frozen"},
-				MaxLifeTimeS: pkg.CalculateMaxAge(15, 1, 1), CreatedTime: time.Now()}},
+			args: args{model.NewShelfItem(model.Order{ID: "3", Name: "ice cream", DecayRate: 1, ShelfLife: 15, Temp: "frozen"}, time.Now(), 1)},
 		},
 	}
-	repo.Initialize()
-	supervisor.Start(1)
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			storeItem(tt.args.shelfItem)
-			if shelf, err := repo.ShelfFactory(tt.args.shelfItem.Order.Temp); err != nil || !shelf.IsPresent(tt.args.shelfItem.Order.ID) {
+			service.storeItem(tt.args.shelfItem)
+			if shelf, err := service.shelves.ShelfFactory(tt.args.shelfItem.Order.Temp); err != nil || !shelf.IsPresent(tt.args.shelfItem.Order.ID) {
 				t.Errorf("internalProcess(), order %v not stored", tt.args.shelfItem.Order.ID)
 			}
 		})
 	}
 }
 
-func getShelf(temp string) repo.IShelf {
-	s, _ := repo.ShelfFactory(temp)
+func Test_storeItem_CancelledOrder(t *testing.T) {
+	tests := []struct {
+		name         string
+		cancelBefore bool
+	}{
+		{
+			name:         "Test_storeItem_CancelledOrder_NotStored",
+			cancelBefore: true,
+		},
+		{
+			name: "Test_takeOffIfCancelled_CancelledWhileStored_TakenOff",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := newTestService()
+			shelfItem := model.NewShelfItem(model.Order{ID: "1", Name: "chicken", DecayRate: 1, ShelfLife: 20, Temp: model.HOT}, time.Now(), 1)
+			service.report.Accept([]model.Order{shelfItem.Order})
+
+			// The cancellation either comes before the order is stored or misses it on its way to the shelf
+			if tt.cancelBefore {
+				service.report.Cancel(shelfItem.Order.ID)
+				service.storeItem(shelfItem)
+			} else {
+				service.storeItem(shelfItem)
+				service.report.Cancel(shelfItem.Order.ID)
+				if !service.takeOffIfCancelled(getShelf(service, model.HOT), model.HOT, shelfItem) {
+					t.Errorf("takeOffIfCancelled(), got order %s left on the shelf, want taken off", shelfItem.Order.ID)
+				}
+			}
+
+			if getShelf(service, model.HOT).IsPresent(shelfItem.Order.ID) {
+				t.Errorf("storeItem(), got cancelled order %s on the shelf, want not stored", shelfItem.Order.ID)
+			}
+
+			for i := 0; i < 100; i++ {
+				if details, err := service.report.Lookup(shelfItem.Order.ID); err == nil && details.Status == model.ORDER_CANCELLED {
+					return
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			t.Errorf("storeItem(), got order %s never cancelled, want cancelled", shelfItem.Order.ID)
+		})
+	}
+}
+
+// newTestService creates a storage service with the default shelves and a running supervisor
+func newTestService() *Service {
+	return newTestServiceWithPolicy(config.Default().EvictionPolicy)
+}
+
+// newTestServiceWithPolicy creates a storage service with the default shelves, a running supervisor and the given
+// eviction policy
+func newTestServiceWithPolicy(policy string) *Service {
+	eventBus := bus.New(10, clock.NewWallClock())
+	shelves := repo.New(config.Default().Shelves)
+	kitchenSupervisor := supervisor.New(eventBus, shelves, config.Default().IdleTimeoutS)
+	kitchenSupervisor.Start(context.Background())
+	return New(eventBus, shelves, kitchenSupervisor.Report, policy, 0, rand.New(rand.NewSource(1)))
+}
+
+func getShelf(service *Service, temp string) repo.IShelf {
+	s, _ := service.shelves.ShelfFactory(temp)
 	return s
 }
 
 func Test_checkAndRemoveOverflownExpiredOrders(t *testing.T) {
-	repo.Initialize()
-	supervisor.Start(1)
+	service := newTestService()
 
 	type args struct {
 		shelf     repo.IShelf
@@ -67,17 +131,17 @@ func Test_checkAndRemoveOverflownExpiredOrders(t *testing.T) {
 		{
 			name: "Test_checkAndRemoveOverflownExpiredOrders_ItemExpired_MustBeRemoved",
 			args: args{
-				shelf: getShelf(model.HOT),
-				shelfItem: model.ShelfItem{Order: model.Order{
-					ID: "3", Name: "chicken", DecayRate: 1, ShelfLife: 10, Temp: "hot"}, MaxLifeTimeS: 5, CreatedTime: time.Now().Add(-5 * time.Second)}},
+				shelf: getShelf(service, model.HOT),
+				shelfItem: model.NewShelfItem(model.Order{
+					ID: "3", Name: "chicken", DecayRate: 1, ShelfLife: 10, Temp: "hot"}, time.Now().Add(-10*time.Second), 1)},
 			mustBeRemoved: true,
 		},
 		{
 			name: "Test_checkAndRemoveOverflownExpiredOrders_ItemExpired_MustNotBeRemoved",
 			args: args{
-				shelf: getShelf(model.HOT),
-				shelfItem: model.ShelfItem{Order: model.Order{
-					ID: "3", Name: "chicken", DecayRate: 1, ShelfLife: 10, Temp: "hot"}, MaxLifeTimeS: 100, CreatedTime: time.Now().Add(time.Second)}},
+				shelf: getShelf(service, model.HOT),
+				shelfItem: model.NewShelfItem(model.Order{
+					ID: "3", Name: "chicken", DecayRate: 1, ShelfLife: 10, Temp: "hot"}, time.Now().Add(time.Second), 1)},
 			mustBeRemoved: false,
 		},
 	}
@@ -85,7 +149,7 @@ func Test_checkAndRemoveOverflownExpiredOrders(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.args.shelf.Push(tt.args.shelfItem)
-			checkAndRemoveOverflownExpiredOrders(tt.args.shelf, tt.args.shelfItem)
+			service.checkAndRemoveOverflownExpiredOrders(tt.args.shelf, time.Now())
 			isRemoved := !tt.args.shelf.IsPresent(tt.args.shelfItem.Order.ID)
 			if isRemoved != tt.mustBeRemoved {
 				t.Errorf("checkAndRemoveOverflownExpiredOrders(), got isExpiredRemoved:%v, want %v ", isRemoved, tt.mustBeRemoved)
@@ -95,8 +159,7 @@ func Test_checkAndRemoveOverflownExpiredOrders(t *testing.T) {
 }
 
 func Test_removeOrders(t *testing.T) {
-	repo.Initialize()
-	supervisor.Start(1)
+	service := newTestService()
 
 	type args struct {
 		shelf     repo.IShelf
@@ -110,17 +173,17 @@ func Test_removeOrders(t *testing.T) {
 		{
 			name: "Test_removeOrders_ItemExpired_MustBeRemoved",
 			args: args{
-				shelf: getShelf(model.HOT),
-				shelfItem: model.ShelfItem{Order: model.Order{
-					ID: "3", Name: "chicken", DecayRate: 1, ShelfLife: 10, Temp: "hot"}, MaxLifeTimeS: 5, CreatedTime: time.Now().Add(-5 * time.Second)}},
+				shelf: getShelf(service, model.HOT),
+				shelfItem: model.NewShelfItem(model.Order{
+					ID: "3", Name: "chicken", DecayRate: 1, ShelfLife: 10, Temp: "hot"}, time.Now().Add(-10*time.Second), 1)},
 			mustBeRemoved: true,
 		},
 		{
 			name: "Test_removeOrders_ItemExpired_MustNotBeRemoved",
 			args: args{
-				shelf: getShelf(model.HOT),
-				shelfItem: model.ShelfItem{Order: model.Order{
-					ID: "1", Name: "chicken", DecayRate: 1, ShelfLife: 10, Temp: "hot"}, MaxLifeTimeS: 100, CreatedTime: time.Now().Add(time.Second)}},
+				shelf: getShelf(service, model.HOT),
+				shelfItem: model.NewShelfItem(model.Order{
+					ID: "1", Name: "chicken", DecayRate: 1, ShelfLife: 10, Temp: "hot"}, time.Now().Add(time.Second), 1)},
 			mustBeRemoved: false,
 		},
 	}
@@ -128,7 +191,7 @@ func Test_removeOrders(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.args.shelf.Push(tt.args.shelfItem)
-			removeOrders(tt.args.shelf, tt.args.shelfItem)
+			service.removeOrders(tt.args.shelf, time.Now())
 			isRemoved := !tt.args.shelf.IsPresent(tt.args.shelfItem.Order.ID)
 			if isRemoved != tt.mustBeRemoved {
 				t.Errorf("removeOrders(), got isExpiredRemoved:%v, want %v ", isRemoved, tt.mustBeRemoved)
@@ -138,8 +201,7 @@ func Test_removeOrders(t *testing.T) {
 }
 
 func Test_onSpaceOverflownEventReceived(t *testing.T) {
-	repo.Initialize()
-	supervisor.Start(10)
+	service := newTestService()
 
 	type args struct {
 		shelf     repo.IShelf
@@ -153,25 +215,25 @@ func Test_onSpaceOverflownEventReceived(t *testing.T) {
 		{
 			name: "Test_onSpaceOverflownEventReceived_ItemExpired_NotStored",
 			args: args{
-				shelf: getShelf(model.HOT),
-				shelfItem: model.ShelfItem{Order: model.Order{
-					ID: "10", Name: "chicken", DecayRate: 1, ShelfLife: 10, Temp: "hot"}, MaxLifeTimeS: pkg.CalculateMaxAge(10, 1, 1), CreatedTime: time.Now().Add(-5 * time.Second)}},
+				shelf: getShelf(service, model.HOT),
+				shelfItem: model.NewShelfItem(model.Order{
+					ID: "10", Name: "chicken", DecayRate: 1, ShelfLife: 10, Temp: "hot"}, time.Now().Add(-10*time.Second), 1)},
 			mustBeStored: false,
 		},
 		{
 			name: "Test_onSpaceOverflownEventReceived_ItemNotExpired_IsStored",
 			args: args{
-				shelf: getShelf(model.COLD),
-				shelfItem: model.ShelfItem{Order: model.Order{
-					ID: "20", Name: "ice cream", DecayRate: 1, ShelfLife: 100, Temp: "cold"}, MaxLifeTimeS: pkg.CalculateMaxAge(100, 0.2, 1), CreatedTime: time.Now()}},
+				shelf: getShelf(service, model.COLD),
+				shelfItem: model.NewShelfItem(model.Order{
+					ID: "20", Name: "ice cream", DecayRate: 1, ShelfLife: 100, Temp: "cold"}, time.Now(), 1)},
 			mustBeStored: true,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			onSpaceOverflownEventReceived(tt.args.shelfItem)
-			overflownShelf := repo.OverflowShelf[strings.ToLower(tt.args.shelfItem.Order.Temp)]
+			service.onSpaceOverflownEventReceived(tt.args.shelfItem)
+			overflownShelf := service.shelves.OverflowShelf
 			isStored := overflownShelf.IsPresent(strings.ToLower(tt.args.shelfItem.Order.ID))
 			if isStored != tt.mustBeStored {
 				t.Errorf("processSpaceOverflownEvents(), got %v, want %v ", isStored, tt.mustBeStored)
@@ -181,8 +243,7 @@ func Test_onSpaceOverflownEventReceived(t *testing.T) {
 }
 
 func Test_onNewShelfSpaceAvailableReceived(t *testing.T) {
-	repo.Initialize()
-	supervisor.Start(10)
+	service := newTestService()
 
 	type args struct {
 		shelf     repo.IShelf
@@ -194,28 +255,28 @@ func Test_onNewShelfSpaceAvailableReceived(t *testing.T) {
 		mustBeRemovedFromOverflownShelf bool
 	}{
 		{
-			name: "Test_onNewShelfSpaceAvailableReceived_RemoveItem_SendToStore",
+			name: "Test_onNewShelfSpaceAvailableReceived_RemoveItem_MovedToShelf",
 			args: args{
-				shelf: getShelf(model.HOT),
-				shelfItem: model.ShelfItem{Order: model.Order{
-					ID: "10", Name: "chicken", DecayRate: 1, ShelfLife: 10, Temp: "hot"}, MaxLifeTimeS: pkg.CalculateMaxAge(10, 1, 1), CreatedTime: time.Now().Add(-5 * time.Second)}},
+				shelf: getShelf(service, model.HOT),
+				shelfItem: model.NewShelfItem(model.Order{
+					ID: "10", Name: "chicken", DecayRate: 1, ShelfLife: 10, Temp: "hot"}, time.Now().Add(-10*time.Second), 1)},
 			mustBeRemovedFromOverflownShelf: true,
 		},
 		{
-			name: "Test_onNewShelfSpaceAvailableReceived_RemoveItem_SendToStore",
+			name: "Test_onNewShelfSpaceAvailableReceived_RemoveItem_MovedToShelf",
 			args: args{
-				shelf: getShelf(model.COLD),
-				shelfItem: model.ShelfItem{Order: model.Order{
-					ID: "10", Name: "chicken", DecayRate: 0.5, ShelfLife: 100, Temp: "cold"}, MaxLifeTimeS: pkg.CalculateMaxAge(100, 1, 1), CreatedTime: time.Now()}},
+				shelf: getShelf(service, model.COLD),
+				shelfItem: model.NewShelfItem(model.Order{
+					ID: "10", Name: "chicken", DecayRate: 0.5, ShelfLife: 100, Temp: "cold"}, time.Now(), 1)},
 			mustBeRemovedFromOverflownShelf: true,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			overflownShelf := repo.OverflowShelf[strings.ToLower(tt.args.shelfItem.Order.Temp)]
+			overflownShelf := service.shelves.OverflowShelf
 			overflownShelf.Push(tt.args.shelfItem)
-			onNewShelfSpaceAvailableReceived(tt.args.shelfItem.Order.Temp)
+			service.onNewShelfSpaceAvailableReceived(tt.args.shelfItem.Order.Temp)
 			isRemoved := !overflownShelf.IsPresent(strings.ToLower(tt.args.shelfItem.Order.ID))
 			if isRemoved != tt.mustBeRemovedFromOverflownShelf {
 				t.Errorf("onNewShelfSpaceAvailableReceive(), got %v, want %v ", isRemoved, tt.mustBeRemovedFromOverflownShelf)
@@ -223,3 +284,172 @@ func Test_onNewShelfSpaceAvailableReceived(t *testing.T) {
 		})
 	}
 }
+
+func Test_onNewShelfSpaceAvailableReceived_CancelledOrder_NextOrderMoved(t *testing.T) {
+	service := newTestService()
+	hot := getShelf(service, model.HOT)
+
+	// The cancelled order expires first, so it is the first moved back
+	cancelled := model.NewShelfItem(model.Order{ID: "1", Name: "chicken", DecayRate: 1, ShelfLife: 50, Temp: model.HOT}, time.Now(), 2)
+	next := model.NewShelfItem(model.Order{ID: "2", Name: "chicken", DecayRate: 1, ShelfLife: 100, Temp: model.HOT}, time.Now(), 2)
+	service.shelves.OverflowShelf.Push(cancelled)
+	service.shelves.OverflowShelf.Push(next)
+	service.report.Accept([]model.Order{cancelled.Order, next.Order})
+	if err := service.report.Cancel(cancelled.Order.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing drains the new space available events but the worker receiving this one
+	for len(service.bus.NewSpaceAvailableChannel) < cap(service.bus.NewSpaceAvailableChannel) {
+		service.bus.NewSpaceAvailableChannel <- model.COLD
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		service.onNewShelfSpaceAvailableReceived(model.HOT)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("onNewShelfSpaceAvailableReceived(), got blocked, want the space freed by the cancelled order used")
+	}
+
+	if hot.IsPresent(cancelled.Order.ID) || !hot.IsPresent(next.Order.ID) || service.shelves.OverflowShelf.Size() != 0 {
+		t.Errorf("onNewShelfSpaceAvailableReceived(), got order 1 on shelf %v and order 2 on shelf %v, want only order 2 moved back", hot.IsPresent(cancelled.Order.ID), hot.IsPresent(next.Order.ID))
+	}
+}
+
+func Test_storeItem_Concurrent(t *testing.T) {
+	service := newTestService()
+	hot := getShelf(service, model.HOT)
+
+	// Count the orders sent to the overflow shelf
+	var overflown int32
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-service.bus.OverflownChannel:
+				atomic.AddInt32(&overflown, 1)
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5*hot.MaxCapacity(); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			service.storeItem(model.NewShelfItem(model.Order{ID: fmt.Sprintf("%d", i), Name: "chicken", DecayRate: 1, ShelfLife: 100, Temp: model.HOT}, time.Now(), 1))
+		}(i)
+	}
+	wg.Wait()
+	close(stop)
+	<-done
+
+	if hot.Size() != hot.MaxCapacity() || int(overflown) != 4*hot.MaxCapacity() {
+		t.Errorf("storeItem(), got %d orders stored and %d overflown, want %d stored and %d overflown", hot.Size(), overflown, hot.MaxCapacity(), 4*hot.MaxCapacity())
+	}
+}
+
+func Test_moveBack(t *testing.T) {
+	tests := []struct {
+		name           string
+		fillShelf      bool
+		onOverflow     bool
+		wantMoved      bool
+		wantOnShelf    bool
+		wantOnOverflow bool
+	}{
+		{
+			name:        "Test_moveBack_RoomOnShelf_Moved",
+			onOverflow:  true,
+			wantMoved:   true,
+			wantOnShelf: true,
+		},
+		{
+			name:           "Test_moveBack_ShelfFull_KeptOnOverflow",
+			fillShelf:      true,
+			onOverflow:     true,
+			wantOnOverflow: true,
+		},
+		{
+			name: "Test_moveBack_TakenOffOverflow_RoomGivenBack",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := newTestService()
+			hot := getShelf(service, model.HOT)
+			if tt.fillShelf {
+				for i := 0; i < hot.MaxCapacity(); i++ {
+					hot.Push(model.NewShelfItem(model.Order{ID: fmt.Sprintf("filler-%d", i), Name: "chicken", DecayRate: 1, ShelfLife: 100, Temp: model.HOT}, time.Now(), 1))
+				}
+			}
+
+			item := model.NewShelfItem(model.Order{ID: "10", Name: "chicken", DecayRate: 1, ShelfLife: 100, Temp: model.HOT}, time.Now(), 2)
+			if tt.onOverflow {
+				service.shelves.OverflowShelf.Push(item)
+			}
+
+			moved, _ := service.moveBack(item, time.Now())
+			onShelf, onOverflow := hot.IsPresent(item.Order.ID), service.shelves.OverflowShelf.IsPresent(item.Order.ID)
+			if moved != tt.wantMoved || onShelf != tt.wantOnShelf || onOverflow != tt.wantOnOverflow {
+				t.Errorf("moveBack(), got moved %v, on shelf %v and on overflow %v, want %v, %v and %v", moved, onShelf, onOverflow, tt.wantMoved, tt.wantOnShelf, tt.wantOnOverflow)
+			}
+		})
+	}
+}
+
+// racingPolicy fills the shelf its policy chose to move an order back to before the order is moved, as an order
+// stored concurrently would
+type racingPolicy struct {
+	EvictionPolicy
+	fill func(temp string)
+}
+
+func (policy *racingPolicy) Evict(candidates []model.ShelfItem, shelves *repo.Repository, now time.Time) Eviction {
+	eviction := policy.EvictionPolicy.Evict(candidates, shelves, now)
+	if eviction.MoveTo != "" {
+		policy.fill(eviction.MoveTo)
+	}
+	return eviction
+}
+
+func Test_onSpaceOverflownEventReceived_RelocationShelfFilled_Discarded(t *testing.T) {
+	service := newTestServiceWithPolicy(config.EVICT_RELOCATE)
+	hot := getShelf(service, model.HOT)
+	service.policy = &racingPolicy{EvictionPolicy: service.policy, fill: func(temp string) {
+		for i := 0; hot.Size() < hot.MaxCapacity(); i++ {
+			hot.Push(model.NewShelfItem(model.Order{ID: fmt.Sprintf("filler-%d", i), Name: "chicken", DecayRate: 1, ShelfLife: 100, Temp: model.HOT}, time.Now(), 1))
+		}
+	}}
+
+	overflownShelf := service.shelves.OverflowShelf
+	for i := 0; i < overflownShelf.MaxCapacity(); i++ {
+		overflownShelf.Push(model.NewShelfItem(model.Order{ID: fmt.Sprintf("overflown-%d", i), Name: "chicken", DecayRate: 1, ShelfLife: 100, Temp: model.HOT}, time.Now(), 2))
+	}
+
+	item := model.NewShelfItem(model.Order{ID: "10", Name: "chicken", DecayRate: 1, ShelfLife: 100, Temp: model.HOT}, time.Now(), 2)
+	service.onSpaceOverflownEventReceived(item)
+
+	if !overflownShelf.IsPresent(item.Order.ID) || overflownShelf.Size() != overflownShelf.MaxCapacity() {
+		t.Errorf("onSpaceOverflownEventReceived(), got stored %v with %d orders overflown, want stored with %d", overflownShelf.IsPresent(item.Order.ID), overflownShelf.Size(), overflownShelf.MaxCapacity())
+	}
+
+	report := service.report.Report()
+	for _, loss := range report.EvictionLoss {
+		if loss.InUse && (report.Evictions != 1 || loss.Loss <= 0) {
+			t.Errorf("onSpaceOverflownEventReceived(), got %d evictions losing %.2f, want 1 eviction losing value", report.Evictions, loss.Loss)
+		}
+	}
+	if report.Evictions != 1 {
+		t.Errorf("onSpaceOverflownEventReceived(), got %d evictions, want 1", report.Evictions)
+	}
+}
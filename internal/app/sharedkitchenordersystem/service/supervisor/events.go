@@ -0,0 +1,101 @@
+package supervisor
+
+import (
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/model"
+
+	"go.uber.org/zap"
+)
+
+// Number of events a subscription holds until its subscriber receives them
+const subscriptionBuffer int = 100
+
+// EventFilter selects order events by order id, temperature and status; an empty list selects any
+type EventFilter struct {
+	OrderIds     []string
+	Temperatures []string
+	Statuses     []string
+}
+
+// Matches tells whether the filter selects the event
+func (filter EventFilter) Matches(event model.OrderEvent) bool {
+	return isSelected(event.OrderId, filter.OrderIds) && isSelected(event.Temp, filter.Temperatures) && isSelected(event.Status, filter.Statuses)
+}
+
+func isSelected(value string, selected []string) bool {
+	if len(selected) == 0 {
+		return true
+	}
+
+	for _, candidate := range selected {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Subscription receives the events of the statuses recorded after it subscribed and selected by its filter. A
+// subscriber which does not keep up with the events is unsubscribed rather than holding the kitchen back
+type Subscription struct {
+	filter EventFilter
+	events chan model.OrderEvent
+}
+
+// Events gives the events of the subscription, in the order they are recorded; the channel is closed once the
+// subscription ends
+func (subscription *Subscription) Events() <-chan model.OrderEvent {
+	return subscription.events
+}
+
+// Subscribe subscribes to the events of the statuses recorded from now on and selected by the filter
+func (r *ReportBook) Subscribe(filter EventFilter) *Subscription {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+
+	subscription := &Subscription{filter: filter, events: make(chan model.OrderEvent, subscriptionBuffer)}
+	r.subscriptions[subscription] = true
+	return subscription
+}
+
+// Unsubscribe ends a subscription; ending a subscription already ended has no effect
+func (r *ReportBook) Unsubscribe(subscription *Subscription) {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+
+	r.unsubscribe(subscription)
+}
+
+// CloseSubscriptions ends every subscription
+func (r *ReportBook) CloseSubscriptions() {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+
+	for subscription := range r.subscriptions {
+		r.unsubscribe(subscription)
+	}
+}
+
+// unsubscribe ends a subscription; the report book must be locked
+func (r *ReportBook) unsubscribe(subscription *Subscription) {
+	if r.subscriptions[subscription] {
+		delete(r.subscriptions, subscription)
+		close(subscription.events)
+	}
+}
+
+// publish sends the event of a status recorded to the subscriptions selecting it; the report book must be locked
+func (r *ReportBook) publish(status model.OrderStatus) {
+	event := model.OrderEvent{OrderStatus: status, Temp: r.temperatures[status.OrderId]}
+	for subscription := range r.subscriptions {
+		if !subscription.filter.Matches(event) {
+			continue
+		}
+
+		select {
+		case subscription.events <- event:
+		default:
+			zap.S().Infof("Supervisor: Subscriber too slow to receive the events; unsubscribed")
+			r.unsubscribe(subscription)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package supervisor
+
+import (
+	"reflect"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/bus"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/clock"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/config"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/model"
+	repo "sharedkitchenordersystem/internal/app/sharedkitchenordersystem/repository/shelf"
+	"testing"
+)
+
+func TestEventFilter_Matches(t *testing.T) {
+	event := model.OrderEvent{OrderStatus: model.OrderStatus{OrderId: "1", Status: model.ORDER_STORED}, Temp: model.HOT}
+
+	tests := []struct {
+		name   string
+		filter EventFilter
+		want   bool
+	}{
+		{
+			name:   "TestEventFilter_Matches_EmptyFilter_Matches",
+			filter: EventFilter{},
+			want:   true,
+		},
+		{
+			name:   "TestEventFilter_Matches_EveryListSelectsEvent_Matches",
+			filter: EventFilter{OrderIds: []string{"2", "1"}, Temperatures: []string{model.HOT}, Statuses: []string{model.ORDER_STORED, model.ORDER_PICKED}},
+			want:   true,
+		},
+		{
+			name:   "TestEventFilter_Matches_OtherOrder_NoMatch",
+			filter: EventFilter{OrderIds: []string{"2"}},
+			want:   false,
+		},
+		{
+			name:   "TestEventFilter_Matches_OtherTemperature_NoMatch",
+			filter: EventFilter{Temperatures: []string{model.COLD, model.FROZEN}},
+			want:   false,
+		},
+		{
+			name:   "TestEventFilter_Matches_OtherStatus_NoMatch",
+			filter: EventFilter{OrderIds: []string{"1"}, Statuses: []string{model.ORDER_PICKED}},
+			want:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Matches(event); got != tt.want {
+				t.Errorf("Matches(), got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReportBook_Subscribe(t *testing.T) {
+	tests := []struct {
+		name       string
+		filter     EventFilter
+		statuses   int
+		wantEvents []string
+		wantClosed bool
+	}{
+		{
+			name:       "TestReportBook_Subscribe_HotOrders_ReceivesTheirEventsWithTemperature",
+			filter:     EventFilter{Temperatures: []string{model.HOT}},
+			statuses:   1,
+			wantEvents: []string{"1:" + model.HOT + ":" + model.ORDER_RECEIVED},
+		},
+		{
+			name:       "TestReportBook_Subscribe_AnyOrder_ReceivesEveryEventInOrder",
+			filter:     EventFilter{},
+			statuses:   1,
+			wantEvents: []string{"1:" + model.HOT + ":" + model.ORDER_RECEIVED, "2:" + model.COLD + ":" + model.ORDER_RECEIVED},
+		},
+		{
+			name:       "TestReportBook_Subscribe_SlowSubscriber_Closed",
+			filter:     EventFilter{OrderIds: []string{"1"}},
+			statuses:   subscriptionBuffer + 1,
+			wantClosed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := New(bus.New(10, clock.NewWallClock()), repo.New(config.Default().Shelves), config.Default().IdleTimeoutS).Report
+			report.Accept([]model.Order{{ID: "1", Temp: model.HOT}, {ID: "2", Temp: model.COLD}})
+
+			subscription := report.Subscribe(tt.filter)
+			for i := 0; i < tt.statuses; i++ {
+				report.push(model.OrderStatus{OrderId: "1", Status: model.ORDER_RECEIVED})
+				report.push(model.OrderStatus{OrderId: "2", Status: model.ORDER_RECEIVED})
+			}
+
+			if tt.wantClosed {
+				for range subscription.Events() {
+				}
+				return
+			}
+
+			report.Unsubscribe(subscription)
+			events := []string{}
+			for event := range subscription.Events() {
+				events = append(events, event.OrderId+":"+event.Temp+":"+event.Status)
+
+				// Statuses pushed without a time are published with the time they are recorded with
+				if details, err := report.Lookup(event.OrderId); err != nil || event.Time.IsZero() || !event.Time.Equal(details.History[0].Time) {
+					t.Errorf("Subscribe(), got event of order '%s' at %s, want the time it was recorded at", event.OrderId, event.Time)
+				}
+			}
+			if !reflect.DeepEqual(events, tt.wantEvents) {
+				t.Errorf("Subscribe(), got events %v, want %v", events, tt.wantEvents)
+			}
+		})
+	}
+}
+
+func TestReportBook_CloseSubscriptions(t *testing.T) {
+	report := New(bus.New(10, clock.NewWallClock()), repo.New(config.Default().Shelves), config.Default().IdleTimeoutS).Report
+	subscriptions := []*Subscription{report.Subscribe(EventFilter{}), report.Subscribe(EventFilter{})}
+
+	report.CloseSubscriptions()
+	report.Unsubscribe(subscriptions[0])
+
+	for _, subscription := range subscriptions {
+		if _, isOpen := <-subscription.Events(); isOpen {
+			t.Errorf("CloseSubscriptions(), got subscription open, want closed")
+		}
+	}
+}
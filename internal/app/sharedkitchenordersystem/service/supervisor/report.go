@@ -0,0 +1,248 @@
+package supervisor
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/model"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Report is the order status report of a run: the number of orders by status, the waste of the eviction policies,
+// the utilisation of the couriers, the waits of the dispatch strategies and the outcome of every order
+type Report struct {
+	Time time.Time `json:"time"`
+
+	Received  int `json:"received"`
+	Rejected  int `json:"rejected"`
+	Processed int `json:"processed"`
+	PickedUp  int `json:"pickedUp"`
+	Expired   int `json:"expired"`
+	Evicted   int `json:"evicted"`
+	Cancelled int `json:"cancelled"`
+	Wasted    int `json:"wasted"`
+
+	// Orders processed out of the orders received, and orders picked up, expired and evicted out of the orders
+	// processed; 0 when there are no orders to compare to
+	ProcessedPercentage float64 `json:"processedPercentage"`
+	DeliveryPercentage  float64 `json:"deliveryPercentage"`
+	ExpiredPercentage   float64 `json:"expiredPercentage"`
+	EvictedPercentage   float64 `json:"evictedPercentage"`
+
+	// Orders picked up out of the orders received
+	SuccessPercentage float64 `json:"successPercentage"`
+
+	// Evictions from the full overflow shelf, and the value they lost by eviction policy
+	Evictions    int            `json:"evictions"`
+	EvictionLoss []PolicyLoss   `json:"evictionLoss"`
+	Couriers     *CourierReport `json:"couriers,omitempty"`
+	Strategies   []StrategyWait `json:"strategies"`
+
+	Orders []OrderReport `json:"orders"`
+}
+
+// PolicyLoss is the value an eviction policy lost, or would have lost, to the evictions from the full overflow shelf
+type PolicyLoss struct {
+	Policy string  `json:"policy"`
+	Loss   float64 `json:"loss"`
+	InUse  bool    `json:"inUse"`
+}
+
+// CourierReport is the time the courier fleet spent on its trips, from assignment until available again
+type CourierReport struct {
+	Couriers    int            `json:"couriers"`
+	Trips       int            `json:"trips"`
+	ElapsedS    float64        `json:"elapsed"`
+	Utilisation float64        `json:"utilisation"`
+	IdleS       float64        `json:"idle"`
+	ByCourier   []CourierUsage `json:"byCourier"`
+}
+
+// CourierUsage is the time a single courier spent on its trips
+type CourierUsage struct {
+	ID          int     `json:"id"`
+	Trips       int     `json:"trips"`
+	Utilisation float64 `json:"utilisation"`
+	IdleS       float64 `json:"idle"`
+}
+
+// StrategyWait is the average time the orders picked up with a dispatch strategy waited on the shelves and their
+// couriers waited at the kitchen
+type StrategyWait struct {
+	Strategy     string  `json:"strategy"`
+	Pickups      int     `json:"pickups"`
+	FoodWaitS    float64 `json:"foodWait"`
+	CourierWaitS float64 `json:"courierWait"`
+}
+
+// OrderReport is the outcome of a single order: its final status, the shelf it was last on, the time of its first and
+// final status, and its value when it was picked up; wasted orders are worth 0 and orders not completed have no value
+type OrderReport struct {
+	OrderId      string    `json:"orderId"`
+	Temp         string    `json:"temp,omitempty"`
+	Status       string    `json:"status"`
+	Shelf        string    `json:"shelf,omitempty"`
+	ReceivedTime time.Time `json:"receivedTime"`
+	StatusTime   time.Time `json:"statusTime"`
+	Value        *float64  `json:"value,omitempty"`
+}
+
+// Columns of the orders in the CSV report
+var orderReportColumns = []string{"orderId", "temp", "status", "shelf", "receivedTime", "statusTime", "value"}
+
+// WriteFile writes the report to a file, as CSV if its extension is '.csv', else as JSON
+func (report Report) WriteFile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		err = report.WriteCSV(file)
+	} else {
+		err = report.WriteJSON(file)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// WriteJSON writes the report as a JSON document
+func (report Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// WriteCSV writes the orders of the report as CSV, a row per order after a header row
+func (report Report) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(orderReportColumns); err != nil {
+		return err
+	}
+
+	for _, order := range report.Orders {
+		value := ""
+		if order.Value != nil {
+			value = strconv.FormatFloat(*order.Value, 'f', 4, 64)
+		}
+		row := []string{order.OrderId, order.Temp, order.Status, order.Shelf, order.ReceivedTime.Format(time.RFC3339Nano), order.StatusTime.Format(time.RFC3339Nano), value}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// Report gives the order status report of the statuses recorded so far
+func (r *ReportBook) Report() Report {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+
+	report := Report{
+		Time:      r.clock.Now(),
+		Received:  len(r.status[model.ORDER_RECEIVED]),
+		Rejected:  len(r.status[model.ORDER_REJECTED]) + r.unidentified[model.ORDER_REJECTED],
+		Processed: len(r.status[model.ORDER_PROCESSED]),
+		PickedUp:  len(r.status[model.ORDER_PICKED]),
+		Expired:   len(r.status[model.ORDER_EXPIRED]),
+		Evicted:   len(r.status[model.ORDER_EVICTED]),
+		Cancelled: len(r.status[model.ORDER_CANCELLED]),
+		Evictions: r.evictions,
+
+		EvictionLoss: []PolicyLoss{},
+		Strategies:   []StrategyWait{},
+		Orders:       []OrderReport{},
+	}
+	report.Wasted = report.Expired + report.Evicted
+
+	report.ProcessedPercentage = percentage(report.Processed, report.Received)
+	report.DeliveryPercentage = percentage(report.PickedUp, report.Processed)
+	report.ExpiredPercentage = percentage(report.Expired, report.Processed)
+	report.EvictedPercentage = percentage(report.Evicted, report.Processed)
+	report.SuccessPercentage = percentage(report.PickedUp, report.Received)
+
+	if r.evictions > 0 {
+		for policy, loss := range r.evictionLoss {
+			report.EvictionLoss = append(report.EvictionLoss, PolicyLoss{Policy: policy, Loss: loss, InUse: policy == r.evictionPolicy})
+		}
+		sort.Slice(report.EvictionLoss, func(i, j int) bool { return report.EvictionLoss[i].Policy < report.EvictionLoss[j].Policy })
+	}
+
+	// Couriers are busy from their assignment until they are available again
+	if elapsed := report.Time.Sub(r.started); r.fleetSize > 0 && elapsed > 0 {
+		couriers := &CourierReport{Couriers: r.fleetSize, ElapsedS: elapsed.Seconds(), ByCourier: []CourierUsage{}}
+		var busy time.Duration
+		for courierId := 1; courierId <= r.fleetSize; courierId++ {
+			busy += r.courierBusy[courierId]
+			couriers.Trips += r.trips[courierId]
+			couriers.ByCourier = append(couriers.ByCourier, CourierUsage{
+				ID:          courierId,
+				Trips:       r.trips[courierId],
+				Utilisation: float64(r.courierBusy[courierId]) / float64(elapsed) * 100,
+				IdleS:       (elapsed - r.courierBusy[courierId]).Seconds(),
+			})
+		}
+		available := elapsed * time.Duration(r.fleetSize)
+		couriers.Utilisation = float64(busy) / float64(available) * 100
+		couriers.IdleS = (available - busy).Seconds()
+		report.Couriers = couriers
+	}
+
+	for strategy, waits := range r.pickupWaits {
+		wait := StrategyWait{Strategy: strategy, Pickups: waits.pickups}
+		if waits.pickups > 0 {
+			wait.FoodWaitS = (waits.food / time.Duration(waits.pickups)).Seconds()
+			wait.CourierWaitS = (waits.courier / time.Duration(waits.pickups)).Seconds()
+		}
+		report.Strategies = append(report.Strategies, wait)
+	}
+	sort.Slice(report.Strategies, func(i, j int) bool { return report.Strategies[i].Strategy < report.Strategies[j].Strategy })
+
+	for orderId, history := range r.history {
+		report.Orders = append(report.Orders, r.orderReport(orderId, history))
+	}
+	sort.Slice(report.Orders, func(i, j int) bool {
+		if !report.Orders[i].ReceivedTime.Equal(report.Orders[j].ReceivedTime) {
+			return report.Orders[i].ReceivedTime.Before(report.Orders[j].ReceivedTime)
+		}
+		return report.Orders[i].OrderId < report.Orders[j].OrderId
+	})
+	return report
+}
+
+// orderReport gives the outcome of an order from its status history; the report book must be locked
+func (r *ReportBook) orderReport(orderId string, history []model.OrderStatus) OrderReport {
+	final := r.index[orderId]
+	order := OrderReport{OrderId: orderId, Temp: r.temperatures[orderId], Status: final.Status, ReceivedTime: history[0].Time, StatusTime: final.Time}
+
+	for _, status := range history {
+		if status.Shelf != "" {
+			order.Shelf = status.Shelf
+		}
+	}
+
+	if value, isPresent := r.values[orderId]; isPresent {
+		order.Value = &value
+	} else if final.Status == model.ORDER_EXPIRED || final.Status == model.ORDER_EVICTED {
+		wasted := 0.0
+		order.Value = &wasted
+	}
+	return order
+}
+
+// percentage gives the percentage a part is of a total; 0 if the total is 0
+func percentage(part int, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) / float64(total) * 100
+}
@@ -0,0 +1,176 @@
+package supervisor
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/bus"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/clock"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/config"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/model"
+	repo "sharedkitchenordersystem/internal/app/sharedkitchenordersystem/repository/shelf"
+	"testing"
+	"time"
+)
+
+func TestReportBook_Report(t *testing.T) {
+	started := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		statuses       []model.OrderStatus
+		values         map[string]float64
+		wantDelivery   float64
+		wantSuccess    float64
+		wantWasted     int
+		wantOrders     []string
+		wantValues     []float64
+		wantHasValue   []bool
+		wantShelves    []string
+		wantStatusTime []time.Time
+	}{
+		{
+			name:       "TestReportBook_Report_NoOrders_ZeroPercentages",
+			wantOrders: []string{},
+		},
+		{
+			name: "TestReportBook_Report_PickedExpiredAndCooking_OrderOutcomes",
+			statuses: []model.OrderStatus{
+				{OrderId: "2", Status: model.ORDER_RECEIVED, Time: started.Add(time.Second)},
+				{OrderId: "1", Status: model.ORDER_RECEIVED, Time: started},
+				{OrderId: "3", Status: model.ORDER_RECEIVED, Time: started.Add(2 * time.Second)},
+				{OrderId: "1", Status: model.ORDER_PROCESSED, Time: started.Add(3 * time.Second)},
+				{OrderId: "2", Status: model.ORDER_PROCESSED, Time: started.Add(3 * time.Second)},
+				{OrderId: "1", Status: model.ORDER_OVERFLOWN, Time: started.Add(3 * time.Second), Shelf: model.OVERFLOW},
+				{OrderId: "1", Status: model.ORDER_PROMOTED, Time: started.Add(4 * time.Second), Shelf: model.OVERFLOW},
+				{OrderId: "1", Status: model.ORDER_STORED, Time: started.Add(4 * time.Second), Shelf: model.HOT},
+				{OrderId: "2", Status: model.ORDER_STORED, Time: started.Add(3 * time.Second), Shelf: model.COLD},
+				{OrderId: "1", Status: model.ORDER_PICKED, Time: started.Add(5 * time.Second), Shelf: model.HOT},
+				{OrderId: "2", Status: model.ORDER_EXPIRED, Time: started.Add(6 * time.Second)},
+				{OrderId: "3", Status: model.ORDER_COOKING, Time: started.Add(7 * time.Second)},
+			},
+			values:         map[string]float64{"1": 0.5},
+			wantDelivery:   50,
+			wantSuccess:    100.0 / 3,
+			wantWasted:     1,
+			wantOrders:     []string{"1", "2", "3"},
+			wantValues:     []float64{0.5, 0, 0},
+			wantHasValue:   []bool{true, true, false},
+			wantShelves:    []string{model.HOT, model.COLD, ""},
+			wantStatusTime: []time.Time{started.Add(5 * time.Second), started.Add(6 * time.Second), started.Add(7 * time.Second)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := New(bus.New(10, clock.NewWallClock()), repo.New(config.Default().Shelves), config.Default().IdleTimeoutS).Report
+			for _, status := range tt.statuses {
+				report.push(status)
+			}
+			for orderId, value := range tt.values {
+				report.RecordValue(orderId, value)
+			}
+
+			got := report.Report()
+			if math.Abs(got.DeliveryPercentage-tt.wantDelivery) > 1e-9 || math.Abs(got.SuccessPercentage-tt.wantSuccess) > 1e-9 {
+				t.Errorf("Report(), got delivery %v%% and success %v%%, want %v%% and %v%%", got.DeliveryPercentage, got.SuccessPercentage, tt.wantDelivery, tt.wantSuccess)
+			}
+			if got.Wasted != tt.wantWasted {
+				t.Errorf("Report(), got %d orders wasted, want %d", got.Wasted, tt.wantWasted)
+			}
+
+			orders := []string{}
+			for i, order := range got.Orders {
+				orders = append(orders, order.OrderId)
+				if i >= len(tt.wantHasValue) {
+					continue
+				}
+				if (order.Value != nil) != tt.wantHasValue[i] || (order.Value != nil && *order.Value != tt.wantValues[i]) {
+					t.Errorf("Report(), got order '%s' value %v, want %v", order.OrderId, order.Value, tt.wantValues[i])
+				}
+				if order.Shelf != tt.wantShelves[i] || !order.StatusTime.Equal(tt.wantStatusTime[i]) {
+					t.Errorf("Report(), got order '%s' on shelf '%s' at %s, want '%s' at %s", order.OrderId, order.Shelf, order.StatusTime, tt.wantShelves[i], tt.wantStatusTime[i])
+				}
+			}
+			if !reflect.DeepEqual(orders, tt.wantOrders) {
+				t.Errorf("Report(), got orders %v, want %v", orders, tt.wantOrders)
+			}
+		})
+	}
+}
+
+func TestReport_WriteFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	value := 0.75
+	received := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	report := Report{
+		Received: 2,
+		PickedUp: 1,
+		Orders: []OrderReport{
+			{OrderId: "1", Temp: model.HOT, Status: model.ORDER_PICKED, Shelf: model.HOT, ReceivedTime: received, StatusTime: received.Add(time.Second), Value: &value},
+			{OrderId: "2", Temp: model.COLD, Status: model.ORDER_COOKING, ReceivedTime: received, StatusTime: received},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		fileName string
+		wantRows [][]string
+	}{
+		{
+			name:     "TestReport_WriteFile_CsvExtension_OrderRows",
+			fileName: "report.csv",
+			wantRows: [][]string{
+				orderReportColumns,
+				{"1", model.HOT, model.ORDER_PICKED, model.HOT, "2020-01-01T00:00:00Z", "2020-01-01T00:00:01Z", "0.7500"},
+				{"2", model.COLD, model.ORDER_COOKING, "", "2020-01-01T00:00:00Z", "2020-01-01T00:00:00Z", ""},
+			},
+		},
+		{
+			name:     "TestReport_WriteFile_JsonExtension_Report",
+			fileName: "report.json",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.fileName)
+			if err := report.WriteFile(path); err != nil {
+				t.Fatalf("WriteFile(), got error %s", err)
+			}
+			content, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.wantRows != nil {
+				rows, err := csv.NewReader(bytes.NewReader(content)).ReadAll()
+				if err != nil {
+					t.Fatalf("WriteFile(), got content %s, want CSV: %s", content, err)
+				}
+				if !reflect.DeepEqual(rows, tt.wantRows) {
+					t.Errorf("WriteFile(), got rows %v, want %v", rows, tt.wantRows)
+				}
+				return
+			}
+
+			got := Report{}
+			if err := json.Unmarshal(content, &got); err != nil {
+				t.Fatalf("WriteFile(), got content %s, want JSON: %s", content, err)
+			}
+			if !reflect.DeepEqual(got, report) {
+				t.Errorf("WriteFile(), got report %+v, want %+v", got, report)
+			}
+		})
+	}
+}
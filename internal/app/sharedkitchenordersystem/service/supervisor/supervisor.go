@@ -1,38 +1,262 @@
 package supervisor
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/bus"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/clock"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/metrics"
 	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/model"
+	repo "sharedkitchenordersystem/internal/app/sharedkitchenordersystem/repository/shelf"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/repository/state"
+	"sort"
 	"sync"
 	"time"
 
 	"go.uber.org/zap"
 )
 
-var SupervisorChannel chan model.OrderStatus
+// Supervisor records the statuses reported by the services of a kitchen in its report book
+type Supervisor struct {
+	Report *ReportBook
 
-var KitchenChannel chan []model.Order = nil
+	bus          *bus.Bus
+	idleTimeoutS int
 
-var DispatchChannel chan model.Order = nil
+	lastActivityReportedTime      time.Time
+	lastActivityHealthCheckedTime time.Time
 
-var StorageChannel chan model.ShelfItem = nil
+	// stopped is closed once the supervisor stops recording statuses
+	stopped chan struct{}
+}
 
-var NewSpaceAvailableChannel chan string = nil
+type ReportBook struct {
+	shelves *repo.Repository
+	clock   clock.Clock
 
-var OverflownChannel chan model.ShelfItem = nil
+	index   map[string]model.OrderStatus
+	status  map[string]map[string]bool
+	history map[string][]model.OrderStatus
 
-var DispatchWaitGroup sync.WaitGroup
+	// Number of statuses reported for orders without an id, by status
+	unidentified map[string]int
 
-var Report *ReportBook
+	// Ids of the orders accepted at intake and sent to the kitchen, and their temperature
+	accepted     map[string]bool
+	temperatures map[string]string
 
-var lastActivityReportedTime time.Time = time.Now()
-var lastActivityHealthCheckedTime time.Time = time.Now()
+	// Value of the orders picked up when they were picked up
+	values map[string]float64
+
+	// Ids of the orders cancelled; they are taken off wherever they are by the service holding them
+	cancelled map[string]bool
+
+	// Eviction policy in use, and the value lost to the evictions of a full overflow shelf by policy; the value
+	// lost by the other policies is the one they would have lost on the same overflow shelf
+	evictionPolicy string
+	evictionLoss   map[string]float64
+	evictions      int
+
+	// Size of the courier fleet, and the time each courier spent on its completed trips and their number, by courier
+	fleetSize   int
+	courierBusy map[int]time.Duration
+	trips       map[int]int
+
+	// Time the orders picked up waited for a courier and the couriers waited for them, by dispatch strategy
+	pickupWaits map[string]*pickupWaits
+
+	// Distributions of the time the orders picked up waited on the shelves and the couriers took to arrive (seconds)
+	OrderWait    *metrics.Histogram
+	CourierDelay *metrics.Histogram
+
+	// Subscriptions to the events of the statuses recorded
+	subscriptions map[*Subscription]bool
+
+	// Time the report book was created, from which the couriers are available
+	started time.Time
 
-type ReportBook struct {
-	index  map[string]model.OrderStatus
-	status map[string]map[string]bool
 	locker sync.Mutex
 }
 
+// Accept records orders accepted at intake, before they are sent to the kitchen
+func (r *ReportBook) Accept(orders []model.Order) {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+
+	for _, order := range orders {
+		r.accepted[order.ID] = true
+		r.temperatures[order.ID] = order.Temp
+	}
+}
+
+// AcceptIfNew records the orders whose id was not accepted at intake yet, checking and recording them at once so an id
+// is never accepted twice. It gives the orders accepted and the orders whose id was already taken
+func (r *ReportBook) AcceptIfNew(orders []model.Order) ([]model.Order, []model.Order) {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+
+	accepted := make([]model.Order, 0, len(orders))
+	taken := []model.Order{}
+	for _, order := range orders {
+		if r.accepted[order.ID] {
+			taken = append(taken, order)
+			continue
+		}
+		r.accepted[order.ID] = true
+		r.temperatures[order.ID] = order.Temp
+		accepted = append(accepted, order)
+	}
+	return accepted, taken
+}
+
+// Withdraw forgets orders accepted at intake which were not sent to the kitchen after all, so their ids can be taken
+// again
+func (r *ReportBook) Withdraw(orders []model.Order) {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+
+	for _, order := range orders {
+		delete(r.accepted, order.ID)
+		delete(r.temperatures, order.ID)
+	}
+}
+
+// IsAccepted tells whether an order with the given id was already accepted at intake
+func (r *ReportBook) IsAccepted(orderId string) bool {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+
+	return r.accepted[orderId]
+}
+
+// Cancel records the cancellation of an accepted order which is not completed yet. The order is cancelled once the
+// service holding it reports it cancelled
+func (r *ReportBook) Cancel(orderId string) error {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+
+	if !r.accepted[orderId] {
+		return errors.New(fmt.Sprintf("Supervisor: Order '%s' not found", orderId))
+	}
+
+	if r.cancelled[orderId] {
+		return errors.New(fmt.Sprintf("Supervisor: Order '%s' is already cancelled", orderId))
+	}
+
+	if last, isPresent := r.index[orderId]; isPresent && model.IsTerminal(last.Status) {
+		return errors.New(fmt.Sprintf("Supervisor: Order '%s' is already %s", orderId, last.Status))
+	}
+
+	r.cancelled[orderId] = true
+	return nil
+}
+
+// IsCancelled tells whether the cancellation of an order was recorded
+func (r *ReportBook) IsCancelled(orderId string) bool {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+
+	return r.cancelled[orderId]
+}
+
+// SetFleetSize sets the number of couriers the idle time is reported for
+func (r *ReportBook) SetFleetSize(size int) {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+
+	r.fleetSize = size
+}
+
+// RecordCourierTrip records a courier completed a trip, from its assignment until it is available again
+func (r *ReportBook) RecordCourierTrip(courierId int, busy time.Duration) {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+
+	r.courierBusy[courierId] += busy
+	r.trips[courierId]++
+}
+
+// pickupWaits adds up the time the orders picked up waited on the shelves and their couriers waited at the kitchen
+type pickupWaits struct {
+	pickups int
+	food    time.Duration
+	courier time.Duration
+}
+
+// RecordPickupWait records the time an order picked up waited on the shelves and its courier waited at the kitchen
+// with the given dispatch strategy
+func (r *ReportBook) RecordPickupWait(strategy string, foodWait time.Duration, courierWait time.Duration) {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+
+	waits, isPresent := r.pickupWaits[strategy]
+	if !isPresent {
+		waits = &pickupWaits{}
+		r.pickupWaits[strategy] = waits
+	}
+	waits.pickups++
+	waits.food += foodWait
+	waits.courier += courierWait
+	r.OrderWait.Observe(foodWait.Seconds())
+}
+
+// RecordValue records the value of an order when it was picked up
+func (r *ReportBook) RecordValue(orderId string, value float64) {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+
+	r.values[orderId] = value
+}
+
+// RecordCourierDelay records the time a courier took to arrive at the kitchen once sent
+func (r *ReportBook) RecordCourierDelay(delay time.Duration) {
+	r.CourierDelay.Observe(delay.Seconds())
+}
+
+// StatusCounts gives the number of orders which reached each status, the rejected orders without an id included
+func (r *ReportBook) StatusCounts() map[string]int {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+
+	counts := make(map[string]int, len(r.status))
+	for status, orders := range r.status {
+		counts[status] = len(orders)
+	}
+	for status, count := range r.unidentified {
+		counts[status] += count
+	}
+	return counts
+}
+
+// InFlight gives the number of accepted orders which have not reached a terminal status yet (picked, expired, evicted
+// or cancelled)
+func (r *ReportBook) InFlight() int {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+
+	terminated := 0
+	for _, status := range r.index {
+		if model.IsTerminal(status.Status) {
+			terminated++
+		}
+	}
+
+	return len(r.accepted) - terminated
+}
+
+// RecordEvictionLoss records the value lost to an eviction by the policy in use and by the other policies
+func (r *ReportBook) RecordEvictionLoss(policy string, losses map[string]float64) {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+
+	r.evictionPolicy = policy
+	r.evictions++
+	for name, loss := range losses {
+		r.evictionLoss[name] += loss
+	}
+}
+
 func (r *ReportBook) IsTrashed(orderId string) bool {
 	r.locker.Lock()
 	defer r.locker.Unlock()
@@ -51,132 +275,299 @@ func (r *ReportBook) IsEvicted(orderId string) bool {
 	return isPresent && order.Status == model.ORDER_EVICTED
 }
 
-func (r *ReportBook) push(order model.OrderStatus) {
+// Lookup gives the last known status of an order, its status history and where it currently sits on the shelves
+func (r *ReportBook) Lookup(orderId string) (model.OrderDetails, error) {
 	r.locker.Lock()
-	defer r.locker.Unlock()
+	last, isPresent := r.index[orderId]
+	history := append([]model.OrderStatus{}, r.history[orderId]...)
+	r.locker.Unlock()
 
-	// Maintain last known status of an order
-	r.index[order.OrderId] = order
+	if !isPresent {
+		return model.OrderDetails{}, errors.New(fmt.Sprintf("Supervisor: Order '%s' not found", orderId))
+	}
 
-	// Maintain record of orders by status
-	if r.status[order.Status] == nil {
-		r.status[order.Status] = make(map[string]bool, 0)
+	details := model.OrderDetails{
+		OrderId: orderId,
+		Status:  last.Status,
+		History: history,
 	}
-	r.status[order.Status][order.OrderId] = true
+
+	if shelfType, item, isOnShelf := r.shelves.Locate(orderId); isOnShelf {
+		details.Shelf = shelfType
+		now := r.clock.Now()
+		details.RemainingShelfLifeS = int64(item.ExpiresAt().Sub(now).Seconds())
+		details.Value = item.Value(now)
+	}
+
+	return details, nil
+}
+
+// Shelves describes the items on every shelf now, and gives the time they are described at
+func (r *ReportBook) Shelves() (time.Time, []model.ShelfSnapshot) {
+	now := r.clock.Now()
+	return now, r.shelves.Snapshot(now)
+}
+
+// Snapshot gives the statuses reported so far, to be saved
+func (r *ReportBook) Snapshot() state.Report {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+
+	report := state.Report{
+		History:      make(map[string][]model.OrderStatus, len(r.history)),
+		Unidentified: make(map[string]int, len(r.unidentified)),
+		Accepted:     make([]string, 0, len(r.accepted)),
+		Temperatures: make(map[string]string, len(r.temperatures)),
+		Values:       make(map[string]float64, len(r.values)),
+		Cancelled:    make([]string, 0, len(r.cancelled)),
+	}
+	for orderId := range r.accepted {
+		report.Accepted = append(report.Accepted, orderId)
+	}
+	sort.Strings(report.Accepted)
+	for orderId := range r.cancelled {
+		report.Cancelled = append(report.Cancelled, orderId)
+	}
+	sort.Strings(report.Cancelled)
+	for orderId, history := range r.history {
+		report.History[orderId] = append([]model.OrderStatus{}, history...)
+	}
+	for status, count := range r.unidentified {
+		report.Unidentified[status] = count
+	}
+	for orderId, temp := range r.temperatures {
+		report.Temperatures[orderId] = temp
+	}
+	for orderId, value := range r.values {
+		report.Values[orderId] = value
+	}
+	return report
 }
 
-func (r *ReportBook) GenerateReport() {
+// Restore replaces the statuses reported so far with saved ones
+func (r *ReportBook) Restore(report state.Report) {
 	r.locker.Lock()
 	defer r.locker.Unlock()
 
-	var totalOrdersReceived float32 = 0
-	var totalOrdersProcessed float32 = 0
-	var totalOrdersExpired float32 = 0
-	var totalOrdersEvicted float32 = 0
-	var totalOrdersPickedUp float32 = 00
+	r.index = make(map[string]model.OrderStatus)
+	r.status = make(map[string]map[string]bool)
+	r.history = make(map[string][]model.OrderStatus)
+	r.unidentified = make(map[string]int)
+	r.accepted = make(map[string]bool, len(report.Accepted))
+	r.cancelled = make(map[string]bool)
+	r.temperatures = make(map[string]string, len(report.Temperatures))
+	r.values = make(map[string]float64, len(report.Values))
+
+	for _, history := range report.History {
+		for _, order := range history {
+			r.record(order)
+		}
+	}
+
+	// Rejections saved in the history of an order accepted later are replayed before it is accepted
+	for _, orderId := range report.Accepted {
+		r.accepted[orderId] = true
+	}
+	for status, count := range report.Unidentified {
+		r.unidentified[status] = count
+	}
+	for orderId, temp := range report.Temperatures {
+		r.temperatures[orderId] = temp
+	}
+	for orderId, value := range report.Values {
+		r.values[orderId] = value
+	}
+	for _, orderId := range report.Cancelled {
+		r.cancelled[orderId] = true
+	}
+}
+
+func (r *ReportBook) push(order model.OrderStatus) {
+	r.locker.Lock()
+	defer r.locker.Unlock()
 
-	orders, isPresent := r.status[model.ORDER_RECEIVED]
-	if isPresent {
-		totalOrdersReceived = float32(len(orders))
+	// The status recorded and the event published carry the same time
+	if order.Time.IsZero() {
+		order.Time = r.clock.Now()
 	}
+	r.record(order)
+	r.publish(order)
+}
 
-	orders, isPresent = r.status[model.ORDER_PICKED]
-	if isPresent {
-		totalOrdersPickedUp = float32(len(orders))
+// record records a status; the report book must be locked
+func (r *ReportBook) record(order model.OrderStatus) {
+	// Orders rejected for a missing id or for the id of an order already accepted can only be counted
+	if order.OrderId == "" || (order.Status == model.ORDER_REJECTED && r.accepted[order.OrderId]) {
+		r.unidentified[order.Status]++
+		return
 	}
 
-	orders, isPresent = r.status[model.ORDER_PROCESSED]
-	if isPresent {
-		totalOrdersProcessed = float32(len(orders))
+	// Maintain last known status of an order; a status reported late for a completed order, such as the stored status
+	// of an order cancelled while being stored, does not reopen it
+	if last, isPresent := r.index[order.OrderId]; !isPresent || !model.IsTerminal(last.Status) || model.IsTerminal(order.Status) {
+		r.index[order.OrderId] = order
 	}
 
-	orders, isPresent = r.status[model.ORDER_EXPIRED]
-	if isPresent {
-		totalOrdersExpired = float32(len(orders))
+	// Maintain status history of an order
+	r.history[order.OrderId] = append(r.history[order.OrderId], order)
+
+	// Maintain record of orders by status
+	if r.status[order.Status] == nil {
+		r.status[order.Status] = make(map[string]bool, 0)
 	}
+	r.status[order.Status][order.OrderId] = true
 
-	orders, isPresent = r.status[model.ORDER_EVICTED]
-	if isPresent {
-		totalOrdersEvicted = float32(len(orders))
+	if order.Status == model.ORDER_CANCELLED {
+		r.cancelled[order.OrderId] = true
 	}
+}
+
+// GenerateReport prints the order status report of the statuses recorded so far, and gives it
+func (r *ReportBook) GenerateReport() Report {
+	report := r.Report()
 
 	// Print report
 	zap.S().Infof("===============Order Status Report===============")
-	zap.S().Infof("Total Orders Received: %.0f", totalOrdersReceived)
-	zap.S().Infof("Total Orders Processed: %.0f", totalOrdersProcessed)
-	zap.S().Infof("Total Orders Picked-Up: %.0f", totalOrdersPickedUp)
-	zap.S().Infof("Total Orders Expired: %.0f", totalOrdersExpired)
-	zap.S().Infof("Total Orders Evicted: %.0f", totalOrdersEvicted)
-
-	zap.S().Infof("Orders processed percentage: %.2f%% ", (totalOrdersProcessed/totalOrdersReceived)*100)
-	zap.S().Infof("Orders delivery percentage: %.2f%% ", (totalOrdersPickedUp/totalOrdersProcessed)*100)
-	zap.S().Infof("Orders expired percentage: %.2f%%", (totalOrdersExpired/totalOrdersProcessed)*100)
-	zap.S().Infof("Orders evicted percentage: %.2f%%", (totalOrdersEvicted/totalOrdersProcessed)*100)
-
-	zap.S().Infof("Overall Orders success percentage: %.2f%%", (totalOrdersPickedUp/totalOrdersReceived)*100)
+	zap.S().Infof("Total Orders Received: %d", report.Received)
+	zap.S().Infof("Total Orders Rejected: %d", report.Rejected)
+	zap.S().Infof("Total Orders Processed: %d", report.Processed)
+	zap.S().Infof("Total Orders Picked-Up: %d", report.PickedUp)
+	zap.S().Infof("Total Orders Expired: %d", report.Expired)
+	zap.S().Infof("Total Orders Evicted: %d", report.Evicted)
+	zap.S().Infof("Total Orders Cancelled: %d", report.Cancelled)
+
+	zap.S().Infof("Orders processed percentage: %.2f%% ", report.ProcessedPercentage)
+	zap.S().Infof("Orders delivery percentage: %.2f%% ", report.DeliveryPercentage)
+	zap.S().Infof("Orders expired percentage: %.2f%%", report.ExpiredPercentage)
+	zap.S().Infof("Orders evicted percentage: %.2f%%", report.EvictedPercentage)
+
+	zap.S().Infof("Overall Orders success percentage: %.2f%%", report.SuccessPercentage)
+
+	// Compare the waste of the eviction policies
+	zap.S().Infof("Orders wasted (expired or evicted): %d", report.Wasted)
+	if report.Evictions > 0 {
+		zap.S().Infof("Value lost to %d evictions from the overflow shelf, by eviction policy:", report.Evictions)
+		for _, loss := range report.EvictionLoss {
+			if loss.InUse {
+				zap.S().Infof("  %s (in use): %.2f", loss.Policy, loss.Loss)
+			} else {
+				zap.S().Infof("  %s: %.2f", loss.Policy, loss.Loss)
+			}
+		}
+	}
+	if couriers := report.Couriers; couriers != nil {
+		zap.S().Infof("Couriers: %d couriers made %d trips in %s", couriers.Couriers, couriers.Trips, seconds(couriers.ElapsedS))
+		zap.S().Infof("Courier utilisation: %.2f%%", couriers.Utilisation)
+		zap.S().Infof("Courier idle time: %s (%s per courier)", seconds(couriers.IdleS), seconds(couriers.IdleS/float64(couriers.Couriers)))
+		for _, courier := range couriers.ByCourier {
+			zap.S().Infof("  courier %d: %d trips, utilisation %.2f%%, idle %s", courier.ID, courier.Trips, courier.Utilisation, seconds(courier.IdleS))
+		}
+	}
+	// Compare the waits of the dispatch strategies
+	for _, wait := range report.Strategies {
+		zap.S().Infof("Dispatch strategy %s: %d pickups, average food wait %s, average courier wait %s", wait.Strategy, wait.Pickups, (time.Duration(wait.FoodWaitS * float64(time.Second))).Round(time.Millisecond), (time.Duration(wait.CourierWaitS * float64(time.Second))).Round(time.Millisecond))
+	}
 	zap.S().Infof("===============End Report===============")
+	return report
 }
 
-// Start instantiates channels for Kicthen ,Dispatch, Storage, Supervisor, NewSpaceAvailable and Overflown events
-func Start(noOfOrdersToRead int) {
-	SupervisorChannel = make(chan model.OrderStatus, noOfOrdersToRead)
+// seconds gives a number of seconds as a duration rounded to the second
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second)).Round(time.Second)
+}
 
-	KitchenChannel = make(chan []model.Order, noOfOrdersToRead)
-	DispatchChannel = make(chan model.Order, noOfOrdersToRead)
-	StorageChannel = make(chan model.ShelfItem, noOfOrdersToRead)
+// New creates a supervisor recording the statuses reported on the bus; it reports the kitchen idle after
+// idleTimeout seconds without activity
+func New(eventBus *bus.Bus, shelves *repo.Repository, idleTimeout int) *Supervisor {
+	return &Supervisor{
+		Report: &ReportBook{
+			shelves: shelves,
+			clock:   eventBus.Clock,
+			index:   make(map[string]model.OrderStatus),
+			status:  make(map[string]map[string]bool),
+			history: make(map[string][]model.OrderStatus),
+
+			unidentified: make(map[string]int),
+			accepted:     make(map[string]bool),
+			temperatures: make(map[string]string),
+			values:       make(map[string]float64),
+
+			subscriptions: make(map[*Subscription]bool),
+			cancelled:     make(map[string]bool),
+			evictionLoss:  make(map[string]float64),
+			courierBusy:   make(map[int]time.Duration),
+			pickupWaits:   make(map[string]*pickupWaits),
+			trips:         make(map[int]int),
+			OrderWait:     metrics.NewHistogram(0.5, 1, 2, 5, 10, 20, 30, 60, 120, 300),
+			CourierDelay:  metrics.NewHistogram(1, 2, 3, 4, 5, 6, 8, 10, 15, 20, 30),
+			started:       eventBus.Clock.Now(),
+		},
+		bus:                           eventBus,
+		idleTimeoutS:                  idleTimeout,
+		lastActivityReportedTime:      eventBus.Clock.Now(),
+		lastActivityHealthCheckedTime: eventBus.Clock.Now(),
+		stopped:                       make(chan struct{}),
+	}
+}
 
-	NewSpaceAvailableChannel = make(chan string, noOfOrdersToRead)
-	OverflownChannel = make(chan model.ShelfItem, noOfOrdersToRead)
+// Start starts recording statuses; the supervisor stops when the context is done
+func (s *Supervisor) Start(ctx context.Context) {
+	s.process(ctx)
+}
 
-	Report = &ReportBook{
-		index:  make(map[string]model.OrderStatus),
-		status: make(map[string]map[string]bool),
-	}
-	process()
+// Wait waits for the supervisor to record the statuses already reported and stop, once its context is done
+func (s *Supervisor) Wait() {
+	<-s.stopped
 }
 
 // process processes events fired by mutiple services in different stages of the order processing cycle
-func process() {
+func (s *Supervisor) process(ctx context.Context) {
+	idleCheckTimer := s.bus.Clock.NewTimer(time.Second)
+
 	go func() {
+		defer close(s.stopped)
+
 		for {
 			select {
-			case reportMsg, isOpen := <-SupervisorChannel:
-				if !isOpen {
-					SupervisorChannel = nil
-					break
+			case reportMsg := <-s.bus.SupervisorChannel:
+				s.record(reportMsg)
+				s.bus.Handled()
+			case <-idleCheckTimer.C():
+				s.handleNoMsgReceived()
+				idleCheckTimer = s.bus.Clock.NewTimer(time.Second)
+				s.bus.Clock.Done()
+			case <-ctx.Done():
+				idleCheckTimer.Stop()
+
+				// Record the statuses reported before stopping
+				for {
+					select {
+					case reportMsg := <-s.bus.SupervisorChannel:
+						s.record(reportMsg)
+						s.bus.Handled()
+					default:
+						zap.S().Info("Supervisor: Stopped")
+						return
+					}
 				}
-
-				Report.push(reportMsg)
-				zap.S().Infof("Supervisor: Order '%s' is reported to supervisor with status %s", reportMsg.OrderId, reportMsg.Status)
-				lastActivityReportedTime = time.Now()
-			default:
-				handleNoMsgReceived()
-			}
-
-			if SupervisorChannel == nil {
-				break
 			}
 		}
 	}()
 }
 
+// record records a reported order status
+func (s *Supervisor) record(reportMsg model.OrderStatus) {
+	s.Report.push(reportMsg)
+	zap.S().Infof("Supervisor: Order '%s' is reported to supervisor with status %s", reportMsg.OrderId, reportMsg.Status)
+	s.lastActivityReportedTime = s.bus.Clock.Now()
+}
+
 // handleNoMsgReceived handles when there is no activity noticed across the kitchen
-func handleNoMsgReceived() {
-	idealTimeS := 10.0
-	now := time.Now()
-	if now.Sub(lastActivityReportedTime).Seconds() >= idealTimeS && now.Sub(lastActivityHealthCheckedTime).Seconds() >= idealTimeS {
+func (s *Supervisor) handleNoMsgReceived() {
+	idealTimeS := float64(s.idleTimeoutS)
+	now := s.bus.Clock.Now()
+	if now.Sub(s.lastActivityReportedTime).Seconds() >= idealTimeS && now.Sub(s.lastActivityHealthCheckedTime).Seconds() >= idealTimeS {
 		zap.S().Infof("---Supervisor: No orders received for more than %.0f(s). Press 'Ctrl + C' to terminate---", idealTimeS)
-		lastActivityHealthCheckedTime = now
+		s.lastActivityHealthCheckedTime = now
 	}
 }
-
-// Closes all the channels and cleans up the resources
-func CloseAll() {
-	close(SupervisorChannel)
-	close(KitchenChannel)
-	close(DispatchChannel)
-	close(StorageChannel)
-	close(NewSpaceAvailableChannel)
-	close(OverflownChannel)
-	Report = nil
-}
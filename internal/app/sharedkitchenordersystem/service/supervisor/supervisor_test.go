@@ -0,0 +1,138 @@
+package supervisor
+
+import (
+	"context"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/bus"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/clock"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/config"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/model"
+	repo "sharedkitchenordersystem/internal/app/sharedkitchenordersystem/repository/shelf"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReportBook_Lookup(t *testing.T) {
+	shelves := repo.New(config.Default().Shelves)
+	report := New(bus.New(10, clock.NewWallClock()), shelves, config.Default().IdleTimeoutS).Report
+
+	shelf, _ := shelves.ShelfFactory(model.HOT)
+	shelf.Push(model.NewShelfItem(model.Order{ID: "1", Name: "chicken", Temp: model.HOT, ShelfLife: 100, DecayRate: 1}, time.Now(), 1))
+
+	report.push(model.OrderStatus{OrderId: "1", Status: model.ORDER_RECEIVED})
+	report.push(model.OrderStatus{OrderId: "1", Status: model.ORDER_PROCESSED})
+	report.push(model.OrderStatus{OrderId: "1", Status: model.ORDER_STORED, Shelf: model.HOT})
+	report.push(model.OrderStatus{OrderId: "2", Status: model.ORDER_RECEIVED})
+	report.push(model.OrderStatus{OrderId: "2", Status: model.ORDER_PROCESSED})
+	report.push(model.OrderStatus{OrderId: "2", Status: model.ORDER_OVERFLOWN, Shelf: model.OVERFLOW})
+	report.push(model.OrderStatus{OrderId: "2", Status: model.ORDER_EXPIRED, Shelf: model.OVERFLOW})
+
+	tests := []struct {
+		name        string
+		orderId     string
+		wantErr     bool
+		wantStatus  string
+		wantHistory []string
+		wantShelf   string
+	}{
+		{
+			name:        "TestReportBook_Lookup_OrderOnShelf_HasShelfAndHistory",
+			orderId:     "1",
+			wantStatus:  model.ORDER_STORED,
+			wantHistory: []string{model.ORDER_RECEIVED, model.ORDER_PROCESSED, model.ORDER_STORED},
+			wantShelf:   model.HOT,
+		},
+		{
+			name:        "TestReportBook_Lookup_ExpiredOrder_NoShelf",
+			orderId:     "2",
+			wantStatus:  model.ORDER_EXPIRED,
+			wantHistory: []string{model.ORDER_RECEIVED, model.ORDER_PROCESSED, model.ORDER_OVERFLOWN, model.ORDER_EXPIRED},
+		},
+		{
+			name:    "TestReportBook_Lookup_UnknownOrder_Error",
+			orderId: "3",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			details, err := report.Lookup(tt.orderId)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Lookup(), got error %v, want error %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if details.Status != tt.wantStatus || details.Shelf != tt.wantShelf {
+				t.Errorf("Lookup(), got status %s on shelf '%s', want %s on shelf '%s'", details.Status, details.Shelf, tt.wantStatus, tt.wantShelf)
+			}
+
+			if len(details.History) != len(tt.wantHistory) {
+				t.Fatalf("Lookup(), got %d history entries, want %d", len(details.History), len(tt.wantHistory))
+			}
+
+			for i, status := range details.History {
+				if status.Status != tt.wantHistory[i] || status.Time.IsZero() {
+					t.Errorf("Lookup(), got history entry %d as %s at %s, want %s with a time", i, status.Status, status.Time, tt.wantHistory[i])
+				}
+			}
+		})
+	}
+}
+
+func TestReportBook_InFlight(t *testing.T) {
+	eventBus := bus.New(10, clock.NewWallClock())
+	supervisor := New(eventBus, repo.New(config.Default().Shelves), config.Default().IdleTimeoutS)
+	ctx, stop := context.WithCancel(context.Background())
+	supervisor.Start(ctx)
+
+	supervisor.Report.Accept([]model.Order{{ID: "1"}, {ID: "2"}, {ID: "3"}})
+	eventBus.SupervisorChannel <- model.OrderStatus{OrderId: "1", Status: model.ORDER_RECEIVED}
+	eventBus.SupervisorChannel <- model.OrderStatus{OrderId: "1", Status: model.ORDER_PICKED}
+	eventBus.SupervisorChannel <- model.OrderStatus{OrderId: "2", Status: model.ORDER_RECEIVED}
+	eventBus.SupervisorChannel <- model.OrderStatus{OrderId: "2", Status: model.ORDER_EXPIRED}
+	eventBus.SupervisorChannel <- model.OrderStatus{OrderId: "3", Status: model.ORDER_STORED}
+
+	// Statuses reported before stopping are recorded
+	stop()
+	supervisor.Wait()
+
+	if inFlight := supervisor.Report.InFlight(); inFlight != 1 {
+		t.Errorf("InFlight(), got %d, want %d", inFlight, 1)
+	}
+}
+
+func TestReportBook_AcceptIfNew(t *testing.T) {
+	report := New(bus.New(10, clock.NewWallClock()), repo.New(config.Default().Shelves), config.Default().IdleTimeoutS).Report
+
+	// Intakes accepting the same id at once accept it only once
+	var accepted int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if orders, _ := report.AcceptIfNew([]model.Order{{ID: "1", Temp: model.HOT}}); len(orders) == 1 {
+				atomic.AddInt32(&accepted, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if accepted != 1 {
+		t.Errorf("AcceptIfNew(), got id accepted %d times, want once", accepted)
+	}
+
+	orders, taken := report.AcceptIfNew([]model.Order{{ID: "1"}, {ID: "2"}})
+	if len(orders) != 1 || orders[0].ID != "2" || len(taken) != 1 || taken[0].ID != "1" {
+		t.Errorf("AcceptIfNew(), got accepted %v and taken %v, want order 2 accepted and order 1 taken", orders, taken)
+	}
+
+	report.Withdraw(orders)
+	if report.IsAccepted("2") || !report.IsAccepted("1") {
+		t.Errorf("Withdraw(), got order 2 accepted %v and order 1 accepted %v, want only order 1 accepted", report.IsAccepted("2"), report.IsAccepted("1"))
+	}
+}
@@ -0,0 +1,262 @@
+package sharedkitchenordersystem
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os/signal"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/clock"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/config"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/repository/order"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/service/supervisor"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Orders receive rate of the simulations sweeping no rates
+const DefaultNoOfOrdersToRead int = 2
+
+// DelayRange is a range of time (seconds) a courier takes to arrive once assigned an order
+type DelayRange struct {
+	MinS int
+	MaxS int
+}
+
+// SimulationGrid lists the values of the parameters swept by a batch of simulations, each combination of values
+// simulated in turn. A parameter without values keeps the value of the base config
+type SimulationGrid struct {
+	// Orders receive rates
+	NoOfOrdersToRead []int
+
+	// Capacities of every temperature controlled shelf, and of the overflow shelf
+	ShelfCapacities    []int
+	OverflowCapacities []int
+
+	CourierDelays []DelayRange
+
+	EvictionPolicies []string
+}
+
+// SimulationResult is the order status report of a simulation of the orders with a combination of the values of a
+// grid
+type SimulationResult struct {
+	NoOfOrdersToRead int
+	Config           *config.Config
+
+	Report   supervisor.Report
+	ExitCode int
+}
+
+// WastePercentage gives the orders expired or evicted out of the orders processed
+func (result SimulationResult) WastePercentage() float64 {
+	return result.Report.ExpiredPercentage + result.Report.EvictedPercentage
+}
+
+// MeanWaitS gives the average time (seconds) the orders picked up waited on the shelves; 0 if none was picked up
+func (result SimulationResult) MeanWaitS() float64 {
+	for _, wait := range result.Report.Strategies {
+		if wait.Strategy == result.Config.DispatchStrategy {
+			return wait.FoodWaitS
+		}
+	}
+	return 0
+}
+
+// ParseSimulationGrid reads a grid from comma-separated lists of values; courier delays are given as 'min-max'
+// ranges (seconds). An empty list sweeps no values
+func ParseSimulationGrid(noOfOrdersToRead string, shelfCapacities string, overflowCapacities string, courierDelays string, evictionPolicies string) (SimulationGrid, error) {
+	grid := SimulationGrid{EvictionPolicies: splitList(evictionPolicies)}
+	var err error
+
+	if grid.NoOfOrdersToRead, err = parsePositiveInts("orders receive rate", noOfOrdersToRead); err != nil {
+		return grid, err
+	}
+	if grid.ShelfCapacities, err = parsePositiveInts("shelf capacity", shelfCapacities); err != nil {
+		return grid, err
+	}
+	if grid.OverflowCapacities, err = parsePositiveInts("overflow shelf capacity", overflowCapacities); err != nil {
+		return grid, err
+	}
+
+	for _, value := range splitList(courierDelays) {
+		bounds := strings.SplitN(value, "-", 2)
+		if len(bounds) != 2 {
+			return grid, errors.New(fmt.Sprintf("Simulation: Invalid courier delay range '%s'; expected 'min-max'", value))
+		}
+
+		minS, minErr := strconv.Atoi(strings.TrimSpace(bounds[0]))
+		maxS, maxErr := strconv.Atoi(strings.TrimSpace(bounds[1]))
+		if minErr != nil || maxErr != nil {
+			return grid, errors.New(fmt.Sprintf("Simulation: Invalid courier delay range '%s'; expected 'min-max'", value))
+		}
+		grid.CourierDelays = append(grid.CourierDelays, DelayRange{MinS: minS, MaxS: maxS})
+	}
+
+	return grid, nil
+}
+
+// splitList splits a comma-separated list of values, leaving out the empty ones
+func splitList(list string) []string {
+	values := []string{}
+	for _, value := range strings.Split(list, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// parsePositiveInts reads a comma-separated list of positive numbers
+func parsePositiveInts(name string, list string) ([]int, error) {
+	numbers := []int{}
+	for _, value := range splitList(list) {
+		number, err := strconv.Atoi(value)
+		if err != nil || number <= 0 {
+			return nil, errors.New(fmt.Sprintf("Simulation: Invalid %s '%s'; expected a positive number", name, value))
+		}
+		numbers = append(numbers, number)
+	}
+	return numbers, nil
+}
+
+// Configs gives the config of every combination of the values of the grid, based on the given config, the value of
+// the last parameter changing first. They are not validated
+func (grid SimulationGrid) Configs(base *config.Config) []*config.Config {
+	configs := []*config.Config{copyConfig(base)}
+
+	configs = sweep(configs, len(grid.ShelfCapacities), func(cfg *config.Config, i int) {
+		for shelf := range cfg.Shelves {
+			if len(cfg.Shelves[shelf].Temperatures) == 1 {
+				cfg.Shelves[shelf].Capacity = grid.ShelfCapacities[i]
+			}
+		}
+	})
+	configs = sweep(configs, len(grid.OverflowCapacities), func(cfg *config.Config, i int) {
+		for shelf := range cfg.Shelves {
+			if len(cfg.Shelves[shelf].Temperatures) > 1 {
+				cfg.Shelves[shelf].Capacity = grid.OverflowCapacities[i]
+			}
+		}
+	})
+	configs = sweep(configs, len(grid.CourierDelays), func(cfg *config.Config, i int) {
+		cfg.Courier.MinDelayS = grid.CourierDelays[i].MinS
+		cfg.Courier.MaxDelayS = grid.CourierDelays[i].MaxS
+	})
+	configs = sweep(configs, len(grid.EvictionPolicies), func(cfg *config.Config, i int) {
+		cfg.EvictionPolicy = grid.EvictionPolicies[i]
+	})
+	return configs
+}
+
+// sweep gives, for every config, a copy of it for each of the values of a parameter, set by apply; the configs are
+// kept as they are if the parameter has no values
+func sweep(configs []*config.Config, values int, apply func(cfg *config.Config, i int)) []*config.Config {
+	if values == 0 {
+		return configs
+	}
+
+	swept := make([]*config.Config, 0, len(configs)*values)
+	for _, cfg := range configs {
+		for i := 0; i < values; i++ {
+			copied := copyConfig(cfg)
+			apply(copied, i)
+			swept = append(swept, copied)
+		}
+	}
+	return swept
+}
+
+// copyConfig copies a config along with its shelves, so the shelves of the copy can be changed
+func copyConfig(cfg *config.Config) *config.Config {
+	copied := *cfg
+	copied.Shelves = append([]config.ShelfConfig{}, cfg.Shelves...)
+	return &copied
+}
+
+// Simulate runs the application on a virtual clock for every combination of the values of the grid in turn, with the
+// orders of the orders file and the same seed, and gives their reports. The orders are only read from the file and
+// the state of the kitchen is neither restored nor saved. Every combination is validated before any is simulated. It
+// stops early, giving the simulations completed, if the process is asked to terminate
+func Simulate(base *config.Config, grid SimulationGrid, ordersPath string, seed int64) ([]SimulationResult, error) {
+	rates := grid.NoOfOrdersToRead
+	if len(rates) == 0 {
+		rates = []int{DefaultNoOfOrdersToRead}
+	}
+
+	configs := grid.Configs(base)
+	for _, cfg := range configs {
+		cfg.Persistence.Path = ""
+		if err := cfg.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	appCloseListener := listenToSystemCloseSignal()
+	defer signal.Stop(appCloseListener)
+
+	results := []SimulationResult{}
+	for _, rate := range rates {
+		for _, cfg := range configs {
+			select {
+			case <-appCloseListener:
+				zap.S().Warnf("Simulation: Received termination signal; stopped after %d of %d simulations", len(results), len(rates)*len(configs))
+				return results, nil
+			default:
+			}
+
+			virtualClock := clock.NewVirtualClock(time.Now())
+			source, err := order.NewOrderSource(order.FILE_SOURCE, ordersPath, virtualClock)
+			if err != nil {
+				return results, err
+			}
+
+			report, exitCode := Run(Options{
+				NoOfOrdersToRead: rate,
+				Config:           cfg,
+				Source:           source,
+				ExitOnCompletion: true,
+				Clock:            virtualClock,
+				Seed:             seed,
+			})
+			if exitCode != EXIT_OK {
+				zap.S().Warnf("Simulation: Run with %s ended with exit status %d", describeSimulation(rate, cfg), exitCode)
+			}
+			results = append(results, SimulationResult{NoOfOrdersToRead: rate, Config: cfg, Report: report, ExitCode: exitCode})
+		}
+	}
+	return results, nil
+}
+
+// describeSimulation describes the values of the parameters of a simulation
+func describeSimulation(rate int, cfg *config.Config) string {
+	return fmt.Sprintf("noOfOrdersToRead %d, shelf capacities %s, overflow capacity %s, courier delay %d-%ds, eviction policy '%s'", rate, shelfCapacities(cfg, false), shelfCapacities(cfg, true), cfg.Courier.MinDelayS, cfg.Courier.MaxDelayS, cfg.EvictionPolicy)
+}
+
+// shelfCapacities gives the capacities of the temperature controlled shelves, or of the overflow shelf, in the order
+// they are configured
+func shelfCapacities(cfg *config.Config, overflow bool) string {
+	capacities := []string{}
+	for _, shelf := range cfg.Shelves {
+		if (len(shelf.Temperatures) > 1) == overflow {
+			capacities = append(capacities, strconv.Itoa(shelf.Capacity))
+		}
+	}
+	return strings.Join(capacities, "/")
+}
+
+// WriteSimulations writes the results of simulations as a table, a row per simulation: the values of its parameters,
+// the orders wasted and delivered out of the orders processed, and the average time the orders picked up waited on
+// the shelves
+func WriteSimulations(w io.Writer, results []SimulationResult) error {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "ORDERS/S\tSHELVES\tOVERFLOW\tCOURIER DELAY\tEVICTION\tWASTE %\tDELIVERY %\tMEAN WAIT")
+	for _, result := range results {
+		cfg := result.Config
+		fmt.Fprintf(table, "%d\t%s\t%s\t%d-%ds\t%s\t%.2f\t%.2f\t%.2fs\n", result.NoOfOrdersToRead, shelfCapacities(cfg, false), shelfCapacities(cfg, true), cfg.Courier.MinDelayS, cfg.Courier.MaxDelayS, cfg.EvictionPolicy, result.WastePercentage(), result.Report.DeliveryPercentage, result.MeanWaitS())
+	}
+	return table.Flush()
+}
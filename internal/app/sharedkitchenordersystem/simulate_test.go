@@ -0,0 +1,147 @@
+package sharedkitchenordersystem
+
+import (
+	"bytes"
+	"reflect"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/config"
+	"strings"
+	"testing"
+)
+
+func TestParseSimulationGrid(t *testing.T) {
+	tests := []struct {
+		name               string
+		noOfOrdersToRead   string
+		shelfCapacities    string
+		overflowCapacities string
+		courierDelays      string
+		evictionPolicies   string
+		want               SimulationGrid
+		wantErr            bool
+	}{
+		{
+			name: "TestParseSimulationGrid_NoValues_EmptyGrid",
+			want: SimulationGrid{NoOfOrdersToRead: []int{}, ShelfCapacities: []int{}, OverflowCapacities: []int{}, EvictionPolicies: []string{}},
+		},
+		{
+			name:               "TestParseSimulationGrid_EveryParameter_Parsed",
+			noOfOrdersToRead:   "2, 5",
+			shelfCapacities:    "5,10",
+			overflowCapacities: "15",
+			courierDelays:      "2-6,8-12",
+			evictionPolicies:   "random,oldest",
+			want: SimulationGrid{
+				NoOfOrdersToRead:   []int{2, 5},
+				ShelfCapacities:    []int{5, 10},
+				OverflowCapacities: []int{15},
+				CourierDelays:      []DelayRange{{MinS: 2, MaxS: 6}, {MinS: 8, MaxS: 12}},
+				EvictionPolicies:   []string{config.EVICT_RANDOM, config.EVICT_OLDEST},
+			},
+		},
+		{
+			name:            "TestParseSimulationGrid_CapacityNotPositive_Error",
+			shelfCapacities: "10,0",
+			wantErr:         true,
+		},
+		{
+			name:             "TestParseSimulationGrid_RateNotNumber_Error",
+			noOfOrdersToRead: "two",
+			wantErr:          true,
+		},
+		{
+			name:          "TestParseSimulationGrid_DelayNotRange_Error",
+			courierDelays: "6",
+			wantErr:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSimulationGrid(tt.noOfOrdersToRead, tt.shelfCapacities, tt.overflowCapacities, tt.courierDelays, tt.evictionPolicies)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSimulationGrid(), got error %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseSimulationGrid(), got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSimulationGrid_Configs(t *testing.T) {
+	base := config.Default()
+	grid := SimulationGrid{
+		ShelfCapacities:    []int{5, 20},
+		OverflowCapacities: []int{30},
+		EvictionPolicies:   []string{config.EVICT_OLDEST, config.EVICT_RELOCATE},
+	}
+
+	configs := grid.Configs(base)
+
+	got := []string{}
+	for _, cfg := range configs {
+		got = append(got, shelfCapacities(cfg, false)+" "+shelfCapacities(cfg, true)+" "+cfg.EvictionPolicy)
+	}
+	want := []string{"5/5/5 30 oldest", "5/5/5 30 relocate", "20/20/20 30 oldest", "20/20/20 30 relocate"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Configs(), got %v, want %v", got, want)
+	}
+
+	if shelfCapacities(base, false) != "10/10/10" || shelfCapacities(base, true) != "15" || base.EvictionPolicy != config.EVICT_RANDOM {
+		t.Errorf("Configs(), got base config changed, want it kept")
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	cfg := config.Default()
+	grid := SimulationGrid{
+		NoOfOrdersToRead: []int{2, 5},
+		ShelfCapacities:  []int{2},
+		CourierDelays:    []DelayRange{{MinS: 8, MaxS: 12}},
+		EvictionPolicies: []string{config.EVICT_RANDOM, config.EVICT_LOWEST_VALUE},
+	}
+
+	tables := []string{}
+	for run := 0; run < 2; run++ {
+		results, err := Simulate(cfg, grid, "repository/order/orders.json", 7)
+		if err != nil {
+			t.Fatalf("Simulate(), got error %s, want none", err)
+		}
+		if len(results) != 4 {
+			t.Fatalf("Simulate(), got %d results, want one per combination", len(results))
+		}
+
+		for _, result := range results {
+			if result.ExitCode != EXIT_OK {
+				t.Errorf("Simulate(), got exit status %d, want every order completed", result.ExitCode)
+			}
+			if completed := result.Report.PickedUp + result.Report.Expired + result.Report.Evicted; completed != result.Report.Received || completed == 0 {
+				t.Errorf("Simulate(), got %d orders completed of %d received, want all of them", completed, result.Report.Received)
+			}
+			if result.WastePercentage() == 0 {
+				t.Errorf("Simulate(), got no orders wasted with small shelves and slow couriers, want some")
+			}
+		}
+
+		table := bytes.Buffer{}
+		if err := WriteSimulations(&table, results); err != nil {
+			t.Fatalf("WriteSimulations(), got error %s, want none", err)
+		}
+		tables = append(tables, table.String())
+	}
+
+	if lines := strings.Split(strings.TrimSpace(tables[0]), "\n"); len(lines) != 5 || !strings.HasPrefix(lines[1], "2 ") || !strings.Contains(lines[4], config.EVICT_LOWEST_VALUE) {
+		t.Errorf("WriteSimulations(), got table\n%s\nwant a header and a row per combination in order", tables[0])
+	}
+	if tables[0] != tables[1] {
+		t.Errorf("Simulate(), got tables\n%s\nand\n%s\nwant the same seed to give the same results", tables[0], tables[1])
+	}
+}
+
+func TestSimulate_InvalidCombination_Error(t *testing.T) {
+	grid := SimulationGrid{CourierDelays: []DelayRange{{MinS: 2, MaxS: 6}, {MinS: 6, MaxS: 2}}}
+
+	if results, err := Simulate(config.Default(), grid, "repository/order/orders.json", 7); err == nil || results != nil {
+		t.Errorf("Simulate(), got %d results and error %v, want an error before any simulation", len(results), err)
+	}
+}
@@ -1,14 +1,16 @@
 package sharedkitchenordersystem
 
 import (
-	"math"
+	"context"
+	"net/http"
 	"os"
 	"os/signal"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/api"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/clock"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/config"
 	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/model"
 	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/repository/order"
-	dispatchService "sharedkitchenordersystem/internal/app/sharedkitchenordersystem/service/dispatch"
-	kitchenService "sharedkitchenordersystem/internal/app/sharedkitchenordersystem/service/kitchen"
-	storageService "sharedkitchenordersystem/internal/app/sharedkitchenordersystem/service/storage"
+	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/service/intake"
 	"sharedkitchenordersystem/internal/app/sharedkitchenordersystem/service/supervisor"
 	"syscall"
 	"time"
@@ -16,83 +18,198 @@ import (
 	"go.uber.org/zap"
 )
 
-// Initialize the application.
-func Start(noOfOrdersToRead int) {
+// Exit status codes of the application
+const EXIT_OK int = 0
+const EXIT_SOURCE_FAILED int = 1
+const EXIT_INCOMPLETE int = 2
 
-	listenToSystemCloseSignal()
+// Options configures a run of the application
+type Options struct {
+	// Orders receive rate
+	NoOfOrdersToRead int
 
-	// initliaze repos
-	order.InitOrders()
+	// HTTP address to accept orders on; orders are not accepted over HTTP if empty
+	Address string
+
+	Config *config.Config
+
+	Source order.OrderSource
+
+	// Stop once the source has no more orders and every accepted order reached a terminal status
+	ExitOnCompletion bool
+
+	// Clock the kitchen and the source run on
+	Clock clock.Clock
+
+	// Seed of the random courier delays and evictions
+	Seed int64
+
+	// File the order status report is written to, as CSV if its extension is '.csv', else as JSON; not written if empty
+	ReportPath string
+}
+
+// Initialize the application. Orders are read from the source and accepted over HTTP until the process is asked
+// to terminate or, if requested, every order is completed; the kitchen then drains and the order status report
+// is printed. It gives the exit status code of the run
+func Start(options Options) int {
+	_, exitCode := Run(options)
+	return exitCode
+}
+
+// Run runs the application as Start does, and gives the order status report of the run along with its exit status
+// code
+func Run(options Options) (supervisor.Report, int) {
+	noOfOrdersToRead := options.NoOfOrdersToRead
+	cfg := options.Config
+
+	appCloseListener := listenToSystemCloseSignal()
+	defer signal.Stop(appCloseListener)
+
+	// A virtual clock must not move forward until the kitchen and the source are started
+	options.Clock.Begin()
 
 	// start services
-	supervisor.Start(noOfOrdersToRead)
+	kitchen := NewKitchen(noOfOrdersToRead, cfg, options.Clock, options.Seed)
+	if err := kitchen.Restore(); err != nil {
+		zap.S().Errorf("Admin: Could not restore kitchen state, starting empty: %s", err)
+	}
+	kitchen.Start()
 
-	dispatchService.Start(noOfOrdersToRead)
-	storageService.Start(noOfOrdersToRead)
-	kitchenService.Start(noOfOrdersToRead)
+	// start accepting orders over HTTP and from the source
+	var server *http.Server
+	if options.Address != "" {
+		server = api.Start(options.Address, kitchen.Handler())
 
-	orderReaderChannel := make(chan []model.Order, noOfOrdersToRead)
+		// Open event streams end once the server shuts down
+		server.RegisterOnShutdown(kitchen.Supervisor.Report.CloseSubscriptions)
+	}
 
-	go func(ordersData []model.Order) {
+	intakeCtx, stopIntake := context.WithCancel(context.Background())
+	defer stopIntake()
+	sourceResult := make(chan error, 1)
+	if batches, err := options.Source.Stream(intakeCtx, noOfOrdersToRead); err != nil {
+		zap.S().Errorf("Admin: Could not read orders from source: %s", err)
+		sourceResult <- err
+	} else {
+		go func() {
+			sourceResult <- readOrders(intakeCtx, kitchen.Intake, options.Clock, batches)
+		}()
+	}
+	options.Clock.Done()
 
-		if len(ordersData) == 0 {
-			return
-		}
+	exitCode := EXIT_OK
+	var completed <-chan struct{}
 
-		end := 0
-		for i := 0; i < len(ordersData); i += noOfOrdersToRead {
-			end = int(math.Min(float64(i+noOfOrdersToRead), float64(len(ordersData))))
-			/*for _, order := range ordersData[i:end] {
-				zap.S().Infof("Admin: Order '%s' (%s) received", order.Name, order.ID)
-				orderReaderChannel <- order
-			}*/
+	for isRunning := true; isRunning; {
+		select {
+		case <-appCloseListener:
+			zap.S().Infof("Admin: Received termination signal (%s). Stopping intake and draining in-flight orders....", "Ctrl+C")
+			isRunning = false
+		case err := <-sourceResult:
+			if err != nil && options.ExitOnCompletion {
+				exitCode = EXIT_SOURCE_FAILED
+				isRunning = false
+			} else if options.ExitOnCompletion {
+				zap.S().Info("Admin: Waiting for every order to be completed....")
+				completed = waitForCompletion(intakeCtx, options.Clock, kitchen.Supervisor.Report)
+			}
+		case <-completed:
+			zap.S().Info("Admin: Every order is completed")
+			isRunning = false
+		}
+	}
 
-			orderReaderChannel <- ordersData[i:end]
+	// stop intake
+	stopIntake()
+	if server != nil {
+		if err := server.Shutdown(context.Background()); err != nil {
+			zap.S().Errorf("Admin: Could not stop API server: %s", err)
+		}
+	}
 
-			time.Sleep(time.Second)
+	// let in-flight orders reach a terminal status, then stop services
+	if !kitchen.Drain(time.Duration(cfg.ShutdownTimeoutS)*time.Second) && exitCode == EXIT_OK {
+		exitCode = EXIT_INCOMPLETE
+	}
+	kitchen.Stop()
+
+	zap.S().Info("Admin: Printing order status report before closing....")
+	report := kitchen.Supervisor.Report.GenerateReport()
+	if options.ReportPath != "" {
+		if err := report.WriteFile(options.ReportPath); err != nil {
+			zap.S().Errorf("Admin: Could not write report to '%s': %s", options.ReportPath, err)
+		} else {
+			zap.S().Infof("Admin: Report written to '%s'", options.ReportPath)
 		}
+	}
+	zap.S().Infof("----------------------Application shutting down (exit status %d)----------------------", exitCode)
+	return report, exitCode
+}
 
-		zap.S().Info("Admin: No more receiving Orders; kitchen closed")
-		zap.S().Info("===============================================")
+// readOrders sends the batches of orders read from the source to the kitchen until the source has no more orders.
+// While the kitchen queue has no room for a batch, the batches read meanwhile wait behind it in turn; they are dropped
+// once the context is done
+func readOrders(ctx context.Context, orderIntake *intake.Service, sourceClock clock.Clock, batches <-chan []model.Order) error {
+	backlog := [][]model.Order{}
 
-		// stop services
-		//dispatchService.Stop()
-		//storageService.Stop()
-		//kitchenService.Stop()
-		// close(orderReaderChannel)
-	}(order.OrdersData)
+	// Orders of the batch waiting for room in the kitchen queue, sent once admitted
+	var waiting []model.Order
+	var admitted <-chan struct{}
 
-	for {
+	for batches != nil || admitted != nil {
 		select {
-		case orderReqs, isOpen := <-orderReaderChannel:
+		case <-ctx.Done():
+			if admitted != nil {
+				zap.S().Infof("Admin: '%d' batches of orders left waiting for room in the kitchen queue", len(backlog)+1)
+			}
+			return nil
+		case orderReqs, isOpen := <-batches:
 			if !isOpen {
-				orderReaderChannel = nil
-				break
+				batches = nil
+				continue
 			}
-
-			zap.S().Infof("Admin: Received number of orders '%d' and are being sent to kitchen at %s", len(orderReqs), time.Now())
-			supervisor.KitchenChannel <- orderReqs
+			zap.S().Infof("Admin: Received number of orders '%d' and are being sent to kitchen at %s", len(orderReqs), sourceClock.Now())
+			backlog = append(backlog, orderReqs)
+		case <-admitted:
+			zap.S().Infof("Admin: Number of orders '%d' admitted to the kitchen queue at %s", len(waiting), sourceClock.Now())
+			orderIntake.Cook(waiting)
+			waiting, admitted = nil, nil
 		}
 
-		if orderReaderChannel == nil {
-			break
+		for admitted == nil && len(backlog) > 0 {
+			waiting, admitted = orderIntake.SubmitLater(backlog[0])
+			backlog = backlog[1:]
 		}
+		sourceClock.Done()
 	}
-}
 
-// ListenToSystemCloseSignal listens to OS interrupt signal. Cleans resources and prints orders status report
-func listenToSystemCloseSignal() {
-	appCloseListener := make(chan os.Signal)
-	signal.Notify(appCloseListener, os.Interrupt, syscall.SIGTERM)
+	zap.S().Info("Admin: No more receiving Orders from source; kitchen closed")
+	zap.S().Info("===============================================")
+	return nil
+}
 
+// waitForCompletion gives a channel closed once every accepted order reached a terminal status
+func waitForCompletion(ctx context.Context, kitchenClock clock.Clock, report *supervisor.ReportBook) <-chan struct{} {
+	completed := make(chan struct{})
 	go func() {
-		<-appCloseListener
-		zap.S().Infof("Admin: Received termination signal (%s).Printing order status report before closing....", "Ctrl+C")
-		supervisor.Report.GenerateReport()
-		zap.S().Info("Admin: Cleaning resources....")
-		supervisor.CloseAll()
-		zap.S().Info("----------------------Application shutting down----------------------")
-
-		os.Exit(0)
+		for report.InFlight() > 0 {
+			timer := kitchenClock.NewTimer(100 * time.Millisecond)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C():
+				kitchenClock.Done()
+			}
+		}
+		close(completed)
 	}()
+	return completed
+}
+
+// ListenToSystemCloseSignal listens to OS interrupt and terminate signals
+func listenToSystemCloseSignal() chan os.Signal {
+	appCloseListener := make(chan os.Signal, 1)
+	signal.Notify(appCloseListener, os.Interrupt, syscall.SIGTERM)
+	return appCloseListener
 }
@@ -2,9 +2,12 @@ package pkg
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
 )
 
+// ReadFile reads the JSON file into content; it fails if the file is not valid JSON
 func ReadFile(name string, content interface{}) error {
 	jsonFile, err := ioutil.ReadFile(name)
 
@@ -12,6 +15,8 @@ func ReadFile(name string, content interface{}) error {
 		return err
 	}
 
-	json.Unmarshal([]byte(jsonFile), &content)
+	if err := json.Unmarshal(jsonFile, content); err != nil {
+		return errors.New(fmt.Sprintf("Invalid JSON in '%s': %s", name, err))
+	}
 	return nil
 }